@@ -0,0 +1,197 @@
+package gin
+
+import (
+	"fmt"
+	"sort"
+)
+
+// An ActionMap maps named actions, like "jump", "fire" or "move_x", to the
+// bindings that trigger them for a single player.  Games with several local
+// players should make one ActionMap per player.  Bindings can be replaced at
+// any time, so players can rebind their controls without the game needing to
+// keep track of Keys.
+//
+// Each action is backed by a derived key that is created when the action is
+// bound and unbound when the action is rebound.  Queries about an action
+// reflect the state of its derived key, so Pressed() and Released() report on
+// the last frame processed by Input.Think().
+type ActionMap struct {
+	input   *Input
+	actions map[string]*action
+
+	// If set, actions only report input while this context is active.
+	context *InputContext
+}
+
+type action struct {
+	// Bindings for the positive and negative directions of the action.  Only
+	// axis actions have negative bindings.
+	bindings     []Binding
+	neg_bindings []Binding
+
+	// Derived keys for the above bindings, nil if there are no such bindings.
+	key, neg Key
+}
+
+func (input *Input) MakeActionMap() *ActionMap {
+	return &ActionMap{
+		input:   input,
+		actions: make(map[string]*action),
+	}
+}
+
+// Declare makes actions known to the ActionMap without binding them to
+// anything.  Querying an action that has never been declared or bound will
+// panic, this catches misspelled action names.
+func (am *ActionMap) Declare(names ...string) {
+	for _, name := range names {
+		if _, ok := am.actions[name]; !ok {
+			am.actions[name] = &action{}
+		}
+	}
+}
+
+// Actions returns the names of all declared actions in sorted order.
+func (am *ActionMap) Actions() []string {
+	var names []string
+	for name := range am.actions {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Bind replaces all of the bindings for the named action.  The action is
+// declared if it hasn't been already.
+func (am *ActionMap) Bind(name string, bindings ...Binding) {
+	am.bindAxis(name, bindings, nil)
+}
+
+// BindFamily is like Bind, but it binds families on a specific device.  This
+// is convenient for binding the same controls to different devices for
+// different players.
+func (am *ActionMap) BindFamily(name string, device DeviceId, families ...BindingFamily) {
+	var bindings []Binding
+	for _, family := range families {
+		bindings = append(bindings, am.input.bindingFromFamily(family, device))
+	}
+	am.Bind(name, bindings...)
+}
+
+// BindAxis replaces all of the bindings for the named action with bindings
+// that make it behave like an axis.  Its Value() is the press amount of the
+// positive bindings minus the press amount of the negative bindings.
+func (am *ActionMap) BindAxis(name string, positive, negative []Binding) {
+	am.bindAxis(name, positive, negative)
+}
+
+// Unbind removes all bindings from the named action, but leaves it declared.
+func (am *ActionMap) Unbind(name string) {
+	am.bindAxis(name, nil, nil)
+}
+
+func (am *ActionMap) bindAxis(name string, positive, negative []Binding) {
+	am.Declare(name)
+	a := am.actions[name]
+	if a.key != nil {
+		am.input.UnbindDerivedKey(a.key)
+		a.key = nil
+	}
+	if a.neg != nil {
+		am.input.UnbindDerivedKey(a.neg)
+		a.neg = nil
+	}
+	a.bindings = positive
+	a.neg_bindings = negative
+	if len(positive) > 0 {
+		a.key = am.input.BindDerivedKey(name, positive...)
+	}
+	if len(negative) > 0 {
+		a.neg = am.input.BindDerivedKey(name+"-", negative...)
+	}
+}
+
+// Bindings returns the positive and negative bindings for the named action.
+func (am *ActionMap) Bindings(name string) (positive, negative []Binding) {
+	a := am.get(name)
+	return a.bindings, a.neg_bindings
+}
+
+// Key returns the derived key that backs the positive direction of the named
+// action, or nil if the action is not bound.
+func (am *ActionMap) Key(name string) Key {
+	return am.get(name).key
+}
+
+// SetContext ties the ActionMap to an InputContext.  While that context is not
+// active, for example because a blocking menu context has been pushed above
+// it, every action reports that it is up and has a Value() of 0.  Passing nil
+// unties the ActionMap from any context.
+//
+// Only blocking contexts mask actions.  An EventConsumer in a higher,
+// non-blocking context that consumes a press doesn't stop the press from being
+// reported here, since actions read key state rather than listening for
+// events.
+func (am *ActionMap) SetContext(c *InputContext) {
+	am.context = c
+}
+
+func (am *ActionMap) masked() bool {
+	return am.context != nil && !am.context.Active()
+}
+
+func (am *ActionMap) get(name string) *action {
+	a, ok := am.actions[name]
+	if !ok {
+		panic(fmt.Sprintf("Action '%s' has not been declared.", name))
+	}
+	return a
+}
+
+// Pressed returns true iff the named action was pressed during the last frame.
+func (am *ActionMap) Pressed(name string) bool {
+	a := am.get(name)
+	if am.masked() {
+		return false
+	}
+	return (a.key != nil && a.key.FramePressCount() > 0) ||
+		(a.neg != nil && a.neg.FramePressCount() > 0)
+}
+
+// Released returns true iff the named action was released during the last
+// frame.
+func (am *ActionMap) Released(name string) bool {
+	a := am.get(name)
+	if am.masked() {
+		return false
+	}
+	return (a.key != nil && a.key.FrameReleaseCount() > 0) ||
+		(a.neg != nil && a.neg.FrameReleaseCount() > 0)
+}
+
+// IsDown returns true iff any of the bindings for the named action are
+// currently down.
+func (am *ActionMap) IsDown(name string) bool {
+	a := am.get(name)
+	if am.masked() {
+		return false
+	}
+	return (a.key != nil && a.key.IsDown()) || (a.neg != nil && a.neg.IsDown())
+}
+
+// Value returns the current press amount of the named action.  For axis
+// actions this is the positive press amount minus the negative press amount.
+func (am *ActionMap) Value(name string) float64 {
+	a := am.get(name)
+	value := 0.0
+	if am.masked() {
+		return value
+	}
+	if a.key != nil {
+		value += a.key.CurPressAmt()
+	}
+	if a.neg != nil {
+		value -= a.neg.CurPressAmt()
+	}
+	return value
+}
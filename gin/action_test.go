@@ -0,0 +1,86 @@
+package gin_test
+
+import (
+	"github.com/orfjackal/gospec/src/gospec"
+	. "github.com/orfjackal/gospec/src/gospec"
+	"github.com/runningwild/glop/gin"
+)
+
+func ActionMapSpec(c gospec.Context) {
+	input := gin.Make()
+	kb1 := gin.DeviceId{Type: gin.DeviceTypeKeyboard, Index: 1}
+	am := input.MakeActionMap()
+	am.Declare("fire")
+	am.Bind("jump", input.MakeBinding(gin.KeyId{Index: gin.Space, Device: kb1}, nil, nil))
+	am.BindAxis("move_x",
+		[]gin.Binding{input.MakeBinding(gin.KeyId{Index: gin.KeyD, Device: kb1}, nil, nil)},
+		[]gin.Binding{input.MakeBinding(gin.KeyId{Index: gin.KeyA, Device: kb1}, nil, nil)})
+
+	c.Specify("Actions report presses of their bindings.", func() {
+		events := make([]gin.OsEvent, 0)
+		injectEvent(&events, gin.Space, 1, gin.DeviceTypeKeyboard, 1, 5)
+		input.Think(10, true, events)
+		c.Expect(am.Pressed("jump"), Equals, true)
+		c.Expect(am.IsDown("jump"), Equals, true)
+		c.Expect(am.Value("jump"), Equals, 1.0)
+		c.Expect(am.Pressed("fire"), Equals, false)
+
+		events = events[0:0]
+		injectEvent(&events, gin.Space, 1, gin.DeviceTypeKeyboard, 0, 15)
+		input.Think(20, true, events)
+		c.Expect(am.Pressed("jump"), Equals, false)
+		c.Expect(am.Released("jump"), Equals, true)
+		c.Expect(am.IsDown("jump"), Equals, false)
+	})
+
+	c.Specify("Axis actions combine their positive and negative bindings.", func() {
+		events := make([]gin.OsEvent, 0)
+		injectEvent(&events, gin.KeyA, 1, gin.DeviceTypeKeyboard, 1, 5)
+		input.Think(10, true, events)
+		c.Expect(am.Value("move_x"), Equals, -1.0)
+		c.Expect(am.Pressed("move_x"), Equals, true)
+
+		events = events[0:0]
+		injectEvent(&events, gin.KeyD, 1, gin.DeviceTypeKeyboard, 1, 15)
+		input.Think(20, true, events)
+		c.Expect(am.Value("move_x"), Equals, 0.0)
+
+		events = events[0:0]
+		injectEvent(&events, gin.KeyA, 1, gin.DeviceTypeKeyboard, 0, 25)
+		input.Think(30, true, events)
+		c.Expect(am.Value("move_x"), Equals, 1.0)
+	})
+
+	c.Specify("Actions can be rebound at runtime.", func() {
+		am.BindFamily("jump", gin.DeviceId{Type: gin.DeviceTypeController, Index: 2},
+			input.MakeBindingFamily(gin.ControllerButton0, nil, nil))
+		events := make([]gin.OsEvent, 0)
+		injectEvent(&events, gin.Space, 1, gin.DeviceTypeKeyboard, 1, 5)
+		input.Think(10, true, events)
+		c.Expect(am.Pressed("jump"), Equals, false)
+
+		events = events[0:0]
+		injectEvent(&events, gin.ControllerButton0, 1, gin.DeviceTypeController, 1, 15)
+		input.Think(20, true, events)
+		c.Expect(am.Pressed("jump"), Equals, false)
+
+		events = events[0:0]
+		injectEvent(&events, gin.ControllerButton0, 2, gin.DeviceTypeController, 1, 25)
+		input.Think(30, true, events)
+		c.Expect(am.Pressed("jump"), Equals, true)
+
+		positive, negative := am.Bindings("jump")
+		c.Expect(len(positive), Equals, 1)
+		c.Expect(len(negative), Equals, 0)
+	})
+
+	c.Specify("Unbound actions are never down.", func() {
+		am.Unbind("jump")
+		events := make([]gin.OsEvent, 0)
+		injectEvent(&events, gin.Space, 1, gin.DeviceTypeKeyboard, 1, 5)
+		input.Think(10, true, events)
+		c.Expect(am.Pressed("jump"), Equals, false)
+		c.Expect(am.Key("jump") == nil, Equals, true)
+		c.Expect(am.Actions(), ContainsInOrder, []string{"fire", "jump", "move_x"})
+	})
+}
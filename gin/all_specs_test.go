@@ -19,5 +19,23 @@ func TestAllSpecs(t *testing.T) {
 	r.AddSpec(AxisSpec)
 	r.AddSpec(EventListenerSpec)
 	r.AddSpec(FocusSpec)
+	r.AddSpec(RecordSpec)
+	r.AddSpec(ActionMapSpec)
+	r.AddSpec(BindingConfigSpec)
+	r.AddSpec(UnbindSpec)
+	r.AddSpec(SequenceKeySpec)
+	r.AddSpec(MultiTapKeySpec)
+	r.AddSpec(HoldKeySpec)
+	r.AddSpec(AxisResponseSpec)
+	r.AddSpec(AxisProcessingSpec)
+	r.AddSpec(InputContextSpec)
+	r.AddSpec(SnapshotSpec)
+	r.AddSpec(GamepadMappingSpec)
+	r.AddSpec(GamepadInputSpec)
+	r.AddSpec(MouseDeltaSpec)
+	r.AddSpec(TouchSpec)
+	r.AddSpec(GestureSpec)
+	r.AddSpec(PacketSpec)
+	r.AddSpec(LockstepSpec)
 	gospec.MainGoTest(r, t)
 }
@@ -0,0 +1,224 @@
+package gin
+
+import (
+	"math"
+)
+
+// Controller axes are reported as two keys per axis, ControllerAxis0Positive+N
+// and ControllerAxis0Negative+N.  If an axis has been configured with
+// SetAxisConfig() or SetStickConfig() then every event for either of its keys
+// is treated as the signed value of the whole axis, it is run through the
+// axis' processing stage, and events are generated for both of its keys.
+// Events for axes that have not been configured are passed through untouched.
+
+// An AxisResponse describes how a raw axis magnitude in [0, 1] is mapped to the
+// magnitude that is reported.  Magnitudes at or below InnerDeadzone are
+// reported as 0, magnitudes at or above 1 - OuterDeadzone are reported as 1,
+// and everything in between is rescaled linearly to [0, 1] and then passed
+// through the response curve.  If Curve is set it is the response curve,
+// otherwise the response curve is x^Exponent, or linear if Exponent is 0.
+type AxisResponse struct {
+	InnerDeadzone float64
+	OuterDeadzone float64
+	Exponent      float64
+	Curve         func(float64) float64
+}
+
+// Apply returns the processed magnitude for the raw magnitude m.
+func (r AxisResponse) Apply(m float64) float64 {
+	m = math.Abs(m)
+	outer := 1 - r.OuterDeadzone
+	if m <= r.InnerDeadzone {
+		return 0
+	}
+	if m >= outer {
+		m = 1
+	} else {
+		m = (m - r.InnerDeadzone) / (outer - r.InnerDeadzone)
+	}
+	switch {
+	case r.Curve != nil:
+		m = r.Curve(m)
+	case r.Exponent != 0:
+		m = math.Pow(m, r.Exponent)
+	}
+	return math.Max(0, math.Min(1, m))
+}
+
+// AxisConfig configures processing for a single axis.
+type AxisConfig struct {
+	AxisResponse
+	Invert bool
+}
+
+// StickConfig configures processing for a pair of axes that make up a single
+// analog stick.  The deadzones and response curve are radial, they are applied
+// to the stick's distance from its center rather than to each axis separately,
+// so the stick's direction is preserved.
+type StickConfig struct {
+	AxisResponse
+	InvertX, InvertY bool
+}
+
+type axisId struct {
+	device DeviceId
+	axis   int
+}
+
+type stick struct {
+	config StickConfig
+	x, y   int
+}
+
+// SetAxisConfig sets the processing for the specified axis on the specified
+// device.  If device.Index is DeviceIndexAny the config applies to every device
+// of that type that does not have its own config.
+func (input *Input) SetAxisConfig(device DeviceId, axis int, config AxisConfig) {
+	input.mutex.Lock()
+	defer input.mutex.Unlock()
+	id := axisId{device, axis}
+	input.clearStick(id)
+	input.axis_configs[id] = config
+}
+
+// SetStickConfig pairs the x and y axes on the specified device into a single
+// stick and sets its processing.  If device.Index is DeviceIndexAny the config
+// applies to every device of that type that does not have its own config.
+func (input *Input) SetStickConfig(device DeviceId, x, y int, config StickConfig) {
+	input.mutex.Lock()
+	defer input.mutex.Unlock()
+	if x == y {
+		panic("Cannot make a stick out of a single axis.")
+	}
+	input.clearStick(axisId{device, x})
+	input.clearStick(axisId{device, y})
+	delete(input.axis_configs, axisId{device, x})
+	delete(input.axis_configs, axisId{device, y})
+	s := &stick{config: config, x: x, y: y}
+	input.sticks[axisId{device, x}] = s
+	input.sticks[axisId{device, y}] = s
+}
+
+// ClearAxisConfig removes any processing from the specified axis, including
+// removing it from a stick if it is part of one.
+func (input *Input) ClearAxisConfig(device DeviceId, axis int) {
+	input.mutex.Lock()
+	defer input.mutex.Unlock()
+	id := axisId{device, axis}
+	input.clearStick(id)
+	delete(input.axis_configs, id)
+}
+
+func (input *Input) clearStick(id axisId) {
+	if s, ok := input.sticks[id]; ok {
+		delete(input.sticks, axisId{id.device, s.x})
+		delete(input.sticks, axisId{id.device, s.y})
+	}
+}
+
+// splitAxisIndex returns the axis number of a controller axis key index, and
+// whether it is the positive or negative key for that axis.
+func splitAxisIndex(index KeyIndex) (axis int, positive bool, ok bool) {
+	switch {
+	case index >= ControllerAxis0Positive && index < ControllerAxis0Negative:
+		return int(index - ControllerAxis0Positive), true, true
+	case index >= ControllerAxis0Negative && index < ControllerHatSwitchUp:
+		return int(index - ControllerAxis0Negative), false, true
+	}
+	return 0, false, false
+}
+
+// axisEvents returns the events for both keys of an axis whose signed value is v.
+func axisEvents(device DeviceId, axis int, v float64, timestamp int64) []OsEvent {
+	pos := OsEvent{
+		KeyId:     KeyId{Index: ControllerAxis0Positive + KeyIndex(axis), Device: device},
+		Press_amt: math.Max(v, 0),
+		Timestamp: timestamp,
+	}
+	neg := OsEvent{
+		KeyId:     KeyId{Index: ControllerAxis0Negative + KeyIndex(axis), Device: device},
+		Press_amt: math.Max(-v, 0),
+		Timestamp: timestamp,
+	}
+	// Send the release before the press so that the axis is never down in both
+	// directions at once.
+	if v < 0 {
+		return []OsEvent{pos, neg}
+	}
+	return []OsEvent{neg, pos}
+}
+
+// processAxisEvent runs an OsEvent through the axis processing stage and
+// returns the events that should be applied in its place.
+func (input *Input) processAxisEvent(event OsEvent) []OsEvent {
+	axis, positive, ok := splitAxisIndex(event.KeyId.Index)
+	if !ok {
+		return []OsEvent{event}
+	}
+	device := event.KeyId.Device
+	any_device := DeviceId{Type: device.Type, Index: DeviceIndexAny}
+	raw := event.Press_amt
+	if !positive {
+		raw = -raw
+	}
+	// The translators send both keys of an axis every time it moves, releasing
+	// the other half first.  That release doesn't mean the axis passed through
+	// the center, so it mustn't release the half that is still pressed.
+	current := input.axis_raw[axisId{device, axis}]
+	other_half := event.Press_amt == 0 && current != 0 && (current > 0) != positive
+	if !other_half {
+		input.axis_raw[axisId{device, axis}] = raw
+	}
+
+	var processed []OsEvent
+	if s, ok := input.sticks[axisId{device, axis}]; ok {
+		processed = input.processStick(device, s, event.Timestamp)
+	} else if config, ok := input.axis_configs[axisId{device, axis}]; ok {
+		processed = axisEvents(device, axis, config.apply(raw), event.Timestamp)
+	} else if s, ok := input.sticks[axisId{any_device, axis}]; ok {
+		processed = input.processStick(device, s, event.Timestamp)
+	} else if config, ok := input.axis_configs[axisId{any_device, axis}]; ok {
+		processed = axisEvents(device, axis, config.apply(raw), event.Timestamp)
+	} else {
+		return []OsEvent{event}
+	}
+	if other_half {
+		return nil
+	}
+
+	// Drop anything that doesn't change a key, otherwise a drifting stick would
+	// still generate a constant stream of events.
+	var changed []OsEvent
+	for _, e := range processed {
+		if input.getKey(e.KeyId).CurPressAmt() != e.Press_amt {
+			changed = append(changed, e)
+		}
+	}
+	return changed
+}
+
+func (c AxisConfig) apply(v float64) float64 {
+	out := c.Apply(v)
+	if (v < 0) != c.Invert {
+		out = -out
+	}
+	return out
+}
+
+func (input *Input) processStick(device DeviceId, s *stick, timestamp int64) []OsEvent {
+	x := input.axis_raw[axisId{device, s.x}]
+	y := input.axis_raw[axisId{device, s.y}]
+	m := math.Hypot(x, y)
+	if m > 0 {
+		scale := s.config.Apply(math.Min(m, 1)) / m
+		x *= scale
+		y *= scale
+	}
+	if s.config.InvertX {
+		x = -x
+	}
+	if s.config.InvertY {
+		y = -y
+	}
+	return append(axisEvents(device, s.x, x, timestamp), axisEvents(device, s.y, y, timestamp)...)
+}
@@ -0,0 +1,155 @@
+package gin_test
+
+import (
+	"github.com/orfjackal/gospec/src/gospec"
+	. "github.com/orfjackal/gospec/src/gospec"
+	"github.com/runningwild/glop/gin"
+)
+
+func AxisResponseSpec(c gospec.Context) {
+	c.Specify("Deadzones clamp and rescale magnitudes.", func() {
+		r := gin.AxisResponse{InnerDeadzone: 0.25, OuterDeadzone: 0.25}
+		c.Expect(r.Apply(0.1), Equals, 0.0)
+		c.Expect(r.Apply(0.25), Equals, 0.0)
+		c.Expect(r.Apply(0.5), Equals, 0.5)
+		c.Expect(r.Apply(0.8), Equals, 1.0)
+		c.Expect(r.Apply(-0.5), Equals, 0.5)
+	})
+	c.Specify("Response curves are applied after rescaling.", func() {
+		r := gin.AxisResponse{Exponent: 2}
+		c.Expect(r.Apply(0.5), Equals, 0.25)
+		r = gin.AxisResponse{Curve: func(x float64) float64 { return 2 * x }}
+		c.Expect(r.Apply(0.25), Equals, 0.5)
+		c.Expect(r.Apply(0.75), Equals, 1.0)
+	})
+}
+
+func AxisProcessingSpec(c gospec.Context) {
+	input := gin.Make()
+	pad := gin.DeviceId{Type: gin.DeviceTypeController, Index: 1}
+	pos0 := input.GetKeyFlat(gin.ControllerAxis0Positive, gin.DeviceTypeController, 1)
+	neg0 := input.GetKeyFlat(gin.ControllerAxis0Negative, gin.DeviceTypeController, 1)
+	pos1 := input.GetKeyFlat(gin.ControllerAxis0Positive+1, gin.DeviceTypeController, 1)
+	neg1 := input.GetKeyFlat(gin.ControllerAxis0Negative+1, gin.DeviceTypeController, 1)
+
+	c.Specify("Unconfigured axes are untouched.", func() {
+		events := make([]gin.OsEvent, 0)
+		injectEvent(&events, gin.ControllerAxis0Positive, 1, gin.DeviceTypeController, 0.05, 5)
+		input.Think(10, true, events)
+		c.Expect(pos0.CurPressAmt(), Equals, 0.05)
+		c.Expect(pos0.FramePressCount(), Equals, 1)
+	})
+
+	c.Specify("Drift inside the deadzone generates no events.", func() {
+		input.SetAxisConfig(gin.DeviceId{Type: gin.DeviceTypeController, Index: gin.DeviceIndexAny}, 0,
+			gin.AxisConfig{AxisResponse: gin.AxisResponse{InnerDeadzone: 0.1}})
+		events := make([]gin.OsEvent, 0)
+		injectEvent(&events, gin.ControllerAxis0Positive, 1, gin.DeviceTypeController, 0.05, 5)
+		injectEvent(&events, gin.ControllerAxis0Negative, 1, gin.DeviceTypeController, 0.07, 6)
+		injectEvent(&events, gin.ControllerAxis0Positive, 1, gin.DeviceTypeController, 0.02, 7)
+		groups := input.Think(10, true, events)
+		c.Expect(len(groups), Equals, 0)
+		c.Expect(pos0.IsDown(), Equals, false)
+		c.Expect(neg0.IsDown(), Equals, false)
+	})
+
+	c.Specify("Moving across the center releases the other direction.", func() {
+		input.SetAxisConfig(pad, 0, gin.AxisConfig{})
+		events := make([]gin.OsEvent, 0)
+		injectEvent(&events, gin.ControllerAxis0Negative, 1, gin.DeviceTypeController, 0.5, 5)
+		input.Think(10, true, events)
+		c.Expect(neg0.CurPressAmt(), Equals, 0.5)
+		events = events[0:0]
+		injectEvent(&events, gin.ControllerAxis0Positive, 1, gin.DeviceTypeController, 0.25, 15)
+		input.Think(20, true, events)
+		c.Expect(neg0.IsDown(), Equals, false)
+		c.Expect(neg0.FrameReleaseCount(), Equals, 1)
+		c.Expect(pos0.CurPressAmt(), Equals, 0.25)
+	})
+
+	c.Specify("The other half's release doesn't move an axis through the center.", func() {
+		// This is the order the translators send things in, the release of the
+		// other half first and then the new value.
+		input.SetAxisConfig(pad, 0, gin.AxisConfig{})
+		events := make([]gin.OsEvent, 0)
+		injectEvent(&events, gin.ControllerAxis0Negative, 1, gin.DeviceTypeController, 0, 5)
+		injectEvent(&events, gin.ControllerAxis0Positive, 1, gin.DeviceTypeController, 0.5, 5)
+		input.Think(10, true, events)
+		c.Expect(pos0.CurPressAmt(), Equals, 0.5)
+
+		events = events[0:0]
+		injectEvent(&events, gin.ControllerAxis0Negative, 1, gin.DeviceTypeController, 0, 15)
+		injectEvent(&events, gin.ControllerAxis0Positive, 1, gin.DeviceTypeController, 0.6, 15)
+		input.Think(20, true, events)
+		c.Expect(pos0.CurPressAmt(), Equals, 0.6)
+		c.Expect(pos0.FrameReleaseCount(), Equals, 0)
+		c.Expect(neg0.IsDown(), Equals, false)
+
+		events = events[0:0]
+		injectEvent(&events, gin.ControllerAxis0Positive, 1, gin.DeviceTypeController, 0, 25)
+		injectEvent(&events, gin.ControllerAxis0Negative, 1, gin.DeviceTypeController, 0.5, 25)
+		input.Think(30, true, events)
+		c.Expect(pos0.IsDown(), Equals, false)
+		c.Expect(pos0.FrameReleaseCount(), Equals, 1)
+		c.Expect(neg0.CurPressAmt(), Equals, 0.5)
+	})
+
+	c.Specify("The other half's release doesn't move a stick through the center.", func() {
+		input.SetStickConfig(pad, 0, 1, gin.StickConfig{})
+		events := make([]gin.OsEvent, 0)
+		injectEvent(&events, gin.ControllerAxis0Negative, 1, gin.DeviceTypeController, 0, 5)
+		injectEvent(&events, gin.ControllerAxis0Positive, 1, gin.DeviceTypeController, 0.5, 5)
+		injectEvent(&events, gin.ControllerAxis0Negative+1, 1, gin.DeviceTypeController, 0, 5)
+		injectEvent(&events, gin.ControllerAxis0Positive+1, 1, gin.DeviceTypeController, 0.5, 5)
+		input.Think(10, true, events)
+		c.Expect(pos0.CurPressAmt(), IsWithin(1e-9), 0.5)
+		c.Expect(pos1.CurPressAmt(), IsWithin(1e-9), 0.5)
+
+		events = events[0:0]
+		injectEvent(&events, gin.ControllerAxis0Negative, 1, gin.DeviceTypeController, 0, 15)
+		injectEvent(&events, gin.ControllerAxis0Positive, 1, gin.DeviceTypeController, 0.6, 15)
+		input.Think(20, true, events)
+		c.Expect(pos0.CurPressAmt(), IsWithin(1e-9), 0.6)
+		c.Expect(pos0.FrameReleaseCount(), Equals, 0)
+		c.Expect(pos1.CurPressAmt(), IsWithin(1e-9), 0.5)
+		c.Expect(pos1.FrameReleaseCount(), Equals, 0)
+		c.Expect(neg1.IsDown(), Equals, false)
+	})
+
+	c.Specify("Inverted axes swap directions.", func() {
+		input.SetAxisConfig(pad, 0, gin.AxisConfig{Invert: true})
+		events := make([]gin.OsEvent, 0)
+		injectEvent(&events, gin.ControllerAxis0Positive, 1, gin.DeviceTypeController, 0.5, 5)
+		input.Think(10, true, events)
+		c.Expect(pos0.IsDown(), Equals, false)
+		c.Expect(neg0.CurPressAmt(), Equals, 0.5)
+	})
+
+	c.Specify("Sticks use a radial deadzone.", func() {
+		input.SetStickConfig(pad, 0, 1, gin.StickConfig{
+			AxisResponse: gin.AxisResponse{InnerDeadzone: 0.25},
+			InvertY:      true,
+		})
+		events := make([]gin.OsEvent, 0)
+		// Each axis is outside of the deadzone on its own, but the stick is not.
+		injectEvent(&events, gin.ControllerAxis0Positive, 1, gin.DeviceTypeController, 0.15, 5)
+		injectEvent(&events, gin.ControllerAxis0Positive+1, 1, gin.DeviceTypeController, 0.2, 6)
+		groups := input.Think(10, true, events)
+		c.Expect(len(groups), Equals, 0)
+
+		// Distance from center is 0.625, rescaled to 0.5.
+		events = events[0:0]
+		injectEvent(&events, gin.ControllerAxis0Positive, 1, gin.DeviceTypeController, 0.375, 15)
+		injectEvent(&events, gin.ControllerAxis0Positive+1, 1, gin.DeviceTypeController, 0.5, 16)
+		input.Think(20, true, events)
+		c.Expect(pos0.CurPressAmt(), IsWithin(1e-9), 0.3)
+		c.Expect(pos1.IsDown(), Equals, false)
+		c.Expect(neg1.CurPressAmt(), IsWithin(1e-9), 0.4)
+
+		input.ClearAxisConfig(pad, 1)
+		events = events[0:0]
+		injectEvent(&events, gin.ControllerAxis0Positive+1, 1, gin.DeviceTypeController, 0.5, 25)
+		input.Think(30, true, events)
+		c.Expect(pos1.CurPressAmt(), Equals, 0.5)
+	})
+}
@@ -0,0 +1,576 @@
+package gin
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// Bindings and binding families can be written as text so that they can be
+// stored in config files.  A binding is written as its modifiers followed by
+// its primary key, all separated by '+', for example "Ctrl+Shift+Key A".  A
+// modifier that must not be down is prefixed with '!', as in "!Shift+Tab".
+//
+// Keys are named using the same names that Key.Name() returns, like
+// "LeftShift", "Key A" or "Button 3", and names are case-insensitive.  A few
+// short aliases are also accepted: "Shift", "Ctrl", "Alt" and "Gui" for the
+// Either* families, and single characters like "A" for the keyboard keys.
+//
+// Keys in a Binding can also specify a device by following the name with '@'
+// and either a device index, a device type, or both, as in "Key A@1",
+// "Key A@keyboard" or "Button 3@controller:2".  If the device type is
+// omitted it is the type of device that the key belongs to, and if the device
+// index is omitted it is DeviceIndexAny.  Binding families never specify
+// devices.
+
+var key_name_aliases = map[string]KeyIndex{
+	"shift":   EitherShift,
+	"ctrl":    EitherControl,
+	"control": EitherControl,
+	"alt":     EitherAlt,
+	"gui":     EitherGui,
+}
+
+// KeyIndexName returns the name of the key or key family with the specified
+// index.  Indexes without a name, like those of derived keys, are written as
+// '#' followed by the index.  That is only meant for display, derived key
+// indexes depend on the order in which keys were bound so they can't be
+// parsed back.
+func (input *Input) KeyIndexName(index KeyIndex) string {
+	input.mutex.Lock()
+	defer input.mutex.Unlock()
+	if name, ok := input.keyIndexName(index); ok {
+		return name
+	}
+	return fmt.Sprintf("#%d", index)
+}
+
+func (input *Input) keyIndexName(index KeyIndex) (string, bool) {
+	if name, ok := input.index_to_name[index]; ok {
+		return name, true
+	}
+	if family, ok := input.index_to_family[index]; ok && family.name != "" {
+		return family.name, true
+	}
+	return "", false
+}
+
+// savableKeyIndexName is keyIndexName() for the text format, which can only
+// refer to keys by name.
+func (input *Input) savableKeyIndexName(index KeyIndex) (string, error) {
+	if name, ok := input.keyIndexName(index); ok {
+		return name, nil
+	}
+	return "", fmt.Errorf("Key index %d has no name, so it can't be written in a binding.", index)
+}
+
+// ParseKeyIndex returns the index of the key or key family with the specified
+// name, along with the type of device that key belongs to.  Unlike
+// GetKeyByName() this works for keys that have never been created.
+func (input *Input) ParseKeyIndex(name string) (KeyIndex, DeviceType, error) {
+	input.mutex.Lock()
+	defer input.mutex.Unlock()
+	index, ok := input.lookupKeyName(strings.TrimSpace(name))
+	if !ok {
+		return 0, DeviceTypeAny, fmt.Errorf("Unknown key name '%s'.", name)
+	}
+	return index, input.indexDeviceType(index), nil
+}
+
+func (input *Input) lookupKeyName(name string) (KeyIndex, bool) {
+	lower := strings.ToLower(name)
+	if index, ok := input.name_to_index[lower]; ok {
+		return index, true
+	}
+	if index, ok := key_name_aliases[lower]; ok {
+		return index, true
+	}
+	if len(lower) == 1 {
+		if index, ok := input.name_to_index["key "+lower]; ok {
+			return index, true
+		}
+	}
+	return 0, false
+}
+
+// indexDeviceType returns the type of device that a key index belongs to.
+// Families belong to the same type of device as their primary keys.
+func (input *Input) indexDeviceType(index KeyIndex) DeviceType {
+	if family, ok := input.index_to_family[index]; ok {
+		if len(family.binding_families) == 0 {
+			return DeviceTypeAny
+		}
+		return input.indexDeviceType(family.binding_families[0].PrimaryIndex)
+	}
+	switch {
+	case index == AnyKey:
+		return DeviceTypeAny
+	case index < MouseXAxis:
+		return DeviceTypeKeyboard
+	case index < ControllerButton0:
+		return DeviceTypeMouse
+	case isTouchContact(index):
+		return DeviceTypeTouch
+	case index < EitherShift:
+		return DeviceTypeController
+	}
+	return DeviceTypeDerived
+}
+
+func parseDeviceType(name string) (DeviceType, bool) {
+	for dt := DeviceTypeAny; dt < DeviceTypeMax; dt++ {
+		if strings.EqualFold(dt.String(), name) {
+			return dt, true
+		}
+	}
+	return DeviceTypeAny, false
+}
+
+type bindingToken struct {
+	index  KeyIndex
+	device *DeviceId // nil if no device was specified
+	down   bool
+}
+
+// parseBindingTokens splits s into its '+' separated keys.  Some key names
+// contain '+', like "Axis0+", so the longest key name that fits is used.
+func (input *Input) parseBindingTokens(s string) ([]bindingToken, error) {
+	var tokens []bindingToken
+	rest := strings.TrimSpace(s)
+	if rest == "" {
+		return nil, fmt.Errorf("Cannot parse an empty binding.")
+	}
+	for {
+		token := bindingToken{down: true}
+		if strings.HasPrefix(rest, "!") {
+			token.down = false
+			rest = strings.TrimSpace(rest[1:])
+		}
+
+		// Find the longest name that is followed by the end of the string, a '+'
+		// or a '@'.
+		length := -1
+		for i := len(rest); i > 0; i-- {
+			if i < len(rest) && rest[i] != '+' && rest[i] != '@' {
+				continue
+			}
+			if index, ok := input.lookupKeyName(strings.TrimSpace(rest[0:i])); ok {
+				token.index = index
+				length = i
+				break
+			}
+		}
+		if length == -1 {
+			name := rest
+			if n := strings.IndexAny(rest, "+@"); n > 0 {
+				name = rest[0:n]
+			}
+			return nil, fmt.Errorf("Unknown key name '%s' in binding '%s'.", strings.TrimSpace(name), s)
+		}
+		rest = rest[length:]
+
+		if strings.HasPrefix(rest, "@") {
+			end := strings.Index(rest, "+")
+			if end == -1 {
+				end = len(rest)
+			}
+			device, err := input.parseDevice(token.index, strings.TrimSpace(rest[1:end]))
+			if err != nil {
+				return nil, fmt.Errorf("%v in binding '%s'", err, s)
+			}
+			token.device = &device
+			rest = rest[end:]
+		}
+		tokens = append(tokens, token)
+
+		if rest == "" {
+			break
+		}
+		rest = strings.TrimSpace(rest[1:])
+		if rest == "" {
+			return nil, fmt.Errorf("Binding '%s' ends with '+'.", s)
+		}
+	}
+	return tokens, nil
+}
+
+func (input *Input) parseDevice(index KeyIndex, s string) (DeviceId, error) {
+	device := DeviceId{Type: input.indexDeviceType(index), Index: DeviceIndexAny}
+	type_str, index_str := s, ""
+	if n := strings.Index(s, ":"); n != -1 {
+		type_str, index_str = s[0:n], s[n+1:]
+	} else if _, err := strconv.Atoi(s); err == nil {
+		type_str, index_str = "", s
+	}
+	if type_str != "" {
+		dt, ok := parseDeviceType(type_str)
+		if !ok {
+			return device, fmt.Errorf("Unknown device type '%s'", type_str)
+		}
+		device.Type = dt
+	}
+	if index_str != "" {
+		n, err := strconv.Atoi(index_str)
+		if err != nil {
+			return device, fmt.Errorf("Invalid device index '%s'", index_str)
+		}
+		device.Index = DeviceIndex(n)
+	}
+	if device.Type == DeviceTypeAny && device.Index != DeviceIndexAny {
+		return device, fmt.Errorf("Cannot specify a device index without a device type")
+	}
+	return device, nil
+}
+
+func (input *Input) formatKeyId(id KeyId) (string, error) {
+	name, err := input.savableKeyIndexName(id.Index)
+	if err != nil {
+		return "", err
+	}
+	implied := input.indexDeviceType(id.Index)
+	switch {
+	case id.Device.Type == implied && id.Device.Index == DeviceIndexAny:
+		return name, nil
+	case id.Device.Type == implied:
+		return fmt.Sprintf("%s@%d", name, id.Device.Index), nil
+	case id.Device.Index == DeviceIndexAny:
+		return fmt.Sprintf("%s@%v", name, id.Device.Type), nil
+	}
+	return fmt.Sprintf("%s@%v:%d", name, id.Device.Type, id.Device.Index), nil
+}
+
+// ParseBinding parses a Binding from the text format described above.
+func (input *Input) ParseBinding(s string) (Binding, error) {
+	input.mutex.Lock()
+	defer input.mutex.Unlock()
+	return input.parseBinding(s)
+}
+
+func (input *Input) parseBinding(s string) (Binding, error) {
+	tokens, err := input.parseBindingTokens(s)
+	if err != nil {
+		return Binding{}, err
+	}
+	ids := make([]KeyId, len(tokens))
+	for i, token := range tokens {
+		if token.device != nil {
+			ids[i] = KeyId{Index: token.index, Device: *token.device}
+		} else {
+			ids[i] = KeyId{
+				Index:  token.index,
+				Device: DeviceId{Type: input.indexDeviceType(token.index), Index: DeviceIndexAny},
+			}
+		}
+	}
+	primary := len(tokens) - 1
+	if !tokens[primary].down {
+		return Binding{}, fmt.Errorf("The primary key in binding '%s' cannot be negated.", s)
+	}
+	var down []bool
+	for _, token := range tokens[0:primary] {
+		down = append(down, token.down)
+	}
+	return input.MakeBinding(ids[primary], ids[0:primary], down), nil
+}
+
+// FormatBinding writes a Binding in the text format described above.  It
+// returns an error if the binding uses a key without a name, like an unnamed
+// derived key.
+func (input *Input) FormatBinding(b Binding) (string, error) {
+	input.mutex.Lock()
+	defer input.mutex.Unlock()
+	return input.formatBinding(b)
+}
+
+func (input *Input) formatBinding(b Binding) (string, error) {
+	var parts []string
+	for i := range b.Modifiers {
+		part, err := input.formatKeyId(b.Modifiers[i])
+		if err != nil {
+			return "", err
+		}
+		if !b.Down[i] {
+			part = "!" + part
+		}
+		parts = append(parts, part)
+	}
+	primary, err := input.formatKeyId(b.PrimaryKey)
+	if err != nil {
+		return "", err
+	}
+	parts = append(parts, primary)
+	return strings.Join(parts, "+"), nil
+}
+
+// ParseBindingFamily parses a BindingFamily from the text format described
+// above.
+func (input *Input) ParseBindingFamily(s string) (BindingFamily, error) {
+	input.mutex.Lock()
+	defer input.mutex.Unlock()
+	return input.parseBindingFamily(s)
+}
+
+func (input *Input) parseBindingFamily(s string) (BindingFamily, error) {
+	tokens, err := input.parseBindingTokens(s)
+	if err != nil {
+		return BindingFamily{}, err
+	}
+	for _, token := range tokens {
+		if token.device != nil {
+			return BindingFamily{}, fmt.Errorf("Binding family '%s' cannot specify a device.", s)
+		}
+	}
+	primary := len(tokens) - 1
+	if !tokens[primary].down {
+		return BindingFamily{}, fmt.Errorf("The primary key in binding family '%s' cannot be negated.", s)
+	}
+	var modifiers []KeyIndex
+	var down []bool
+	for _, token := range tokens[0:primary] {
+		modifiers = append(modifiers, token.index)
+		down = append(down, token.down)
+	}
+	return input.MakeBindingFamily(tokens[primary].index, modifiers, down), nil
+}
+
+// FormatBindingFamily writes a BindingFamily in the text format described
+// above.  Like FormatBinding() it returns an error if a key has no name.
+func (input *Input) FormatBindingFamily(bf BindingFamily) (string, error) {
+	input.mutex.Lock()
+	defer input.mutex.Unlock()
+	return input.formatBindingFamily(bf)
+}
+
+func (input *Input) formatBindingFamily(bf BindingFamily) (string, error) {
+	var parts []string
+	for i := range bf.Modifiers {
+		part, err := input.savableKeyIndexName(bf.Modifiers[i])
+		if err != nil {
+			return "", err
+		}
+		if !bf.Down[i] {
+			part = "!" + part
+		}
+		parts = append(parts, part)
+	}
+	primary, err := input.savableKeyIndexName(bf.PrimaryIndex)
+	if err != nil {
+		return "", err
+	}
+	parts = append(parts, primary)
+	return strings.Join(parts, "+"), nil
+}
+
+// A DerivedKeyDef describes a derived key in a form that can be stored as
+// JSON.  Each binding is in the text format described above.
+type DerivedKeyDef struct {
+	Name     string
+	Bindings []string
+}
+
+// A DerivedKeyFamilyDef describes a derived key family in a form that can be
+// stored as JSON.  Each binding family is in the text format described above.
+type DerivedKeyFamilyDef struct {
+	Name     string
+	Families []string
+}
+
+// A BindingConfig is a set of derived key and derived key family definitions,
+// typically read from a per-user controls file.
+type BindingConfig struct {
+	Keys     []DerivedKeyDef       `json:",omitempty"`
+	Families []DerivedKeyFamilyDef `json:",omitempty"`
+}
+
+func ReadBindingConfig(r io.Reader) (BindingConfig, error) {
+	var config BindingConfig
+	err := json.NewDecoder(r).Decode(&config)
+	return config, err
+}
+
+func WriteBindingConfig(w io.Writer, config BindingConfig) error {
+	data, err := json.MarshalIndent(config, "", "  ")
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(append(data, '\n'))
+	return err
+}
+
+// DescribeDerivedKey returns a DerivedKeyDef that will recreate key, which must
+// have been created with BindDerivedKey().
+func (input *Input) DescribeDerivedKey(key Key) (DerivedKeyDef, error) {
+	input.mutex.Lock()
+	defer input.mutex.Unlock()
+	dk, ok := key.(*derivedKey)
+	if !ok {
+		return DerivedKeyDef{}, fmt.Errorf("Key '%s' is not a derived key.", key.Name())
+	}
+	if dk.name == "" {
+		return DerivedKeyDef{}, fmt.Errorf("Derived key %d has no name, so it can't be described.", dk.id.Index)
+	}
+	def := DerivedKeyDef{Name: dk.name}
+	for _, binding := range dk.Bindings {
+		s, err := input.formatBinding(binding)
+		if err != nil {
+			return DerivedKeyDef{}, err
+		}
+		def.Bindings = append(def.Bindings, s)
+	}
+	return def, nil
+}
+
+// DescribeDerivedKeyFamily returns a DerivedKeyFamilyDef that will recreate
+// the family with the specified index.
+func (input *Input) DescribeDerivedKeyFamily(index KeyIndex) (DerivedKeyFamilyDef, error) {
+	input.mutex.Lock()
+	defer input.mutex.Unlock()
+	family, ok := input.index_to_family[index]
+	if !ok {
+		return DerivedKeyFamilyDef{}, fmt.Errorf("Key index %d is not a derived key family.", index)
+	}
+	if family.name == "" {
+		return DerivedKeyFamilyDef{}, fmt.Errorf("Derived key family %d has no name, so it can't be described.", index)
+	}
+	def := DerivedKeyFamilyDef{Name: family.name}
+	for _, bf := range family.binding_families {
+		s, err := input.formatBindingFamily(bf)
+		if err != nil {
+			return DerivedKeyFamilyDef{}, err
+		}
+		def.Families = append(def.Families, s)
+	}
+	return def, nil
+}
+
+// BindConfig parses all of the definitions in config and binds them.  The
+// keys and family indexes that were created are returned, keyed by name.
+// Nothing is bound if any of the definitions fail to parse.
+func (input *Input) BindConfig(config BindingConfig) (map[string]Key, map[string]KeyIndex, error) {
+	input.mutex.Lock()
+	defer input.mutex.Unlock()
+	key_bindings := make([][]Binding, len(config.Keys))
+	for i, def := range config.Keys {
+		for _, s := range def.Bindings {
+			binding, err := input.parseBinding(s)
+			if err != nil {
+				return nil, nil, err
+			}
+			key_bindings[i] = append(key_bindings[i], binding)
+		}
+	}
+	family_bindings := make([][]BindingFamily, len(config.Families))
+	for i, def := range config.Families {
+		for _, s := range def.Families {
+			bf, err := input.parseBindingFamily(s)
+			if err != nil {
+				return nil, nil, err
+			}
+			family_bindings[i] = append(family_bindings[i], bf)
+		}
+	}
+
+	keys := make(map[string]Key)
+	for i, def := range config.Keys {
+		keys[def.Name] = input.bindDerivedKeyWithIndex(
+			def.Name,
+			genDerivedKeyIndex(),
+			DeviceId{Index: 1, Type: DeviceTypeDerived},
+			key_bindings[i]...)
+	}
+	families := make(map[string]KeyIndex)
+	for i, def := range config.Families {
+		families[def.Name] = input.bindDerivedKeyFamilyWithIndex(def.Name, genDerivedKeyIndex(), family_bindings[i]...)
+	}
+	return keys, families, nil
+}
+
+// An ActionDef describes the bindings for a single action in an ActionMap.
+// Each binding is in the text format described above.
+type ActionDef struct {
+	Bindings []string `json:",omitempty"`
+	Negative []string `json:",omitempty"`
+}
+
+// Config returns the bindings of all actions in the ActionMap, keyed by action
+// name, in a form that can be stored as JSON.  It returns an error if any
+// binding uses a key without a name.
+func (am *ActionMap) Config() (map[string]ActionDef, error) {
+	config := make(map[string]ActionDef)
+	for name, a := range am.actions {
+		var def ActionDef
+		for _, binding := range a.bindings {
+			s, err := am.input.FormatBinding(binding)
+			if err != nil {
+				return nil, fmt.Errorf("Action '%s': %v", name, err)
+			}
+			def.Bindings = append(def.Bindings, s)
+		}
+		for _, binding := range a.neg_bindings {
+			s, err := am.input.FormatBinding(binding)
+			if err != nil {
+				return nil, fmt.Errorf("Action '%s': %v", name, err)
+			}
+			def.Negative = append(def.Negative, s)
+		}
+		config[name] = def
+	}
+	return config, nil
+}
+
+// ApplyConfig rebinds every action named in config.  Actions not mentioned in
+// config are left alone.  Nothing is rebound if any of the bindings fail to
+// parse.
+func (am *ActionMap) ApplyConfig(config map[string]ActionDef) error {
+	type parsed struct {
+		positive, negative []Binding
+	}
+	all := make(map[string]parsed)
+	for name, def := range config {
+		var p parsed
+		for _, s := range def.Bindings {
+			binding, err := am.input.ParseBinding(s)
+			if err != nil {
+				return fmt.Errorf("Action '%s': %v", name, err)
+			}
+			p.positive = append(p.positive, binding)
+		}
+		for _, s := range def.Negative {
+			binding, err := am.input.ParseBinding(s)
+			if err != nil {
+				return fmt.Errorf("Action '%s': %v", name, err)
+			}
+			p.negative = append(p.negative, binding)
+		}
+		all[name] = p
+	}
+	for name, p := range all {
+		am.BindAxis(name, p.positive, p.negative)
+	}
+	return nil
+}
+
+// Save writes the bindings of all actions as JSON.
+func (am *ActionMap) Save(w io.Writer) error {
+	config, err := am.Config()
+	if err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(config, "", "  ")
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(append(data, '\n'))
+	return err
+}
+
+// Load reads bindings written by Save() and applies them.
+func (am *ActionMap) Load(r io.Reader) error {
+	var config map[string]ActionDef
+	if err := json.NewDecoder(r).Decode(&config); err != nil {
+		return err
+	}
+	return am.ApplyConfig(config)
+}
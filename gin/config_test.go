@@ -0,0 +1,166 @@
+package gin_test
+
+import (
+	"bytes"
+	"github.com/orfjackal/gospec/src/gospec"
+	. "github.com/orfjackal/gospec/src/gospec"
+	"github.com/runningwild/glop/gin"
+)
+
+func formatBinding(input *gin.Input, binding gin.Binding) string {
+	s, err := input.FormatBinding(binding)
+	if err != nil {
+		return err.Error()
+	}
+	return s
+}
+
+func BindingConfigSpec(c gospec.Context) {
+	input := gin.Make()
+	c.Specify("Key names can be parsed.", func() {
+		index, device_type, err := input.ParseKeyIndex("LeftShift")
+		c.Expect(err, Equals, nil)
+		c.Expect(index, Equals, gin.KeyIndex(gin.LeftShift))
+		c.Expect(device_type, Equals, gin.DeviceTypeKeyboard)
+
+		index, device_type, err = input.ParseKeyIndex("button 3")
+		c.Expect(err, Equals, nil)
+		c.Expect(index, Equals, gin.KeyIndex(gin.ControllerButton0+3))
+		c.Expect(device_type, Equals, gin.DeviceTypeController)
+
+		index, device_type, err = input.ParseKeyIndex("Shift")
+		c.Expect(err, Equals, nil)
+		c.Expect(index, Equals, gin.KeyIndex(gin.EitherShift))
+		c.Expect(device_type, Equals, gin.DeviceTypeKeyboard)
+
+		_, _, err = input.ParseKeyIndex("NotAKey")
+		c.Expect(err, Not(Equals), nil)
+	})
+
+	c.Specify("Bindings can be parsed.", func() {
+		binding, err := input.ParseBinding("Ctrl+!Shift+A")
+		c.Assume(err, Equals, nil)
+		c.Expect(binding.PrimaryKey, Equals, gin.AnyKeyA)
+		c.Expect(len(binding.Modifiers), Equals, 2)
+		c.Expect(binding.Modifiers[0].Index, Equals, gin.KeyIndex(gin.EitherControl))
+		c.Expect(binding.Modifiers[1].Index, Equals, gin.KeyIndex(gin.EitherShift))
+		c.Expect(binding.Down, ContainsInOrder, []bool{true, false})
+		c.Expect(formatBinding(input, binding), Equals, "EitherControl+!EitherShift+Key A")
+
+		binding, err = input.ParseBinding("Axis0+@2 + Button 3@controller:2")
+		c.Assume(err, Equals, nil)
+		c.Expect(binding.PrimaryKey, Equals, gin.KeyId{
+			Index:  gin.ControllerButton0 + 3,
+			Device: gin.DeviceId{Type: gin.DeviceTypeController, Index: 2},
+		})
+		c.Expect(binding.Modifiers[0].Index, Equals, gin.KeyIndex(gin.ControllerAxis0Positive))
+		c.Expect(formatBinding(input, binding), Equals, "Axis0+@2+Button 3@2")
+
+		binding, err = input.ParseBinding("Key A@any")
+		c.Assume(err, Equals, nil)
+		c.Expect(formatBinding(input, binding), Equals, "Key A@any")
+
+		for _, bad := range []string{"", "Ctrl+", "Ctrl+Bogus", "!A", "A@wheel", "A@any:3"} {
+			_, err = input.ParseBinding(bad)
+			c.Expect(err, Not(Equals), nil)
+		}
+	})
+
+	c.Specify("Binding families can be parsed.", func() {
+		bf, err := input.ParseBindingFamily("Shift+Tab")
+		c.Assume(err, Equals, nil)
+		c.Expect(bf.PrimaryIndex, Equals, gin.KeyIndex(gin.Tab))
+		c.Expect(bf.Modifiers, ContainsInOrder, []gin.KeyIndex{gin.EitherShift})
+		s, err := input.FormatBindingFamily(bf)
+		c.Expect(err, Equals, nil)
+		c.Expect(s, Equals, "EitherShift+Tab")
+
+		_, err = input.ParseBindingFamily("Shift+Tab@1")
+		c.Expect(err, Not(Equals), nil)
+	})
+
+	c.Specify("Configs create working derived keys.", func() {
+		var buf bytes.Buffer
+		err := gin.WriteBindingConfig(&buf, gin.BindingConfig{
+			Keys: []gin.DerivedKeyDef{
+				{Name: "ShiftA", Bindings: []string{"LeftShift@1+Key A@1"}},
+			},
+			Families: []gin.DerivedKeyFamilyDef{
+				{Name: "Jump", Families: []string{"Space", "Up"}},
+			},
+		})
+		c.Assume(err, Equals, nil)
+		config, err := gin.ReadBindingConfig(&buf)
+		c.Assume(err, Equals, nil)
+		keys, families, err := input.BindConfig(config)
+		c.Assume(err, Equals, nil)
+
+		def, err := input.DescribeDerivedKey(keys["ShiftA"])
+		c.Expect(err, Equals, nil)
+		c.Expect(def.Bindings, ContainsInOrder, []string{"LeftShift@1+Key A@1"})
+		fdef, err := input.DescribeDerivedKeyFamily(families["Jump"])
+		c.Expect(err, Equals, nil)
+		c.Expect(fdef.Families, ContainsInOrder, []string{"Space", "Up"})
+		index, _, err := input.ParseKeyIndex("jump")
+		c.Expect(err, Equals, nil)
+		c.Expect(index, Equals, families["Jump"])
+
+		events := make([]gin.OsEvent, 0)
+		injectEvent(&events, gin.LeftShift, 1, gin.DeviceTypeKeyboard, 1, 3)
+		injectEvent(&events, gin.KeyA, 1, gin.DeviceTypeKeyboard, 1, 5)
+		injectEvent(&events, gin.Up, 3, gin.DeviceTypeKeyboard, 1, 7)
+		input.Think(10, true, events)
+		c.Expect(keys["ShiftA"].FramePressCount(), Equals, 1)
+		c.Expect(input.GetKeyFlat(families["Jump"], gin.DeviceTypeKeyboard, 3).FramePressCount(), Equals, 1)
+	})
+
+	c.Specify("Action maps can be saved and loaded.", func() {
+		am := input.MakeActionMap()
+		binding, _ := input.ParseBinding("Space@1")
+		am.Bind("jump", binding)
+		left, _ := input.ParseBinding("Key A@1")
+		right, _ := input.ParseBinding("Key D@1")
+		am.BindAxis("move_x", []gin.Binding{right}, []gin.Binding{left})
+		var buf bytes.Buffer
+		c.Assume(am.Save(&buf), Equals, nil)
+
+		loaded := input.MakeActionMap()
+		c.Assume(loaded.Load(&buf), Equals, nil)
+		c.Expect(loaded.Actions(), ContainsInOrder, []string{"jump", "move_x"})
+		config, err := loaded.Config()
+		c.Assume(err, Equals, nil)
+		c.Expect(config["jump"].Bindings, ContainsInOrder, []string{"Space@1"})
+		c.Expect(config["move_x"].Negative, ContainsInOrder, []string{"Key A@1"})
+
+		events := make([]gin.OsEvent, 0)
+		injectEvent(&events, gin.KeyA, 1, gin.DeviceTypeKeyboard, 1, 5)
+		input.Think(10, true, events)
+		c.Expect(loaded.Value("move_x"), Equals, -1.0)
+	})
+
+	c.Specify("Unnamed derived keys can't be saved.", func() {
+		space, _ := input.ParseBinding("Space")
+		unnamed := input.BindDerivedKey("", space)
+		_, err := input.DescribeDerivedKey(unnamed)
+		c.Expect(err, Not(Equals), nil)
+
+		// A binding that uses a derived key can only refer to it by index,
+		// which isn't stable between runs.
+		uses_unnamed := input.MakeBinding(gin.KeyId{
+			Index:  unnamed.Id().Index,
+			Device: gin.DeviceId{Type: gin.DeviceTypeDerived, Index: 1},
+		}, nil, nil)
+		_, err = input.FormatBinding(uses_unnamed)
+		c.Expect(err, Not(Equals), nil)
+
+		am := input.MakeActionMap()
+		am.Bind("use", uses_unnamed)
+		_, err = am.Config()
+		c.Expect(err, Not(Equals), nil)
+		var buf bytes.Buffer
+		c.Expect(am.Save(&buf), Not(Equals), nil)
+
+		_, _, err = input.ParseKeyIndex(input.KeyIndexName(unnamed.Id().Index))
+		c.Expect(err, Not(Equals), nil)
+	})
+}
@@ -0,0 +1,209 @@
+package gin
+
+import (
+	"github.com/runningwild/glop/util/algorithm"
+	"sort"
+)
+
+// Input contexts let different parts of an app, like a modal dialog, the
+// gameplay and a debug console, receive input without stepping on each other.
+// Each InputContext has its own listeners and a priority.  Event groups are
+// sent to the contexts that have been pushed onto the Input in order of
+// decreasing priority, contexts with the same priority are visited most
+// recently pushed first.  A listener can stop an event group from going any
+// further by implementing EventConsumer, and a blocking context stops every
+// event group from reaching the contexts below it.
+//
+// Listeners registered directly with Input.RegisterEventListener() belong to
+// the Input's default context, which has priority 0, never blocks, and is
+// always pushed.
+
+// An EventConsumer is a Listener that can consume event groups.  If a
+// Listener implements EventConsumer then ConsumeEventGroup is called instead
+// of HandleEventGroup, and if it returns true the event group is not sent to
+// any listeners after it in the same context, or to any lower contexts.
+//
+// Consuming an event group only affects listeners.  Key state is updated
+// regardless, so an ActionMap tied to a lower context still sees the press.
+// To keep a menu's input out of the gameplay underneath it, push the menu in a
+// blocking context instead.
+type EventConsumer interface {
+	ConsumeEventGroup(EventGroup) bool
+}
+
+type InputContext struct {
+	input     *Input
+	name      string
+	priority  int
+	blocking  bool
+	listeners []Listener
+
+	// Whether or not this context is currently pushed, and if so the order in
+	// which it was pushed relative to all other pushed contexts.
+	pushed bool
+	seq    int
+}
+
+// MakeInputContext creates a context that can be pushed onto this Input.  If
+// blocking is true then while the context is pushed no contexts with a lower
+// priority will receive any events, this is what a modal menu should use so
+// that keypresses don't leak into the gameplay underneath it.
+func (input *Input) MakeInputContext(name string, priority int, blocking bool) *InputContext {
+	return &InputContext{
+		input:    input,
+		name:     name,
+		priority: priority,
+		blocking: blocking,
+	}
+}
+
+func (c *InputContext) Name() string {
+	return c.name
+}
+
+func (c *InputContext) Priority() int {
+	return c.priority
+}
+
+func (c *InputContext) Blocking() bool {
+	return c.blocking
+}
+
+func (c *InputContext) RegisterEventListener(listener Listener) {
+	c.input.mutex.Lock()
+	defer c.input.mutex.Unlock()
+	c.listeners = append(c.listeners, listener)
+}
+
+func (c *InputContext) UnregisterEventListener(listener Listener) {
+	c.input.mutex.Lock()
+	defer c.input.mutex.Unlock()
+	algorithm.Choose(&c.listeners, func(l Listener) bool { return l != listener })
+}
+
+// Active returns true iff this context is pushed and there is no blocking
+// context above it, i.e. if it will receive events that are not consumed.
+func (c *InputContext) Active() bool {
+	c.input.mutex.Lock()
+	defer c.input.mutex.Unlock()
+	if !c.pushed {
+		return false
+	}
+	for _, other := range c.input.contexts {
+		if other == c {
+			return true
+		}
+		if other.blocking {
+			return false
+		}
+	}
+	return false
+}
+
+// PushContext pushes a context onto the Input so that it will start receiving
+// events.  Pushing a context that is already pushed moves it above other
+// contexts with the same priority.
+func (input *Input) PushContext(c *InputContext) {
+	input.mutex.Lock()
+	defer input.mutex.Unlock()
+	if c.input != input {
+		panic("Cannot push an InputContext onto an Input that didn't make it.")
+	}
+	input.removeContext(c)
+	input.context_seq++
+	c.seq = input.context_seq
+	c.pushed = true
+	input.contexts = append(input.contexts, c)
+	sort.Sort(contextSlice(input.contexts))
+}
+
+// PopContext removes a context from the Input.  Popping a context that is not
+// pushed does nothing, and the default context cannot be popped.
+func (input *Input) PopContext(c *InputContext) {
+	input.mutex.Lock()
+	defer input.mutex.Unlock()
+	if c == input.default_context {
+		panic("Cannot pop the default InputContext.")
+	}
+	input.removeContext(c)
+}
+
+func (input *Input) removeContext(c *InputContext) {
+	c.pushed = false
+	algorithm.Choose(&input.contexts, func(other *InputContext) bool { return other != c })
+}
+
+// Contexts returns all pushed contexts in the order that they receive events.
+func (input *Input) Contexts() []*InputContext {
+	input.mutex.Lock()
+	defer input.mutex.Unlock()
+	return append([]*InputContext(nil), input.contexts...)
+}
+
+// DefaultContext returns the context that listeners registered directly with
+// the Input belong to.
+func (input *Input) DefaultContext() *InputContext {
+	return input.default_context
+}
+
+// unlockedDispatch sends group to the active listeners, in order, stopping
+// early if a listener consumes it.  The lock must be held when this is called,
+// and it is released while the listeners are running.
+func (input *Input) unlockedDispatch(group EventGroup) {
+	listeners := input.activeListeners()
+	input.mutex.Unlock()
+	defer input.mutex.Lock()
+	for _, listener := range listeners {
+		if consumer, ok := listener.(EventConsumer); ok {
+			if consumer.ConsumeEventGroup(group) {
+				return
+			}
+		} else {
+			listener.HandleEventGroup(group)
+		}
+	}
+}
+
+// unlockedThink calls Think() on all of the specified listeners with the lock
+// released.
+func (input *Input) unlockedThink(listeners []Listener) {
+	input.mutex.Unlock()
+	defer input.mutex.Lock()
+	for _, listener := range listeners {
+		listener.Think()
+	}
+}
+
+// activeListeners returns, in dispatch order, all listeners in contexts that
+// are not blocked.
+func (input *Input) activeListeners() []Listener {
+	var listeners []Listener
+	for _, c := range input.contexts {
+		listeners = append(listeners, c.listeners...)
+		if c.blocking {
+			break
+		}
+	}
+	return listeners
+}
+
+// allListeners returns all listeners in every pushed context, even the ones
+// that are blocked.  Blocked listeners still Think() every frame.
+func (input *Input) allListeners() []Listener {
+	var listeners []Listener
+	for _, c := range input.contexts {
+		listeners = append(listeners, c.listeners...)
+	}
+	return listeners
+}
+
+type contextSlice []*InputContext
+
+func (cs contextSlice) Len() int      { return len(cs) }
+func (cs contextSlice) Swap(i, j int) { cs[i], cs[j] = cs[j], cs[i] }
+func (cs contextSlice) Less(i, j int) bool {
+	if cs[i].priority != cs[j].priority {
+		return cs[i].priority > cs[j].priority
+	}
+	return cs[i].seq > cs[j].seq
+}
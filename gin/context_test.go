@@ -0,0 +1,114 @@
+package gin_test
+
+import (
+	"github.com/orfjackal/gospec/src/gospec"
+	. "github.com/orfjackal/gospec/src/gospec"
+	"github.com/runningwild/glop/gin"
+)
+
+// A contextListener logs every event group it sees, and consumes groups that
+// contain an event for any of the keys in consume.
+type contextListener struct {
+	name    string
+	log     *[]string
+	consume []gin.KeyId
+	thinks  int
+}
+
+func (l *contextListener) HandleEventGroup(group gin.EventGroup) {
+	*l.log = append(*l.log, l.name)
+}
+
+func (l *contextListener) Think() {
+	l.thinks++
+}
+
+type consumingListener struct {
+	contextListener
+}
+
+func (l *consumingListener) ConsumeEventGroup(group gin.EventGroup) bool {
+	l.HandleEventGroup(group)
+	for _, id := range l.consume {
+		if found, _ := group.FindEvent(id); found {
+			return true
+		}
+	}
+	return false
+}
+
+func InputContextSpec(c gospec.Context) {
+	input := gin.Make()
+	kb1 := gin.DeviceId{Type: gin.DeviceTypeKeyboard, Index: 1}
+	escape := gin.KeyId{Index: gin.Escape, Device: kb1}
+	var log []string
+	game := &contextListener{name: "game", log: &log}
+	input.RegisterEventListener(game)
+	press := func(index gin.KeyIndex, amt float64) {
+		log = nil
+		events := make([]gin.OsEvent, 0)
+		injectEvent(&events, index, 1, gin.DeviceTypeKeyboard, amt, 5)
+		input.Think(10, true, events)
+	}
+
+	c.Specify("Higher priority contexts receive events first.", func() {
+		console := input.MakeInputContext("console", 10, false)
+		console_listener := &contextListener{name: "console", log: &log}
+		console.RegisterEventListener(console_listener)
+		input.PushContext(console)
+		c.Expect(len(input.Contexts()), Equals, 2)
+		c.Expect(input.Contexts()[0], Equals, console)
+		press(gin.KeyA, 1)
+		c.Expect(log, ContainsInOrder, []string{"console", "game"})
+
+		input.PopContext(console)
+		press(gin.KeyA, 0)
+		c.Expect(log, ContainsInOrder, []string{"game"})
+		c.Expect(console.Active(), Equals, false)
+	})
+
+	c.Specify("Contexts with equal priority are visited most recently pushed first.", func() {
+		a := input.MakeInputContext("a", 5, false)
+		b := input.MakeInputContext("b", 5, false)
+		a.RegisterEventListener(&contextListener{name: "a", log: &log})
+		b.RegisterEventListener(&contextListener{name: "b", log: &log})
+		input.PushContext(a)
+		input.PushContext(b)
+		press(gin.KeyA, 1)
+		c.Expect(log, ContainsInOrder, []string{"b", "a", "game"})
+		input.PushContext(a)
+		press(gin.KeyA, 0)
+		c.Expect(log, ContainsInOrder, []string{"a", "b", "game"})
+	})
+
+	c.Specify("Consumed event groups go no further.", func() {
+		menu := input.MakeInputContext("menu", 10, false)
+		menu_listener := &consumingListener{contextListener{name: "menu", log: &log, consume: []gin.KeyId{escape}}}
+		menu.RegisterEventListener(menu_listener)
+		input.PushContext(menu)
+		press(gin.Escape, 1)
+		c.Expect(log, ContainsInOrder, []string{"menu"})
+		press(gin.KeyA, 1)
+		c.Expect(log, ContainsInOrder, []string{"menu", "game"})
+		c.Expect(game.thinks, Equals, 2)
+	})
+
+	c.Specify("Blocking contexts mask everything below them.", func() {
+		menu := input.MakeInputContext("menu", 10, true)
+		menu.RegisterEventListener(&contextListener{name: "menu", log: &log})
+		am := input.MakeActionMap()
+		am.Bind("jump", input.MakeBinding(gin.KeyId{Index: gin.Space, Device: kb1}, nil, nil))
+		am.SetContext(input.DefaultContext())
+		input.PushContext(menu)
+		c.Expect(menu.Active(), Equals, true)
+		c.Expect(input.DefaultContext().Active(), Equals, false)
+		press(gin.Space, 1)
+		c.Expect(log, ContainsInOrder, []string{"menu"})
+		c.Expect(am.Pressed("jump"), Equals, false)
+		c.Expect(am.IsDown("jump"), Equals, false)
+		c.Expect(game.thinks, Equals, 1)
+
+		input.PopContext(menu)
+		c.Expect(am.IsDown("jump"), Equals, true)
+	})
+}
@@ -1,7 +1,15 @@
 package gin
 
+import (
+	"fmt"
+	"github.com/runningwild/glop/util/algorithm"
+	"strings"
+	"sync/atomic"
+)
+
 var (
-	next_derived_key_index KeyIndex
+	// Shared by all Inputs, so it is only modified atomically.
+	next_derived_key_index int64
 )
 
 func init() {
@@ -9,11 +17,9 @@ func init() {
 }
 
 func genDerivedKeyIndex() KeyIndex {
-	next_derived_key_index++
-	return next_derived_key_index
+	return KeyIndex(atomic.AddInt64(&next_derived_key_index, 1))
 }
 
-// TODO: Handle removal of dependencies
 func (input *Input) registerDependence(derived Key, dep KeyId) {
 	list, ok := input.id_to_deps[dep]
 	if !ok {
@@ -27,6 +33,8 @@ func (input *Input) registerDependence(derived Key, dep KeyId) {
 }
 
 func (input *Input) BindDerivedKey(name string, bindings ...Binding) Key {
+	input.mutex.Lock()
+	defer input.mutex.Unlock()
 	return input.bindDerivedKeyWithIndex(
 		name,
 		genDerivedKeyIndex(),
@@ -67,6 +75,90 @@ func (input *Input) bindDerivedKeyWithIndex(name string, index KeyIndex, device
 	return dk
 }
 
+// UnbindDerivedKey removes a key that was created with BindDerivedKey(), or
+// with one of the other Bind*Key() functions, like BindSequenceKey().  The
+// key will not generate any more events, not even a release event if it is
+// currently down, and should not be used after this call.  It is an error to
+// unbind a key that other derived keys depend on.
+func (input *Input) UnbindDerivedKey(key Key) {
+	input.mutex.Lock()
+	defer input.mutex.Unlock()
+	if key.Id().Device.Type != DeviceTypeDerived {
+		panic(fmt.Sprintf("Cannot unbind %v, it is not a derived key.", key))
+	}
+	if input.key_map[key.Id()] != key {
+		panic(fmt.Sprintf("Cannot unbind %v, it is not bound.", key))
+	}
+	input.checkNoDependents(key.Id())
+	input.removeKeys(func(k Key) bool { return k == key })
+}
+
+// UnbindDerivedKeyFamily removes a family that was created with
+// BindDerivedKeyFamily(), along with all of the keys that it has created for
+// specific devices.  As with UnbindDerivedKey(), no release events are sent
+// for those keys, and it is an error to unbind a family that other derived
+// keys depend on.
+func (input *Input) UnbindDerivedKeyFamily(index KeyIndex) {
+	input.mutex.Lock()
+	defer input.mutex.Unlock()
+	family, ok := input.index_to_family[index]
+	if !ok {
+		panic(fmt.Sprintf("Cannot unbind key index %d, it is not a derived key family.", index))
+	}
+	for _, key := range input.all_keys {
+		if key.Id().Index == index {
+			input.checkNoDependents(key.Id())
+		}
+	}
+	input.removeKeys(func(k Key) bool { return k.Id().Index == index })
+
+	delete(input.index_to_family, index)
+	for dep, families := range input.index_to_family_deps {
+		algorithm.Choose(&families, func(dkf derivedKeyFamily) bool { return dkf.index != index })
+		if len(families) == 0 {
+			delete(input.index_to_family_deps, dep)
+		} else {
+			input.index_to_family_deps[dep] = families
+		}
+	}
+	if input.name_to_index[strings.ToLower(family.name)] == index {
+		delete(input.name_to_index, strings.ToLower(family.name))
+	}
+}
+
+// checkNoDependents panics if any derived keys depend on the key with the
+// specified id.
+func (input *Input) checkNoDependents(id KeyId) {
+	if len(input.id_to_deps[id]) > 0 {
+		panic(fmt.Sprintf("Cannot unbind %v, %d other keys depend on it.", id, len(input.id_to_deps[id])))
+	}
+	for _, family := range input.index_to_family_deps[id.Index] {
+		if family.index != id.Index {
+			panic(fmt.Sprintf("Cannot unbind %v, derived key family '%s' depends on it.", id, family.name))
+		}
+	}
+}
+
+// removeKeys removes all keys for which remove returns true from key_map,
+// all_keys and id_to_deps.
+func (input *Input) removeKeys(remove func(Key) bool) {
+	for id, key := range input.key_map {
+		if remove(key) {
+			delete(input.key_map, id)
+			delete(input.id_to_deps, id)
+		}
+	}
+	algorithm.Choose(&input.all_keys, func(k Key) bool { return !remove(k) })
+	for id, deps := range input.id_to_deps {
+		algorithm.Choose(&deps, func(k Key) bool { return !remove(k) })
+		if len(deps) == 0 {
+			delete(input.id_to_deps, id)
+		} else {
+			input.id_to_deps[id] = deps
+		}
+	}
+}
+
 // A derivedKey is down if any of its bindings are down
 type derivedKey struct {
 	keyState
@@ -160,7 +252,7 @@ func (b *Binding) primaryPressAmt() float64 {
 
 func (b *Binding) CurPressAmt() float64 {
 	for i := range b.Modifiers {
-		if b.Input.GetKey(b.Modifiers[i]).IsDown() != b.Down[i] {
+		if b.Input.getKey(b.Modifiers[i]).IsDown() != b.Down[i] {
 			return 0
 		}
 	}
@@ -182,6 +274,8 @@ type derivedKeyFamily struct {
 }
 
 func (input *Input) BindDerivedKeyFamily(name string, bindings ...BindingFamily) KeyIndex {
+	input.mutex.Lock()
+	defer input.mutex.Unlock()
 	return input.bindDerivedKeyFamilyWithIndex(
 		name,
 		genDerivedKeyIndex(),
@@ -196,12 +290,13 @@ func (input *Input) bindDerivedKeyFamilyWithIndex(name string, index KeyIndex, b
 		input:            input,
 	}
 	for _, binding := range bindings {
-		input.index_to_family_deps[binding.PrimaryIndex] = append(input.index_to_family_deps[dkf.index], dkf)
+		input.index_to_family_deps[binding.PrimaryIndex] = append(input.index_to_family_deps[binding.PrimaryIndex], dkf)
 		for _, mod := range binding.Modifiers {
-			input.index_to_family_deps[mod] = append(input.index_to_family_deps[dkf.index], dkf)
+			input.index_to_family_deps[mod] = append(input.index_to_family_deps[mod], dkf)
 		}
 	}
 	dkf.input.index_to_family[dkf.index] = dkf
+	dkf.input.name_to_index[strings.ToLower(dkf.name)] = dkf.index
 	return dkf.index
 }
 
@@ -210,16 +305,7 @@ func (dkf *derivedKeyFamily) GetKey(device DeviceId) Key {
 	if _, ok := dkf.input.key_map[id]; !ok {
 		var bindings []Binding
 		for _, binding_family := range dkf.binding_families {
-			var modifiers []KeyId
-			for _, index := range binding_family.Modifiers {
-				modifiers = append(modifiers, KeyId{Index: index, Device: device})
-			}
-			bindings = append(bindings, Binding{
-				PrimaryKey: KeyId{Index: binding_family.PrimaryIndex, Device: device},
-				Modifiers:  modifiers,
-				Down:       binding_family.Down,
-				Input:      dkf.input,
-			})
+			bindings = append(bindings, dkf.input.bindingFromFamily(binding_family, device))
 		}
 		dkf.input.bindDerivedKeyWithIndex(dkf.name, dkf.index, device, bindings...)
 
@@ -230,8 +316,7 @@ func (dkf *derivedKeyFamily) GetKey(device DeviceId) Key {
 		// dkf.input.key_map[id] = key
 		// dkf.input.all_keys = append(dkf.input.all_keys, key)
 	}
-	ret := dkf.input.GetKeyFlat(dkf.index, device.Type, device.Index)
-	return ret
+	return dkf.input.getKey(id)
 }
 
 // A BindingFamily is like a binding, but it does not specify a device.  Instead
@@ -257,3 +342,18 @@ func (input *Input) MakeBindingFamily(primary KeyIndex, modifiers []KeyIndex, do
 		Down:         down,
 	}
 }
+
+// bindingFromFamily returns the Binding that bf represents on the specified
+// device.
+func (input *Input) bindingFromFamily(bf BindingFamily, device DeviceId) Binding {
+	var modifiers []KeyId
+	for _, index := range bf.Modifiers {
+		modifiers = append(modifiers, KeyId{Index: index, Device: device})
+	}
+	return Binding{
+		PrimaryKey: KeyId{Index: bf.PrimaryIndex, Device: device},
+		Modifiers:  modifiers,
+		Down:       bf.Down,
+		Input:      input,
+	}
+}
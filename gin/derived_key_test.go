@@ -0,0 +1,74 @@
+package gin_test
+
+import (
+	"github.com/orfjackal/gospec/src/gospec"
+	. "github.com/orfjackal/gospec/src/gospec"
+	"github.com/runningwild/glop/gin"
+)
+
+func UnbindSpec(c gospec.Context) {
+	input := gin.Make()
+	keya := gin.KeyId{Index: gin.KeyA, Device: gin.DeviceId{Type: gin.DeviceTypeKeyboard, Index: 1}}
+	keyb := gin.KeyId{Index: gin.KeyB, Device: gin.DeviceId{Type: gin.DeviceTypeKeyboard, Index: 1}}
+	ab := input.BindDerivedKey("AB", input.MakeBinding(keya, nil, nil), input.MakeBinding(keyb, nil, nil))
+
+	c.Specify("Unbound derived keys are removed and stop receiving events.", func() {
+		input.UnbindDerivedKey(ab)
+		c.Expect(input.GetKeyByName("AB") == nil, Equals, true)
+		events := make([]gin.OsEvent, 0)
+		injectEvent(&events, gin.KeyA, 1, gin.DeviceTypeKeyboard, 1, 5)
+		groups := input.Think(10, true, events)
+		c.Expect(ab.FramePressCount(), Equals, 0)
+		c.Expect(len(groups), Equals, 1)
+		found, _ := groups[0].FindEvent(ab.Id())
+		c.Expect(found, Equals, false)
+	})
+
+	c.Specify("Keys that other keys depend on cannot be unbound.", func() {
+		nested := input.BindDerivedKey("Nested", input.MakeBinding(ab.Id(), nil, nil))
+		panicked := false
+		func() {
+			defer func() { panicked = recover() != nil }()
+			input.UnbindDerivedKey(ab)
+		}()
+		c.Expect(panicked, Equals, true)
+		input.UnbindDerivedKey(nested)
+		input.UnbindDerivedKey(ab)
+	})
+
+	c.Specify("Unbinding a family removes all of its keys.", func() {
+		family := input.BindDerivedKeyFamily("AOrB",
+			input.MakeBindingFamily(gin.KeyA, nil, nil),
+			input.MakeBindingFamily(gin.KeyB, nil, nil))
+		other := input.BindDerivedKeyFamily("AOrC",
+			input.MakeBindingFamily(gin.KeyA, nil, nil),
+			input.MakeBindingFamily(gin.KeyC, nil, nil))
+		k1 := input.GetKeyFlat(family, gin.DeviceTypeKeyboard, 1)
+		events := make([]gin.OsEvent, 0)
+		injectEvent(&events, gin.KeyA, 1, gin.DeviceTypeKeyboard, 1, 5)
+		input.Think(10, true, events)
+		c.Expect(k1.FramePressCount(), Equals, 1)
+
+		input.UnbindDerivedKeyFamily(family)
+		c.Expect(input.GetKeyByName("AOrB") == nil, Equals, true)
+		_, _, err := input.ParseKeyIndex("AOrB")
+		c.Expect(err, Not(Equals), nil)
+
+		events = events[0:0]
+		injectEvent(&events, gin.KeyA, 1, gin.DeviceTypeKeyboard, 0, 15)
+		injectEvent(&events, gin.KeyA, 2, gin.DeviceTypeKeyboard, 1, 16)
+		input.Think(20, true, events)
+		c.Expect(k1.FrameReleaseCount(), Equals, 0)
+		c.Expect(input.GetKeyByName("AOrB") == nil, Equals, true)
+		c.Expect(input.GetKeyFlat(other, gin.DeviceTypeKeyboard, 2).FramePressCount(), Equals, 1)
+	})
+
+	c.Specify("Rebinding an action unbinds its old key.", func() {
+		am := input.MakeActionMap()
+		am.Bind("jump", input.MakeBinding(keya, nil, nil))
+		old := am.Key("jump")
+		am.Bind("jump", input.MakeBinding(keyb, nil, nil))
+		c.Expect(input.GetKeyByName("jump") == am.Key("jump"), Equals, true)
+		c.Expect(input.GetKeyByName("jump") == old, Equals, false)
+	})
+}
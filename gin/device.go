@@ -0,0 +1,102 @@
+package gin
+
+import (
+	"fmt"
+)
+
+// Device events report devices, typically controllers, being connected and
+// disconnected while the app is running.  A device keeps the same DeviceIndex
+// if it is disconnected and then reconnected, so an app can tell that player
+// 2's controller has come back.
+
+type DeviceEventType int
+
+const (
+	DeviceConnected DeviceEventType = iota
+	DeviceDisconnected
+)
+
+func (t DeviceEventType) String() string {
+	switch t {
+	case DeviceConnected:
+		return "connected"
+	case DeviceDisconnected:
+		return "disconnected"
+	}
+	panic(fmt.Sprintf("%d is not a valid DeviceEventType", t))
+}
+
+type DeviceEvent struct {
+	Type   DeviceEventType
+	Device DeviceId
+
+	// Human readable name of the device as reported by the OS, may be empty.
+	Name string
+
+	// GUID of the device in the format used by SDL, see GamepadDatabase.  May
+	// be empty, and is only set on DeviceConnected events.
+	GUID string
+
+	Timestamp int64
+}
+
+func (e DeviceEvent) String() string {
+	return fmt.Sprintf("'%v %v %q'", e.Type, e.Device, e.Name)
+}
+
+// A Listener that also implements DeviceHandler will receive device events
+// that are passed to Input.DispatchDeviceEvents().
+type DeviceHandler interface {
+	HandleDeviceEvent(DeviceEvent)
+}
+
+// DispatchDeviceEvents sends each event, in order, to every registered
+// Listener that implements DeviceHandler.  Unlike key and text events, device
+// events are sent to listeners in every pushed context, even blocked ones,
+// since a controller being unplugged matters to the game underneath a menu.
+// Controllers that connect are also given their mapping from the database set
+// with SetGamepadDatabase(), if there is one.
+func (input *Input) DispatchDeviceEvents(events []DeviceEvent) {
+	for _, event := range events {
+		input.mutex.Lock()
+		if input.recorder != nil {
+			input.recorded_device_events = append(input.recorded_device_events, event)
+		}
+		input.lookupGamepadMapping(event)
+		listeners := input.allListeners()
+		input.mutex.Unlock()
+		for _, listener := range listeners {
+			if handler, ok := listener.(DeviceHandler); ok {
+				handler.HandleDeviceEvent(event)
+			}
+		}
+	}
+}
+
+// DeviceReleaseEvents returns OsEvents that release every key that is down on
+// a device that is disconnected by one of the specified events.  These should
+// be passed to Think() along with the rest of the frame's events so that keys
+// don't get stuck down when a device is unplugged while they are pressed.
+func (input *Input) DeviceReleaseEvents(events []DeviceEvent) []OsEvent {
+	input.mutex.Lock()
+	defer input.mutex.Unlock()
+	var releases []OsEvent
+	for _, event := range events {
+		if event.Type != DeviceDisconnected {
+			continue
+		}
+		for _, key := range input.all_keys {
+			if key.Id().Device != event.Device || !key.Id().IsNatural() {
+				continue
+			}
+			if key.IsDown() {
+				releases = append(releases, OsEvent{
+					KeyId:     key.Id(),
+					Press_amt: 0,
+					Timestamp: event.Timestamp,
+				})
+			}
+		}
+	}
+	return releases
+}
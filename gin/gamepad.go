@@ -0,0 +1,527 @@
+package gin
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"runtime"
+	"strconv"
+	"strings"
+)
+
+// Controllers report their buttons and axes as ControllerButton0+N,
+// ControllerAxis0Positive+N and so on, but which N is the A button depends on
+// the controller.  A GamepadMapping describes one kind of controller in the
+// format used by SDL's gamecontrollerdb.txt, e.g.
+//
+//   030000005e0400008e02000014010000,Xbox 360 Controller,a:b0,b:b1,...,leftx:a0,lefttrigger:a2,...,dpup:h0.1,platform:Linux,
+//
+// Once a controller has a mapping, see Input.SetGamepadMapping() and
+// Input.SetGamepadDatabase(), events for its raw keys are also reported on
+// the standard gamepad keys, GamepadA through RightTrigger, so a single
+// binding works on every mapped controller.
+
+type gamepadOutput struct {
+	sdl_name string
+	index    KeyIndex
+	name     string
+}
+
+var gamepad_outputs = []gamepadOutput{
+	{"a", GamepadA, "GamepadA"},
+	{"b", GamepadB, "GamepadB"},
+	{"x", GamepadX, "GamepadX"},
+	{"y", GamepadY, "GamepadY"},
+	{"back", GamepadBack, "GamepadBack"},
+	{"guide", GamepadGuide, "GamepadGuide"},
+	{"start", GamepadStart, "GamepadStart"},
+	{"leftstick", GamepadLeftStick, "GamepadLeftStick"},
+	{"rightstick", GamepadRightStick, "GamepadRightStick"},
+	{"leftshoulder", GamepadLeftShoulder, "GamepadLeftShoulder"},
+	{"rightshoulder", GamepadRightShoulder, "GamepadRightShoulder"},
+	{"dpup", GamepadDpadUp, "GamepadDpadUp"},
+	{"dpdown", GamepadDpadDown, "GamepadDpadDown"},
+	{"dpleft", GamepadDpadLeft, "GamepadDpadLeft"},
+	{"dpright", GamepadDpadRight, "GamepadDpadRight"},
+	{"misc1", GamepadMisc1, "GamepadMisc1"},
+	{"leftx", LeftStickX, "LeftStickX"},
+	{"lefty", LeftStickY, "LeftStickY"},
+	{"rightx", RightStickX, "RightStickX"},
+	{"righty", RightStickY, "RightStickY"},
+	{"lefttrigger", LeftTrigger, "LeftTrigger"},
+	{"righttrigger", RightTrigger, "RightTrigger"},
+}
+
+func isGamepadAxis(index KeyIndex) bool {
+	return index >= LeftStickX && index <= RightTrigger
+}
+
+func isGamepadTrigger(index KeyIndex) bool {
+	return index == LeftTrigger || index == RightTrigger
+}
+
+type gamepadInputKind int
+
+const (
+	gamepadButton gamepadInputKind = iota
+	gamepadAxis
+	gamepadHat
+)
+
+// A gamepadElement maps one raw button, axis or hat direction to one standard
+// gamepad key.
+type gamepadElement struct {
+	kind gamepadInputKind
+
+	// Button or axis number, or hat number for hats.
+	index int
+
+	// Direction mask for hats, 1 is up, 2 is right, 4 is down and 8 is left.
+	hat_mask int
+
+	// 0 if the whole input axis is used, otherwise +1 or -1 to use only the
+	// positive or negative half of it.
+	input_half int
+	invert     bool
+
+	output KeyIndex
+
+	// 0 if the whole output axis is set, otherwise +1 or -1 to set only the
+	// positive or negative half of it.
+	output_half int
+}
+
+type GamepadMapping struct {
+	// Lower-case hex, as in gamecontrollerdb.txt.
+	GUID string
+
+	Name string
+
+	// Platform that this mapping is for, e.g. "Linux", or empty if it wasn't
+	// specified.
+	Platform string
+
+	elements []gamepadElement
+}
+
+// ParseGamepadMapping parses a single line in the gamecontrollerdb.txt format.
+// Standard gamepad keys that gin doesn't have, like SDL's paddles, are
+// ignored.
+func ParseGamepadMapping(line string) (*GamepadMapping, error) {
+	fields := strings.Split(strings.TrimSpace(line), ",")
+	if len(fields) < 2 {
+		return nil, fmt.Errorf("Gamepad mapping '%s' has no name.", line)
+	}
+	m := &GamepadMapping{
+		GUID: strings.ToLower(fields[0]),
+		Name: fields[1],
+	}
+	if len(m.GUID) != 32 {
+		return nil, fmt.Errorf("Invalid GUID '%s' in gamepad mapping.", fields[0])
+	}
+	for _, c := range m.GUID {
+		if !strings.ContainsRune("0123456789abcdef", c) {
+			return nil, fmt.Errorf("Invalid GUID '%s' in gamepad mapping.", fields[0])
+		}
+	}
+	for _, field := range fields[2:] {
+		if field == "" {
+			continue
+		}
+		colon := strings.Index(field, ":")
+		if colon == -1 {
+			return nil, fmt.Errorf("Invalid element '%s' in gamepad mapping for '%s'.", field, m.Name)
+		}
+		target, source := field[:colon], field[colon+1:]
+		if target == "platform" {
+			m.Platform = source
+			continue
+		}
+		element, ok, err := parseGamepadElement(target, source)
+		if err != nil {
+			return nil, fmt.Errorf("%v in gamepad mapping for '%s'", err, m.Name)
+		}
+		if ok {
+			m.elements = append(m.elements, element)
+		}
+	}
+	return m, nil
+}
+
+// parseGamepadElement parses a single target:source element.  It returns false
+// if target is valid but isn't a key that gin reports.
+func parseGamepadElement(target, source string) (gamepadElement, bool, error) {
+	var e gamepadElement
+	switch {
+	case strings.HasPrefix(target, "+"):
+		e.output_half = 1
+		target = target[1:]
+	case strings.HasPrefix(target, "-"):
+		e.output_half = -1
+		target = target[1:]
+	}
+	found := false
+	for _, output := range gamepad_outputs {
+		if output.sdl_name == target {
+			e.output = output.index
+			found = true
+		}
+	}
+	if e.output_half != 0 && found && !isGamepadAxis(e.output) {
+		return e, false, fmt.Errorf("Cannot use half of '%s'", target)
+	}
+
+	switch {
+	case strings.HasPrefix(source, "+"):
+		e.input_half = 1
+		source = source[1:]
+	case strings.HasPrefix(source, "-"):
+		e.input_half = -1
+		source = source[1:]
+	}
+	if strings.HasSuffix(source, "~") {
+		e.invert = true
+		source = source[:len(source)-1]
+	}
+	if source == "" {
+		return e, false, fmt.Errorf("Missing input for '%s'", target)
+	}
+	var err error
+	switch source[0] {
+	case 'b':
+		e.kind = gamepadButton
+		e.index, err = strconv.Atoi(source[1:])
+	case 'a':
+		e.kind = gamepadAxis
+		e.index, err = strconv.Atoi(source[1:])
+	case 'h':
+		e.kind = gamepadHat
+		dot := strings.Index(source, ".")
+		if dot == -1 {
+			return e, false, fmt.Errorf("Invalid hat '%s'", source)
+		}
+		e.index, err = strconv.Atoi(source[1:dot])
+		if err == nil {
+			e.hat_mask, err = strconv.Atoi(source[dot+1:])
+		}
+	default:
+		return e, false, fmt.Errorf("Invalid input '%s' for '%s'", source, target)
+	}
+	if err != nil || e.index < 0 {
+		return e, false, fmt.Errorf("Invalid input '%s' for '%s'", source, target)
+	}
+	if e.kind != gamepadAxis && (e.input_half != 0 || e.invert) {
+		return e, false, fmt.Errorf("Only axes can be split or inverted, not '%s'", source)
+	}
+	return e, found, nil
+}
+
+// String returns the mapping in the gamecontrollerdb.txt format.
+func (m *GamepadMapping) String() string {
+	parts := []string{m.GUID, m.Name}
+	for _, e := range m.elements {
+		target := ""
+		switch e.output_half {
+		case 1:
+			target = "+"
+		case -1:
+			target = "-"
+		}
+		for _, output := range gamepad_outputs {
+			if output.index == e.output {
+				target += output.sdl_name
+			}
+		}
+		source := ""
+		switch e.input_half {
+		case 1:
+			source = "+"
+		case -1:
+			source = "-"
+		}
+		switch e.kind {
+		case gamepadButton:
+			source += fmt.Sprintf("b%d", e.index)
+		case gamepadAxis:
+			source += fmt.Sprintf("a%d", e.index)
+		case gamepadHat:
+			source += fmt.Sprintf("h%d.%d", e.index, e.hat_mask)
+		}
+		if e.invert {
+			source += "~"
+		}
+		parts = append(parts, target+":"+source)
+	}
+	if m.Platform != "" {
+		parts = append(parts, "platform:"+m.Platform)
+	}
+	return strings.Join(parts, ",") + ","
+}
+
+// A GamepadDatabase holds GamepadMappings keyed by GUID.
+type GamepadDatabase struct {
+	mappings map[string]*GamepadMapping
+}
+
+func MakeGamepadDatabase() *GamepadDatabase {
+	return &GamepadDatabase{mappings: make(map[string]*GamepadMapping)}
+}
+
+// sdlPlatform is the name that gamecontrollerdb.txt uses for this platform.
+func sdlPlatform() string {
+	switch runtime.GOOS {
+	case "linux":
+		return "Linux"
+	case "darwin":
+		return "Mac OS X"
+	case "windows":
+		return "Windows"
+	}
+	return runtime.GOOS
+}
+
+// Load adds every mapping in r, which is in the gamecontrollerdb.txt format,
+// that is for this platform or that doesn't specify a platform.  Blank lines
+// and lines starting with # are ignored.  Mappings replace any earlier
+// mappings with the same GUID.
+func (db *GamepadDatabase) Load(r io.Reader) error {
+	scanner := bufio.NewScanner(r)
+	line_num := 0
+	for scanner.Scan() {
+		line_num++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		m, err := ParseGamepadMapping(line)
+		if err != nil {
+			return fmt.Errorf("Line %d: %v", line_num, err)
+		}
+		if m.Platform == "" || m.Platform == sdlPlatform() {
+			db.Add(m)
+		}
+	}
+	return scanner.Err()
+}
+
+func (db *GamepadDatabase) Add(m *GamepadMapping) {
+	db.mappings[m.GUID] = m
+}
+
+// Lookup returns the mapping for the controller with the specified GUID, or
+// nil if there isn't one.  Like SDL, if there is no exact match this also
+// tries ignoring the CRC of the name that newer versions of SDL put in bytes 2
+// and 3 of the GUID, and then also ignoring the version in bytes 12 and 13.
+func (db *GamepadDatabase) Lookup(guid string) *GamepadMapping {
+	guid = strings.ToLower(guid)
+	if m, ok := db.mappings[guid]; ok {
+		return m
+	}
+	if len(guid) != 32 {
+		return nil
+	}
+	guid = guid[0:4] + "0000" + guid[8:]
+	if m, ok := db.mappings[guid]; ok {
+		return m
+	}
+	guid = guid[0:24] + "0000" + guid[28:]
+	if m, ok := db.mappings[guid]; ok {
+		return m
+	}
+	return nil
+}
+
+// Len returns the number of mappings in the database.
+func (db *GamepadDatabase) Len() int {
+	return len(db.mappings)
+}
+
+// gamepadState tracks the raw inputs of a single mapped controller.
+type gamepadState struct {
+	mapping *GamepadMapping
+
+	// Signed values of the raw axes, and the direction mask of the first hat.
+	axes map[int]float64
+	hat  int
+
+	// Axes that have gone negative.  Triggers usually report values in
+	// [0, 1], but some report values in [-1, 1], resting at -1, like SDL
+	// expects them all to.
+	bipolar map[int]bool
+
+	// Values of the halves of output axes that are set a half at a time.
+	halves map[KeyIndex][2]float64
+}
+
+// SetGamepadMapping sets the mapping used for the controller device.  A nil
+// mapping removes any mapping for device, and the standard gamepad keys on
+// that device stop being updated.
+func (input *Input) SetGamepadMapping(device DeviceId, m *GamepadMapping) {
+	input.mutex.Lock()
+	defer input.mutex.Unlock()
+	input.setGamepadMapping(device, m)
+}
+
+func (input *Input) setGamepadMapping(device DeviceId, m *GamepadMapping) {
+	if m == nil {
+		delete(input.gamepads, device)
+		return
+	}
+	input.gamepads[device] = &gamepadState{
+		mapping: m,
+		axes:    make(map[int]float64),
+		bipolar: make(map[int]bool),
+		halves:  make(map[KeyIndex][2]float64),
+	}
+}
+
+// GamepadMapping returns the mapping used for the controller device, or nil
+// if it doesn't have one.
+func (input *Input) GamepadMapping(device DeviceId) *GamepadMapping {
+	input.mutex.Lock()
+	defer input.mutex.Unlock()
+	if state, ok := input.gamepads[device]; ok {
+		return state.mapping
+	}
+	return nil
+}
+
+// SetGamepadDatabase sets the database that mappings are looked up in when
+// DispatchDeviceEvents() sees a controller connect.
+func (input *Input) SetGamepadDatabase(db *GamepadDatabase) {
+	input.mutex.Lock()
+	defer input.mutex.Unlock()
+	input.gamepad_db = db
+}
+
+// lookupGamepadMapping gives a newly connected controller the mapping for its
+// GUID, if there is one.  Controllers keep their mapping when they disconnect
+// since they keep their DeviceIndex if they come back.
+func (input *Input) lookupGamepadMapping(event DeviceEvent) {
+	if event.Type != DeviceConnected || event.Device.Type != DeviceTypeController {
+		return
+	}
+	if input.gamepad_db == nil || event.GUID == "" {
+		return
+	}
+	if m := input.gamepad_db.Lookup(event.GUID); m != nil {
+		if state, ok := input.gamepads[event.Device]; ok && state.mapping == m {
+			return
+		}
+		input.setGamepadMapping(event.Device, m)
+	}
+}
+
+// hat_masks maps the hat switch keys to gamecontrollerdb.txt hat masks.
+var hat_masks = map[KeyIndex]int{
+	ControllerHatSwitchUp:        1,
+	ControllerHatSwitchUpRight:   1 | 2,
+	ControllerHatSwitchRight:     2,
+	ControllerHatSwitchDownRight: 4 | 2,
+	ControllerHatSwitchDown:      4,
+	ControllerHatSwitchDownLeft:  4 | 8,
+	ControllerHatSwitchLeft:      8,
+	ControllerHatSwitchUpLeft:    1 | 8,
+}
+
+// mapGamepadEvent returns the events for the standard gamepad keys that change
+// as a result of event, which has already been applied to its raw key.
+func (input *Input) mapGamepadEvent(event OsEvent) []OsEvent {
+	state, ok := input.gamepads[event.KeyId.Device]
+	if !ok {
+		return nil
+	}
+	index := event.KeyId.Index
+	var kind gamepadInputKind
+	var number int
+	switch {
+	case index >= ControllerButton0 && index < ControllerAxis0Positive:
+		kind, number = gamepadButton, int(index-ControllerButton0)
+	case index >= ControllerAxis0Positive && index < ControllerHatSwitchUp:
+		axis, positive, _ := splitAxisIndex(index)
+		// Both keys of an axis are sent on every change, releasing the other
+		// half first.  Ignore that release so that the axis doesn't pass through
+		// 0 every time it moves.
+		if event.Press_amt == 0 && (state.axes[axis] > 0) != positive && state.axes[axis] != 0 {
+			return nil
+		}
+		if positive {
+			state.axes[axis] = event.Press_amt
+		} else {
+			state.axes[axis] = -event.Press_amt
+		}
+		if state.axes[axis] < 0 {
+			state.bipolar[axis] = true
+		}
+		kind, number = gamepadAxis, axis
+	case index >= ControllerHatSwitchUp && index <= ControllerHatSwitchUpLeft:
+		if event.Press_amt != 0 {
+			state.hat = hat_masks[index]
+		} else if state.hat == hat_masks[index] {
+			state.hat = 0
+		}
+		kind, number = gamepadHat, 0
+	default:
+		return nil
+	}
+
+	var events []OsEvent
+	for _, e := range state.mapping.elements {
+		if e.kind != kind || e.index != number {
+			continue
+		}
+		var v float64
+		switch kind {
+		case gamepadButton:
+			v = event.Press_amt
+		case gamepadAxis:
+			v = state.axes[number]
+			if e.invert {
+				v = -v
+			}
+			switch e.input_half {
+			case 1:
+				v = math.Max(v, 0)
+			case -1:
+				v = math.Max(-v, 0)
+			}
+		case gamepadHat:
+			if state.hat&e.hat_mask != 0 {
+				v = 1
+			}
+		}
+
+		var amt float64
+		switch {
+		case !isGamepadAxis(e.output):
+			// Analog inputs press buttons once they are more than half way.
+			if math.Abs(v) > 0.5 {
+				amt = 1
+			}
+		case e.output_half != 0:
+			halves := state.halves[e.output]
+			if e.output_half > 0 {
+				halves[0] = math.Abs(v)
+			} else {
+				halves[1] = math.Abs(v)
+			}
+			state.halves[e.output] = halves
+			amt = halves[0] - halves[1]
+		case isGamepadTrigger(e.output):
+			if e.kind == gamepadAxis && e.input_half == 0 && state.bipolar[e.index] {
+				// Like SDL, the whole axis maps to the whole trigger.
+				amt = (v + 1) / 2
+			} else {
+				amt = math.Max(v, 0)
+			}
+		default:
+			amt = v
+		}
+		id := KeyId{Index: e.output, Device: event.KeyId.Device}
+		if input.getKey(id).CurPressAmt() != amt {
+			events = append(events, OsEvent{KeyId: id, Press_amt: amt, Timestamp: event.Timestamp})
+		}
+	}
+	return events
+}
@@ -0,0 +1,203 @@
+package gin_test
+
+import (
+	"github.com/orfjackal/gospec/src/gospec"
+	. "github.com/orfjackal/gospec/src/gospec"
+	"github.com/runningwild/glop/gin"
+	"strings"
+)
+
+const xbox_guid = "030000005e0400008e02000014010000"
+const ps4_guid = "030000004c050000c405000011010000"
+
+var gamepad_db_text = `
+# Two controllers that disagree about which button is which.
+030000005e0400008e02000014010000,Xbox 360 Controller,a:b0,b:b1,x:b2,y:b3,back:b6,guide:b8,start:b7,leftstick:b9,rightstick:b10,leftshoulder:b4,rightshoulder:b5,dpup:h0.1,dpdown:h0.4,dpleft:h0.8,dpright:h0.2,leftx:a0,lefty:a1,rightx:a3,righty:a4,lefttrigger:a2,righttrigger:a5,platform:Linux,
+030000004c050000c405000011010000,PS4 Controller,a:b1,b:b2,x:b0,y:b3,back:b8,guide:b12,start:b9,-leftx:b14,+leftx:b15,lefty:a1~,lefttrigger:+a3,platform:Linux,
+030000004c050000c405000011010000,PS4 Controller,a:b5,platform:Mac OS X,
+`
+
+func GamepadMappingSpec(c gospec.Context) {
+	c.Specify("Mappings are parsed and printed in the gamecontrollerdb.txt format.", func() {
+		line := "030000004C050000C405000011010000,PS4,a:b1,-leftx:b14,lefty:a1~,lefttrigger:+a3,dpup:h0.1,paddle1:b20,platform:Linux,"
+		m, err := gin.ParseGamepadMapping(line)
+		c.Assume(err, IsNil)
+		c.Expect(m.GUID, Equals, ps4_guid)
+		c.Expect(m.Name, Equals, "PS4")
+		c.Expect(m.Platform, Equals, "Linux")
+		c.Expect(m.String(), Equals, strings.ToLower(line[0:32])+",PS4,a:b1,-leftx:b14,lefty:a1~,lefttrigger:+a3,dpup:h0.1,platform:Linux,")
+	})
+
+	c.Specify("Malformed mappings are errors.", func() {
+		bad := []string{
+			"",
+			"0300,Short GUID,a:b0,",
+			xbox_guid + ",Pad,a",
+			xbox_guid + ",Pad,a:c0",
+			xbox_guid + ",Pad,a:bx",
+			xbox_guid + ",Pad,a:h0",
+			xbox_guid + ",Pad,a:b0~",
+			xbox_guid + ",Pad,+a:b0",
+		}
+		for _, line := range bad {
+			_, err := gin.ParseGamepadMapping(line)
+			c.Expect(err, Not(IsNil))
+		}
+	})
+
+	c.Specify("Databases only load mappings for this platform.", func() {
+		db := gin.MakeGamepadDatabase()
+		c.Assume(db.Load(strings.NewReader(gamepad_db_text)), IsNil)
+		c.Expect(db.Lookup(xbox_guid).Name, Equals, "Xbox 360 Controller")
+		c.Expect(db.Lookup("030000005e0400008e02000099999999"), IsNil)
+		err := db.Load(strings.NewReader("\n" + xbox_guid + ",Bad,a:q0,\n"))
+		c.Expect(err, Not(IsNil))
+		c.Expect(strings.Contains(err.Error(), "Line 2"), Equals, true)
+	})
+
+	c.Specify("Lookups ignore the name CRC and then the version.", func() {
+		db := gin.MakeGamepadDatabase()
+		c.Assume(db.Load(strings.NewReader(gamepad_db_text)), IsNil)
+		c.Expect(db.Lookup("03001234"+xbox_guid[8:]), Not(IsNil))
+		c.Expect(db.Lookup(xbox_guid[0:24]+"99990000"), IsNil)
+		db.Add(&gin.GamepadMapping{GUID: xbox_guid[0:24] + "00000000", Name: "Any version"})
+		c.Expect(db.Lookup(xbox_guid[0:24]+"99990000").Name, Equals, "Any version")
+	})
+}
+
+func GamepadInputSpec(c gospec.Context) {
+	input := gin.Make()
+	db := gin.MakeGamepadDatabase()
+	c.Assume(db.Load(strings.NewReader(gamepad_db_text)), IsNil)
+	input.SetGamepadDatabase(db)
+	xbox := gin.DeviceId{Type: gin.DeviceTypeController, Index: 1}
+	ps4 := gin.DeviceId{Type: gin.DeviceTypeController, Index: 2}
+	input.DispatchDeviceEvents([]gin.DeviceEvent{
+		{Type: gin.DeviceConnected, Device: xbox, GUID: xbox_guid},
+		{Type: gin.DeviceConnected, Device: ps4, GUID: ps4_guid},
+	})
+	key := func(index gin.KeyIndex, device gin.DeviceId) gin.Key {
+		return input.GetKey(gin.KeyId{Index: index, Device: device})
+	}
+
+	c.Specify("Connected controllers get their mappings from the database.", func() {
+		c.Expect(input.GamepadMapping(xbox).Name, Equals, "Xbox 360 Controller")
+		c.Expect(input.GamepadMapping(ps4).Name, Equals, "PS4 Controller")
+		c.Expect(input.GamepadMapping(gin.DeviceId{Type: gin.DeviceTypeController, Index: 3}), IsNil)
+	})
+
+	c.Specify("Different raw buttons press the same standard button.", func() {
+		events := make([]gin.OsEvent, 0)
+		injectEvent(&events, gin.ControllerButton0+0, 1, gin.DeviceTypeController, 1, 5)
+		injectEvent(&events, gin.ControllerButton0+1, 2, gin.DeviceTypeController, 1, 6)
+		input.Think(10, true, events)
+		c.Expect(key(gin.GamepadA, xbox).IsDown(), Equals, true)
+		c.Expect(key(gin.GamepadA, ps4).IsDown(), Equals, true)
+		c.Expect(key(gin.GamepadX, ps4).IsDown(), Equals, false)
+		c.Expect(key(gin.ControllerButton0+1, ps4).IsDown(), Equals, true)
+
+		events = events[0:0]
+		injectEvent(&events, gin.ControllerButton0+1, 2, gin.DeviceTypeController, 0, 15)
+		input.Think(20, true, events)
+		c.Expect(key(gin.GamepadA, ps4).IsDown(), Equals, false)
+		c.Expect(key(gin.GamepadA, ps4).FrameReleaseCount(), Equals, 1)
+	})
+
+	c.Specify("Standard keys can be bound by name.", func() {
+		binding, err := input.ParseBinding("GamepadA")
+		c.Assume(err, IsNil)
+		c.Expect(binding.PrimaryKey.Index, Equals, gin.KeyIndex(gin.GamepadA))
+		c.Expect(binding.PrimaryKey.Device.Type, Equals, gin.DeviceTypeController)
+	})
+
+	c.Specify("Axes map to sticks and triggers.", func() {
+		events := make([]gin.OsEvent, 0)
+		injectEvent(&events, gin.ControllerAxis0Positive, 1, gin.DeviceTypeController, 0, 5)
+		injectEvent(&events, gin.ControllerAxis0Negative, 1, gin.DeviceTypeController, 0.5, 5)
+		injectEvent(&events, gin.ControllerAxis0Negative+2, 1, gin.DeviceTypeController, 0, 6)
+		injectEvent(&events, gin.ControllerAxis0Positive+2, 1, gin.DeviceTypeController, 0.75, 6)
+		input.Think(10, true, events)
+		c.Expect(key(gin.LeftStickX, xbox).CurPressAmt(), Equals, -0.5)
+		c.Expect(key(gin.LeftTrigger, xbox).CurPressAmt(), Equals, 0.75)
+
+		// Moving within the negative half doesn't pass through the center.
+		events = events[0:0]
+		injectEvent(&events, gin.ControllerAxis0Positive, 1, gin.DeviceTypeController, 0, 15)
+		injectEvent(&events, gin.ControllerAxis0Negative, 1, gin.DeviceTypeController, 0.25, 15)
+		input.Think(20, true, events)
+		c.Expect(key(gin.LeftStickX, xbox).CurPressAmt(), Equals, -0.25)
+		c.Expect(key(gin.LeftStickX, xbox).FrameReleaseCount(), Equals, 0)
+	})
+
+	c.Specify("Triggers that report the whole range map it to the whole trigger.", func() {
+		// Axis 5 rests at -1, like a trigger that isn't normalized to [0, 1].
+		events := make([]gin.OsEvent, 0)
+		injectEvent(&events, gin.ControllerAxis0Positive+5, 1, gin.DeviceTypeController, 0, 5)
+		injectEvent(&events, gin.ControllerAxis0Negative+5, 1, gin.DeviceTypeController, 1, 5)
+		input.Think(10, true, events)
+		c.Expect(key(gin.RightTrigger, xbox).CurPressAmt(), Equals, 0.0)
+
+		events = events[0:0]
+		injectEvent(&events, gin.ControllerAxis0Positive+5, 1, gin.DeviceTypeController, 0, 15)
+		injectEvent(&events, gin.ControllerAxis0Negative+5, 1, gin.DeviceTypeController, 0.5, 15)
+		input.Think(20, true, events)
+		c.Expect(key(gin.RightTrigger, xbox).CurPressAmt(), Equals, 0.25)
+
+		events = events[0:0]
+		injectEvent(&events, gin.ControllerAxis0Negative+5, 1, gin.DeviceTypeController, 0, 25)
+		injectEvent(&events, gin.ControllerAxis0Positive+5, 1, gin.DeviceTypeController, 1, 25)
+		input.Think(30, true, events)
+		c.Expect(key(gin.RightTrigger, xbox).CurPressAmt(), Equals, 1.0)
+	})
+
+	c.Specify("Inverted and half axes are applied.", func() {
+		events := make([]gin.OsEvent, 0)
+		injectEvent(&events, gin.ControllerAxis0Negative+1, 2, gin.DeviceTypeController, 0, 5)
+		injectEvent(&events, gin.ControllerAxis0Positive+1, 2, gin.DeviceTypeController, 0.5, 5)
+		injectEvent(&events, gin.ControllerAxis0Positive+3, 2, gin.DeviceTypeController, 0, 6)
+		injectEvent(&events, gin.ControllerAxis0Negative+3, 2, gin.DeviceTypeController, 1, 6)
+		input.Think(10, true, events)
+		c.Expect(key(gin.LeftStickY, ps4).CurPressAmt(), Equals, -0.5)
+		c.Expect(key(gin.LeftTrigger, ps4).IsDown(), Equals, false)
+	})
+
+	c.Specify("Buttons can set half of an axis.", func() {
+		events := make([]gin.OsEvent, 0)
+		injectEvent(&events, gin.ControllerButton0+14, 2, gin.DeviceTypeController, 1, 5)
+		input.Think(10, true, events)
+		c.Expect(key(gin.LeftStickX, ps4).CurPressAmt(), Equals, -1.0)
+		events = events[0:0]
+		injectEvent(&events, gin.ControllerButton0+15, 2, gin.DeviceTypeController, 1, 15)
+		input.Think(20, true, events)
+		c.Expect(key(gin.LeftStickX, ps4).IsDown(), Equals, false)
+		events = events[0:0]
+		injectEvent(&events, gin.ControllerButton0+14, 2, gin.DeviceTypeController, 0, 25)
+		input.Think(30, true, events)
+		c.Expect(key(gin.LeftStickX, ps4).CurPressAmt(), Equals, 1.0)
+	})
+
+	c.Specify("Hat directions map to the dpad.", func() {
+		events := make([]gin.OsEvent, 0)
+		injectEvent(&events, gin.ControllerHatSwitchUpRight, 1, gin.DeviceTypeController, 1, 5)
+		input.Think(10, true, events)
+		c.Expect(key(gin.GamepadDpadUp, xbox).IsDown(), Equals, true)
+		c.Expect(key(gin.GamepadDpadRight, xbox).IsDown(), Equals, true)
+		c.Expect(key(gin.GamepadDpadDown, xbox).IsDown(), Equals, false)
+
+		events = events[0:0]
+		injectEvent(&events, gin.ControllerHatSwitchUpRight, 1, gin.DeviceTypeController, 0, 15)
+		injectEvent(&events, gin.ControllerHatSwitchRight, 1, gin.DeviceTypeController, 1, 15)
+		input.Think(20, true, events)
+		c.Expect(key(gin.GamepadDpadUp, xbox).IsDown(), Equals, false)
+		c.Expect(key(gin.GamepadDpadRight, xbox).IsDown(), Equals, true)
+	})
+
+	c.Specify("Removing a mapping stops updating the standard keys.", func() {
+		input.SetGamepadMapping(xbox, nil)
+		events := make([]gin.OsEvent, 0)
+		injectEvent(&events, gin.ControllerButton0+0, 1, gin.DeviceTypeController, 1, 5)
+		input.Think(10, true, events)
+		c.Expect(key(gin.GamepadA, xbox).IsDown(), Equals, false)
+		c.Expect(input.GamepadMapping(xbox), IsNil)
+	})
+}
@@ -0,0 +1,342 @@
+package gin
+
+import (
+	"fmt"
+	"math"
+)
+
+// Gesture keys are timed keys that watch the contacts on a touch device.  Each
+// one has a Cursor that is positioned where its gesture happened.  A gesture
+// key can watch a single touch device, or every touch device if the device's
+// Index is DeviceIndexAny.
+
+// touchStart records where and when a contact was first seen by a gesture key.
+type touchStart struct {
+	contact int
+	ms      int64
+	x, y    int
+
+	// Set once the contact can no longer complete the gesture, or once it has.
+	done bool
+}
+
+func (s *touchStart) dist(tk *touchKey) float64 {
+	return math.Hypot(float64(tk.cursor.X-s.x), float64(tk.cursor.Y-s.y))
+}
+
+// gestureKeyState is embedded by every gesture key.
+type gestureKeyState struct {
+	keyState
+	input  *Input
+	device DeviceId
+
+	// Contacts that the gesture is currently watching.
+	starts map[*touchKey]*touchStart
+}
+
+func (input *Input) makeGestureKeyState(name string, device DeviceId) gestureKeyState {
+	if device.Type != DeviceTypeTouch {
+		panic(fmt.Sprintf("Gesture keys must watch a touch device, not %v.", device))
+	}
+	return gestureKeyState{
+		keyState: keyState{
+			id: KeyId{
+				Index:  genDerivedKeyIndex(),
+				Device: DeviceId{Index: 1, Type: DeviceTypeDerived},
+			},
+			name:       name,
+			cursor:     &cursor{name: name},
+			aggregator: &standardAggregator{},
+		},
+		input:  input,
+		device: device,
+		starts: make(map[*touchKey]*touchStart),
+	}
+}
+
+// addGestureKey registers key as depending on every contact of its device.
+func (input *Input) addGestureKey(key Key, device DeviceId) {
+	var deps []KeyId
+	for i := 0; i < MaxTouchContacts; i++ {
+		deps = append(deps, KeyId{Index: TouchContact0 + KeyIndex(i), Device: device})
+	}
+	input.addTimedKey(key, deps)
+}
+
+// track updates starts with the contacts that are currently down, and calls
+// ended for each contact that has ended since the last call.
+func (gs *gestureKeyState) track(ms int64, ended func(*touchKey, *touchStart)) {
+	for _, tk := range gs.input.touchKeys(gs.device) {
+		start, ok := gs.starts[tk]
+		if ok && (!tk.IsDown() || tk.contact != start.contact) {
+			delete(gs.starts, tk)
+			if tk.contact == start.contact {
+				ended(tk, start)
+			}
+			ok = false
+		}
+		if !ok && tk.IsDown() {
+			gs.starts[tk] = &touchStart{
+				contact: tk.contact,
+				ms:      ms,
+				x:       tk.cursor.X,
+				y:       tk.cursor.Y,
+			}
+		}
+	}
+}
+
+// press presses the key and moves its cursor to x, y, unless it is already
+// down.
+func (gs *gestureKeyState) press(x, y int, ms int64, event *Event) {
+	if gs.IsDown() {
+		return
+	}
+	gs.cursor.X, gs.cursor.Y = x, y
+	event.Type = Press
+	gs.keyState.aggregator.SetPressAmt(1, ms, event.Type)
+}
+
+// momentaryThink releases a key that is only down for the frame in which its
+// gesture completes.
+func (gs *gestureKeyState) momentaryThink(ms int64) (bool, float64) {
+	gs.keyState.Think(ms)
+	if gs.IsDown() {
+		return true, 0
+	}
+	return false, 0
+}
+
+// releaseMomentary handles the release requested by momentaryThink(), and
+// returns true iff that is what cause is.
+func (gs *gestureKeyState) releaseMomentary(amt float64, ms int64, cause Event, event *Event) bool {
+	if cause.Key != nil || amt != 0 {
+		return false
+	}
+	if gs.IsDown() {
+		event.Type = Release
+		gs.keyState.aggregator.SetPressAmt(0, ms, event.Type)
+	}
+	return true
+}
+
+// A tapKey is pressed for a single frame when a contact lifts shortly after
+// touching down, without having moved far.
+type tapKey struct {
+	gestureKeyState
+	max_ms   int64
+	max_dist float64
+}
+
+// BindTapKey creates a key that is pressed when a contact on device lifts no
+// more than max_ms after it touched down, and without ever having moved more
+// than max_dist from where it touched down.  The key is released at the end of
+// the frame in which it is pressed, and its Cursor is where the tap happened.
+func (input *Input) BindTapKey(name string, device DeviceId, max_ms int64, max_dist float64) Key {
+	input.mutex.Lock()
+	defer input.mutex.Unlock()
+	tk := &tapKey{
+		gestureKeyState: input.makeGestureKeyState(name, device),
+		max_ms:          max_ms,
+		max_dist:        max_dist,
+	}
+	input.addGestureKey(tk, device)
+	return tk
+}
+
+func (k *tapKey) SetPressAmt(amt float64, ms int64, cause Event) (event Event) {
+	event.Type = NoEvent
+	event.Key = &k.keyState
+	if k.releaseMomentary(amt, ms, cause, &event) {
+		return
+	}
+	k.track(ms, func(tk *touchKey, start *touchStart) {
+		if !start.done && ms-start.ms <= k.max_ms && start.dist(tk) <= k.max_dist {
+			k.press(start.x, start.y, ms, &event)
+		}
+	})
+	for tk, start := range k.starts {
+		if start.dist(tk) > k.max_dist {
+			start.done = true
+		}
+	}
+	return
+}
+
+func (k *tapKey) Think(ms int64) (bool, float64) {
+	return k.momentaryThink(ms)
+}
+
+type SwipeDirection int
+
+// Directions are in window coordinates, so up is towards the top of the
+// window.
+const (
+	SwipeUp SwipeDirection = iota
+	SwipeDown
+	SwipeLeft
+	SwipeRight
+)
+
+func (d SwipeDirection) String() string {
+	switch d {
+	case SwipeUp:
+		return "up"
+	case SwipeDown:
+		return "down"
+	case SwipeLeft:
+		return "left"
+	case SwipeRight:
+		return "right"
+	}
+	panic(fmt.Sprintf("%d is not a valid SwipeDirection", d))
+}
+
+// split returns how far dx, dy goes in direction d, and how far it goes
+// perpendicular to d.
+func (d SwipeDirection) split(dx, dy int) (along, across float64) {
+	switch d {
+	case SwipeUp:
+		return float64(dy), math.Abs(float64(dx))
+	case SwipeDown:
+		return float64(-dy), math.Abs(float64(dx))
+	case SwipeLeft:
+		return float64(-dx), math.Abs(float64(dy))
+	case SwipeRight:
+		return float64(dx), math.Abs(float64(dy))
+	}
+	panic(fmt.Sprintf("%d is not a valid SwipeDirection", d))
+}
+
+// A swipeKey is pressed for a single frame when a contact moves quickly in a
+// particular direction.
+type swipeKey struct {
+	gestureKeyState
+	direction SwipeDirection
+	min_dist  float64
+	max_ms    int64
+}
+
+// BindSwipeKey creates a key that is pressed when a contact on device moves at
+// least min_dist in the specified direction within max_ms of touching down,
+// and moves further in that direction than in any other.  Each contact can
+// swipe at most once.  The key is released at the end of the frame in which
+// it is pressed, and its Cursor is where the swipe started.
+func (input *Input) BindSwipeKey(name string, device DeviceId, direction SwipeDirection, min_dist float64, max_ms int64) Key {
+	input.mutex.Lock()
+	defer input.mutex.Unlock()
+	sk := &swipeKey{
+		gestureKeyState: input.makeGestureKeyState(name, device),
+		direction:       direction,
+		min_dist:        min_dist,
+		max_ms:          max_ms,
+	}
+	input.addGestureKey(sk, device)
+	return sk
+}
+
+func (k *swipeKey) SetPressAmt(amt float64, ms int64, cause Event) (event Event) {
+	event.Type = NoEvent
+	event.Key = &k.keyState
+	if k.releaseMomentary(amt, ms, cause, &event) {
+		return
+	}
+	k.track(ms, func(*touchKey, *touchStart) {})
+	for tk, start := range k.starts {
+		if start.done {
+			continue
+		}
+		if ms-start.ms > k.max_ms {
+			start.done = true
+			continue
+		}
+		along, across := k.direction.split(tk.cursor.X-start.x, tk.cursor.Y-start.y)
+		if along >= k.min_dist && along > across {
+			start.done = true
+			k.press(start.x, start.y, ms, &event)
+		}
+	}
+	return
+}
+
+func (k *swipeKey) Think(ms int64) (bool, float64) {
+	return k.momentaryThink(ms)
+}
+
+// A pinchKey follows the distance between two contacts.
+type pinchKey struct {
+	gestureKeyState
+	threshold float64
+
+	// The two contacts being followed, if any, and the distance between them
+	// when the second one touched down.
+	a, b       *touchKey
+	a_id, b_id int
+	initial    float64
+}
+
+// BindPinchKey creates a key that follows the first two contacts on device.
+// Its press amount is the distance between them divided by the distance
+// between them when the second one touched down, so it is less than 1 while
+// pinching in and greater than 1 while spreading out.  The key is pressed once
+// that ratio differs from 1 by at least threshold, and is released when either
+// contact lifts.  Its Cursor is halfway between the two contacts.
+func (input *Input) BindPinchKey(name string, device DeviceId, threshold float64) Key {
+	input.mutex.Lock()
+	defer input.mutex.Unlock()
+	pk := &pinchKey{
+		gestureKeyState: input.makeGestureKeyState(name, device),
+		threshold:       threshold,
+	}
+	input.addGestureKey(pk, device)
+	return pk
+}
+
+func contactDist(a, b *touchKey) float64 {
+	return math.Hypot(float64(a.cursor.X-b.cursor.X), float64(a.cursor.Y-b.cursor.Y))
+}
+
+func (k *pinchKey) active(tk *touchKey, id int) bool {
+	return tk.IsDown() && tk.contact == id
+}
+
+func (k *pinchKey) SetPressAmt(amt float64, ms int64, cause Event) (event Event) {
+	event.Type = NoEvent
+	event.Key = &k.keyState
+	if k.a != nil && (!k.active(k.a, k.a_id) || !k.active(k.b, k.b_id)) {
+		k.a, k.b = nil, nil
+		if k.IsDown() {
+			event.Type = Release
+			k.keyState.aggregator.SetPressAmt(0, ms, event.Type)
+		}
+		return
+	}
+	if k.a == nil {
+		var down []*touchKey
+		for _, tk := range k.input.touchKeys(k.device) {
+			if tk.IsDown() {
+				down = append(down, tk)
+			}
+		}
+		if len(down) < 2 {
+			return
+		}
+		k.a, k.b = down[0], down[1]
+		k.a_id, k.b_id = k.a.contact, k.b.contact
+		k.initial = math.Max(contactDist(k.a, k.b), 1)
+		return
+	}
+	k.cursor.X = (k.a.cursor.X + k.b.cursor.X) / 2
+	k.cursor.Y = (k.a.cursor.Y + k.b.cursor.Y) / 2
+	ratio := math.Max(contactDist(k.a, k.b)/k.initial, 1e-3)
+	if !k.IsDown() {
+		if math.Abs(ratio-1) < k.threshold {
+			return
+		}
+		event.Type = Press
+	} else if ratio != k.CurPressAmt() {
+		event.Type = Adjust
+	}
+	k.keyState.aggregator.SetPressAmt(ratio, ms, event.Type)
+	return
+}
@@ -2,7 +2,8 @@ package gin
 
 import (
 	"fmt"
-	"github.com/runningwild/glop/util/algorithm"
+	"strings"
+	"sync"
 )
 
 var (
@@ -239,6 +240,47 @@ const (
 	DeleteOrBackspace
 )
 
+// These are kept out of the const block above so that they don't change the
+// values of the standard derived keys, which are numbered with iota.
+const (
+	// Standard gamepad keys, these are reported in addition to the raw
+	// controller keys above on controllers that have a GamepadMapping.  The
+	// buttons are named after their positions on an Xbox controller, so
+	// GamepadA is the bottom face button.
+	GamepadA             = 95000
+	GamepadB             = 95001
+	GamepadX             = 95002
+	GamepadY             = 95003
+	GamepadBack          = 95004
+	GamepadGuide         = 95005
+	GamepadStart         = 95006
+	GamepadLeftStick     = 95007
+	GamepadRightStick    = 95008
+	GamepadLeftShoulder  = 95009
+	GamepadRightShoulder = 95010
+	GamepadDpadUp        = 95011
+	GamepadDpadDown      = 95012
+	GamepadDpadLeft      = 95013
+	GamepadDpadRight     = 95014
+	GamepadMisc1         = 95015
+
+	// Standard gamepad axes.  The sticks are in [-1, 1], with up being
+	// negative, and the triggers are in [0, 1].
+	LeftStickX   = 95100
+	LeftStickY   = 95101
+	RightStickX  = 95102
+	RightStickY  = 95103
+	LeftTrigger  = 95104
+	RightTrigger = 95105
+)
+
+const (
+	// TouchContact0 + N is the key for the Nth simultaneous contact on a touch
+	// device, see MaxTouchContacts.  Each contact's press amount is its
+	// pressure and its Cursor is its position.
+	TouchContact0 = 96000
+)
+
 type Cursor interface {
 	Name() string
 	Point() (int, int)
@@ -263,11 +305,24 @@ type OsEvent struct {
 	KeyId     KeyId
 	Press_amt float64
 	Timestamp int64
+
+	// Window coordinates, with the origin at the lower-left corner of the
+	// window, for keys that have a Cursor.  Only touch contacts use these.
+	X, Y int
 }
 
 // Everything 'global' is put inside a struct so that tests can be run without stepping
 // on each other
+//
+// All methods on Input are safe to call from multiple goroutines.  Listeners
+// are called without the lock held, so they can query and modify the Input
+// freely.  Keys are not synchronized, so querying the state of a Key is only
+// safe from the goroutine that calls Think(), and from listeners.  Other
+// goroutines should use Snapshot() instead.
 type Input struct {
+	// Protects everything below.
+	mutex sync.Mutex
+
 	all_keys []Key
 	key_map  map[KeyId]Key
 
@@ -289,10 +344,40 @@ type Input struct {
 	// map from KeyIndex to a human-readable name for that key
 	index_to_name map[KeyIndex]string
 
-	// The listeners will receive all events immediately after those events have been used to
-	// update all key states.  The order in which listeners are notified of a particular event
-	// group can change from group to group.
-	listeners []Listener
+	// map from lower-cased key and key family names to their KeyIndex, used
+	// for parsing bindings from text
+	name_to_index map[string]KeyIndex
+
+	// Listeners receive all events immediately after those events have been used
+	// to update all key states.  Listeners are grouped into contexts, see
+	// InputContext, and contexts are kept sorted in the order in which they
+	// receive events.  The default context holds listeners that were registered
+	// directly with the Input.
+	contexts        []*InputContext
+	default_context *InputContext
+	context_seq     int
+
+	// If set, every call to Think() is recorded here before it is processed,
+	// along with the device events dispatched since the previous call.
+	recorder               *Recorder
+	recorded_device_events []DeviceEvent
+
+	// Key states as of the end of the most recent call to Think().
+	snapshot *Snapshot
+
+	// Processing for controller axes, see SetAxisConfig() and SetStickConfig(),
+	// and the last raw value seen for each axis.
+	axis_configs map[axisId]AxisConfig
+	sticks       map[axisId]*stick
+	axis_raw     map[axisId]float64
+
+	// Mappings from raw controller keys to the standard gamepad keys, see
+	// SetGamepadMapping() and SetGamepadDatabase().
+	gamepad_db *GamepadDatabase
+	gamepads   map[DeviceId]*gamepadState
+
+	// Incremented each time a touch contact begins, see Contact.Id.
+	touch_seq int
 }
 
 // The standard input object
@@ -317,8 +402,15 @@ func Make() *Input {
 	input.id_to_deps = make(map[KeyId][]Key, 16)
 	input.index_to_agg_type = make(map[KeyIndex]aggregatorType)
 	input.index_to_name = make(map[KeyIndex]string)
+	input.name_to_index = make(map[string]KeyIndex)
+	input.axis_configs = make(map[axisId]AxisConfig)
+	input.sticks = make(map[axisId]*stick)
+	input.axis_raw = make(map[axisId]float64)
+	input.gamepads = make(map[DeviceId]*gamepadState)
 	input.index_to_family_deps = make(map[KeyIndex][]derivedKeyFamily)
 	input.index_to_family = make(map[KeyIndex]derivedKeyFamily)
+	input.default_context = input.MakeInputContext("default", 0, false)
+	input.PushContext(input.default_context)
 
 	input.registerKeyIndex(AnyKey, aggregatorTypeStandard, "AnyKey")
 	for c := 'a'; c <= 'z'; c++ {
@@ -412,6 +504,14 @@ func Make() *Input {
 	input.registerKeyIndex(ControllerHatSwitchLeft, aggregatorTypeStandard, "HatSwitchLeft")
 	input.registerKeyIndex(ControllerHatSwitchUpLeft, aggregatorTypeStandard, "HatSwitchUpLeft")
 
+	for _, output := range gamepad_outputs {
+		input.registerKeyIndex(output.index, aggregatorTypeStandard, output.name)
+	}
+
+	for i := 0; i < MaxTouchContacts; i++ {
+		input.registerKeyIndex(TouchContact0+KeyIndex(i), aggregatorTypeStandard, fmt.Sprintf("Touch %d", i))
+	}
+
 	input.bindDerivedKeyFamilyWithIndex(
 		"EitherShift",
 		EitherShift,
@@ -535,10 +635,13 @@ func (input *Input) registerKeyIndex(index KeyIndex, agg_type aggregatorType, na
 	}
 	input.index_to_agg_type[index] = agg_type
 	input.index_to_name[index] = name
+	input.name_to_index[strings.ToLower(name)] = index
 }
 
 func (input *Input) GetKeyFlat(key_index KeyIndex, device_type DeviceType, device_index DeviceIndex) Key {
-	return input.GetKey(KeyId{
+	input.mutex.Lock()
+	defer input.mutex.Unlock()
+	return input.getKey(KeyId{
 		Index: key_index,
 		Device: DeviceId{
 			Index: device_index,
@@ -548,6 +651,12 @@ func (input *Input) GetKeyFlat(key_index KeyIndex, device_type DeviceType, devic
 }
 
 func (input *Input) GetKey(id KeyId) Key {
+	input.mutex.Lock()
+	defer input.mutex.Unlock()
+	return input.getKey(id)
+}
+
+func (input *Input) getKey(id KeyId) Key {
 	if id.Device.Type >= DeviceTypeMax || id.Device.Type < 0 {
 		panic(fmt.Sprintf("Specied invalid DeviceType, %d.", id.Device))
 	}
@@ -597,11 +706,17 @@ func (input *Input) GetKey(id KeyId) Key {
 			default:
 				panic(fmt.Sprintf("Unknown aggregator type specified: %T.", agg_type))
 			}
-			input.key_map[id] = &keyState{
+			ks := keyState{
 				id:         id,
 				name:       input.index_to_name[id.Index],
 				aggregator: agg,
 			}
+			if id.Device.Type == DeviceTypeTouch && isTouchContact(id.Index) {
+				ks.cursor = &cursor{name: ks.name}
+				input.key_map[id] = &touchKey{keyState: ks, input: input}
+			} else {
+				input.key_map[id] = &ks
+			}
 			key = input.key_map[id]
 			input.all_keys = append(input.all_keys, key)
 		}
@@ -609,6 +724,8 @@ func (input *Input) GetKey(id KeyId) Key {
 	return key
 }
 func (input *Input) GetKeyByName(name string) Key {
+	input.mutex.Lock()
+	defer input.mutex.Unlock()
 	for _, key := range input.key_map {
 		if key.Name() == name {
 			return key
@@ -640,12 +757,13 @@ func (input *Input) pressKey(k Key, amt float64, cause Event, group *EventGroup)
 	event := k.SetPressAmt(amt, group.Timestamp, cause)
 	input.informDeps(event, group)
 	if k.Id().Index != AnyKey && k.Id().Device.Type != DeviceTypeAny && k.Id().Device.Type != DeviceTypeDerived && k.Id().Device.Index != DeviceIndexAny {
+		device := k.Id().Device
 		general_keys := []Key{
-			input.GetKeyFlat(AnyKey, k.Id().Device.Type, k.Id().Device.Index),
-			input.GetKeyFlat(AnyKey, k.Id().Device.Type, DeviceIndexAny),
-			input.GetKeyFlat(AnyKey, DeviceTypeAny, DeviceIndexAny),
-			input.GetKeyFlat(k.Id().Index, k.Id().Device.Type, DeviceIndexAny),
-			input.GetKeyFlat(k.Id().Index, DeviceTypeAny, DeviceIndexAny),
+			input.getKey(KeyId{Index: AnyKey, Device: device}),
+			input.getKey(KeyId{Index: AnyKey, Device: DeviceId{Type: device.Type, Index: DeviceIndexAny}}),
+			input.getKey(KeyId{Index: AnyKey, Device: DeviceId{Type: DeviceTypeAny, Index: DeviceIndexAny}}),
+			input.getKey(KeyId{Index: k.Id().Index, Device: DeviceId{Type: device.Type, Index: DeviceIndexAny}}),
+			input.getKey(KeyId{Index: k.Id().Index, Device: DeviceId{Type: DeviceTypeAny, Index: DeviceIndexAny}}),
 		}
 		for _, general_key := range general_keys {
 			input.pressKey(general_key, amt, cause, group)
@@ -671,16 +789,30 @@ type EventDispatcher interface {
 	UnregisterEventListener(Listener)
 }
 
-// TODO: These two functions should be synchronized
 func (input *Input) RegisterEventListener(listener Listener) {
-	input.listeners = append(input.listeners, listener)
+	input.default_context.RegisterEventListener(listener)
 }
 
 func (input *Input) UnregisterEventListener(listener Listener) {
-	algorithm.Choose(&input.listeners, func(l Listener) bool { return l != listener })
+	input.default_context.UnregisterEventListener(listener)
 }
 
+// Think processes all of the events that happened since the last call to Think().
+// Listeners are notified of each event group as it is processed, and the lock
+// is released while they are, so listeners may call back into the Input.
 func (input *Input) Think(t int64, has_focus bool, os_events []OsEvent) []EventGroup {
+	input.mutex.Lock()
+	defer input.mutex.Unlock()
+	if input.recorder != nil {
+		input.recorder.Record(Frame{
+			Horizon:      t,
+			HasFocus:     has_focus,
+			DeviceEvents: input.recorded_device_events,
+			Events:       os_events,
+		})
+		input.recorded_device_events = nil
+	}
+
 	// If we have lost focus, clear all key state.
 	if !has_focus {
 		// clearAllKeyState()
@@ -706,20 +838,35 @@ func (input *Input) Think(t int64, has_focus bool, os_events []OsEvent) []EventG
 		group := EventGroup{
 			Timestamp: os_event.Timestamp,
 		}
-		input.pressKey(
-			input.GetKey(os_event.KeyId),
-			os_event.Press_amt,
-			Event{},
-			&group)
+		for _, processed := range input.processAxisEvent(os_event) {
+			key := input.getKey(processed.KeyId)
+			if tk, ok := key.(*touchKey); ok && processed.Press_amt != 0 {
+				tk.moveTo(processed.X, processed.Y)
+			}
+			input.pressKey(
+				key,
+				processed.Press_amt,
+				Event{},
+				&group)
+			for _, mapped := range input.mapGamepadEvent(processed) {
+				input.pressKey(
+					input.getKey(mapped.KeyId),
+					mapped.Press_amt,
+					Event{},
+					&group)
+			}
+		}
 		if len(group.Events) > 0 {
 			groups = append(groups, group)
-			for _, listener := range input.listeners {
-				listener.HandleEventGroup(group)
-			}
+			input.unlockedDispatch(group)
 		}
 	}
 
-	for _, key := range input.all_keys {
+	// Listeners may bind or unbind keys, so iterate over a copy.
+	for _, key := range append([]Key(nil), input.all_keys...) {
+		if input.key_map[key.Id()] != key {
+			continue
+		}
 		gen, amt := key.Think(t)
 		if !gen {
 			continue
@@ -728,14 +875,11 @@ func (input *Input) Think(t int64, has_focus bool, os_events []OsEvent) []EventG
 		input.pressKey(key, amt, Event{}, &group)
 		if len(group.Events) > 0 {
 			groups = append(groups, group)
-			for _, listener := range input.listeners {
-				listener.HandleEventGroup(group)
-			}
+			input.unlockedDispatch(group)
 		}
 	}
 
-	for _, listener := range input.listeners {
-		listener.Think()
-	}
+	input.snapshot = input.makeSnapshot(t)
+	input.unlockedThink(input.allListeners())
 	return groups
 }
@@ -256,9 +256,28 @@ const (
 	DeviceTypeMouse
 	DeviceTypeController
 	DeviceTypeDerived
+	DeviceTypeTouch
 	DeviceTypeMax
 )
 
+func (dt DeviceType) String() string {
+	switch dt {
+	case DeviceTypeAny:
+		return "any"
+	case DeviceTypeKeyboard:
+		return "keyboard"
+	case DeviceTypeMouse:
+		return "mouse"
+	case DeviceTypeController:
+		return "controller"
+	case DeviceTypeDerived:
+		return "derived"
+	case DeviceTypeTouch:
+		return "touch"
+	}
+	return fmt.Sprintf("DeviceType(%d)", int(dt))
+}
+
 // natural keys and derived keys all embed a keyState
 type keyState struct {
 	id     KeyId   // Unique id among all keys ever
@@ -0,0 +1,570 @@
+package gin
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"sync"
+)
+
+// Lockstep multiplayer runs the same simulation on every peer, so every peer's
+// Input must see exactly the same Frames.  Each peer sends its own OsEvents for
+// every frame to every other peer as a Packet, and a Lockstep merges the
+// Packets from all peers into a single Frame once they have all arrived.
+// Every peer's devices are given distinct DeviceIndexes, see PeerDevice(), so
+// each player shows up as their own keyboard, mouse, etc.  DeviceEvents are
+// sent the same way, so every peer gives a connected controller the same
+// gamepad mapping.
+
+// PacketVersion is the version of the format written by AppendPacket().
+const PacketVersion = 2
+
+const packetMagic = 'g'
+
+// PeerDeviceStride is the number of DeviceIndexes reserved for each peer.  A
+// peer can only send events from devices with indexes in [0, PeerDeviceStride).
+const PeerDeviceStride = 1000
+
+// A Packet holds all of the OsEvents and DeviceEvents from a single peer for a
+// single frame.
+type Packet struct {
+	Peer  int
+	Frame int64
+
+	// Horizon that the peer passed to Lockstep.Local() for this frame.  The
+	// timestamps of Events are no later than this.
+	Horizon int64
+
+	DeviceEvents []DeviceEvent
+	Events       []OsEvent
+}
+
+// Flags that describe how each event is encoded.
+const (
+	packetAmtZero = 1 << iota
+	packetAmtOne
+	packetCursor
+)
+
+// AppendPacket appends the encoding of p to b.  Timestamps are encoded
+// relative to the horizon, and press amounts of 0 and 1, which is almost all
+// of them, don't take any space, so most events take only a handful of bytes.
+func AppendPacket(b []byte, p Packet) []byte {
+	var buf [binary.MaxVarintLen64]byte
+	uvarint := func(v uint64) {
+		b = append(b, buf[0:binary.PutUvarint(buf[:], v)]...)
+	}
+	varint := func(v int64) {
+		b = append(b, buf[0:binary.PutVarint(buf[:], v)]...)
+	}
+	b = append(b, packetMagic, PacketVersion)
+	uvarint(uint64(p.Peer))
+	varint(p.Frame)
+	varint(p.Horizon)
+	uvarint(uint64(len(p.DeviceEvents)))
+	for _, e := range p.DeviceEvents {
+		uvarint(uint64(e.Type))
+		uvarint(uint64(e.Device.Type))
+		varint(int64(e.Device.Index))
+		varint(e.Timestamp - p.Horizon)
+		uvarint(uint64(len(e.Name)))
+		b = append(b, e.Name...)
+		uvarint(uint64(len(e.GUID)))
+		b = append(b, e.GUID...)
+	}
+	uvarint(uint64(len(p.Events)))
+	for _, e := range p.Events {
+		var flags uint64
+		switch e.Press_amt {
+		case 0:
+			flags |= packetAmtZero
+		case 1:
+			flags |= packetAmtOne
+		}
+		if e.X != 0 || e.Y != 0 {
+			flags |= packetCursor
+		}
+		uvarint(flags)
+		varint(int64(e.KeyId.Index))
+		uvarint(uint64(e.KeyId.Device.Type))
+		varint(int64(e.KeyId.Device.Index))
+		varint(e.Timestamp - p.Horizon)
+		if flags&(packetAmtZero|packetAmtOne) == 0 {
+			binary.LittleEndian.PutUint64(buf[0:8], math.Float64bits(e.Press_amt))
+			b = append(b, buf[0:8]...)
+		}
+		if flags&packetCursor != 0 {
+			varint(int64(e.X))
+			varint(int64(e.Y))
+		}
+	}
+	return b
+}
+
+// packetReader decodes values from a packet, and remembers the first error.
+type packetReader struct {
+	b   []byte
+	err error
+}
+
+func (r *packetReader) fail() {
+	if r.err == nil {
+		r.err = fmt.Errorf("Packet is truncated or corrupt.")
+	}
+	r.b = nil
+}
+
+func (r *packetReader) uvarint() uint64 {
+	v, n := binary.Uvarint(r.b)
+	if n <= 0 {
+		r.fail()
+		return 0
+	}
+	r.b = r.b[n:]
+	return v
+}
+
+func (r *packetReader) varint() int64 {
+	v, n := binary.Varint(r.b)
+	if n <= 0 {
+		r.fail()
+		return 0
+	}
+	r.b = r.b[n:]
+	return v
+}
+
+func (r *packetReader) string() string {
+	n := r.uvarint()
+	if n > uint64(len(r.b)) {
+		r.fail()
+		return ""
+	}
+	v := string(r.b[0:n])
+	r.b = r.b[n:]
+	return v
+}
+
+func (r *packetReader) float64() float64 {
+	if len(r.b) < 8 {
+		r.fail()
+		return 0
+	}
+	v := math.Float64frombits(binary.LittleEndian.Uint64(r.b[0:8]))
+	r.b = r.b[8:]
+	return v
+}
+
+// ParsePacket decodes a packet that was encoded with AppendPacket().
+func ParsePacket(b []byte) (Packet, error) {
+	var p Packet
+	if len(b) < 2 || b[0] != packetMagic {
+		return p, fmt.Errorf("Not a gin packet.")
+	}
+	if b[1] != PacketVersion {
+		return p, fmt.Errorf("Cannot read gin packet version %d, expected version %d.", b[1], PacketVersion)
+	}
+	r := packetReader{b: b[2:]}
+	p.Peer = int(r.uvarint())
+	p.Frame = r.varint()
+	p.Horizon = r.varint()
+	n := r.uvarint()
+	// Every device event takes at least six bytes.
+	if n > uint64(len(r.b)/6) {
+		r.fail()
+	}
+	for i := uint64(0); i < n && r.err == nil; i++ {
+		var e DeviceEvent
+		e.Type = DeviceEventType(r.uvarint())
+		e.Device.Type = DeviceType(r.uvarint())
+		e.Device.Index = DeviceIndex(r.varint())
+		e.Timestamp = p.Horizon + r.varint()
+		e.Name = r.string()
+		e.GUID = r.string()
+		p.DeviceEvents = append(p.DeviceEvents, e)
+	}
+	n = r.uvarint()
+	// Every event takes at least five bytes, so this stops a corrupt count
+	// from allocating a huge slice.
+	if n > uint64(len(r.b)/5) {
+		r.fail()
+	}
+	for i := uint64(0); i < n && r.err == nil; i++ {
+		var e OsEvent
+		flags := r.uvarint()
+		e.KeyId.Index = KeyIndex(r.varint())
+		e.KeyId.Device.Type = DeviceType(r.uvarint())
+		e.KeyId.Device.Index = DeviceIndex(r.varint())
+		e.Timestamp = p.Horizon + r.varint()
+		switch {
+		case flags&packetAmtZero != 0:
+			e.Press_amt = 0
+		case flags&packetAmtOne != 0:
+			e.Press_amt = 1
+		default:
+			e.Press_amt = r.float64()
+		}
+		if flags&packetCursor != 0 {
+			e.X = int(r.varint())
+			e.Y = int(r.varint())
+		}
+		p.Events = append(p.Events, e)
+	}
+	if r.err == nil && len(r.b) != 0 {
+		r.err = fmt.Errorf("Packet has %d extra bytes.", len(r.b))
+	}
+	if r.err != nil {
+		return Packet{}, r.err
+	}
+	return p, nil
+}
+
+// Packets sent over a stream are prefixed with their length.  Nothing
+// legitimate comes anywhere close to this size.
+const maxPacketSize = 1 << 20
+
+// WritePacket writes p to w, prefixed with its length, so that it can be read
+// back from a stream with ReadPacket().
+func WritePacket(w io.Writer, p Packet) error {
+	b := AppendPacket(make([]byte, 4, 64), p)
+	binary.LittleEndian.PutUint32(b[0:4], uint32(len(b)-4))
+	_, err := w.Write(b)
+	return err
+}
+
+// ReadPacket reads a single packet that was written with WritePacket().
+func ReadPacket(r io.Reader) (Packet, error) {
+	var size [4]byte
+	if _, err := io.ReadFull(r, size[:]); err != nil {
+		return Packet{}, err
+	}
+	n := binary.LittleEndian.Uint32(size[:])
+	if n > maxPacketSize {
+		return Packet{}, fmt.Errorf("Packet of %d bytes is too large, the limit is %d.", n, maxPacketSize)
+	}
+	b := make([]byte, n)
+	if _, err := io.ReadFull(r, b); err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return Packet{}, err
+	}
+	return ParsePacket(b)
+}
+
+// PeerDevice returns the device that device on the specified peer appears as
+// in the Frames returned by a Lockstep.
+func PeerDevice(peer int, device DeviceId) DeviceId {
+	device.Index += DeviceIndex(peer * PeerDeviceStride)
+	return device
+}
+
+// DevicePeer is the inverse of PeerDevice(), it returns the peer that a device
+// in a Frame returned by a Lockstep belongs to, and that device's id on that
+// peer.
+func DevicePeer(device DeviceId) (int, DeviceId) {
+	peer := int(device.Index) / PeerDeviceStride
+	device.Index -= DeviceIndex(peer * PeerDeviceStride)
+	return peer, device
+}
+
+// A Lockstep collects the Packets from every peer and merges them into Frames.
+// Peers are numbered from 0, and every peer must call Local() once per frame.
+// Packets from other peers can be passed to Receive() from any goroutine.
+//
+// The Frames returned by Next() are the same on every peer: events from every
+// peer's Packet are moved to that peer's devices and merged in timestamp
+// order, with ties going to the lower numbered peer.  Since peers' clocks
+// differ, each Frame's horizon is the latest horizon of all of its Packets,
+// and events are shifted by the same amount that their Packet's horizon is.
+// Events that would still be at or before the previous Frame's horizon are
+// moved just after it, so every Frame keeps Input.Think()'s promise that its
+// events are newer than the last horizon.
+type Lockstep struct {
+	mutex sync.Mutex
+
+	local int
+	peers int
+	delay int64
+
+	// Number of the frame that the next call to Local() will send.
+	frame int64
+
+	// Number of the frame that the next call to Next() will return, and the
+	// horizon of the frame before that.
+	next    int64
+	horizon int64
+
+	// Packets that have arrived for frames that haven't been returned by
+	// Next() yet, indexed by peer.
+	pending map[int64][]*Packet
+
+	// Local keys that were down as of the last packet sent.
+	down map[KeyId]bool
+}
+
+// MakeLockstep returns a Lockstep for peer local out of peers.  Local input is
+// delayed by delay frames, which gives packets that long to reach the other
+// peers before anyone has to wait for them.  The first delay Frames are empty
+// on every peer.
+func MakeLockstep(local, peers, delay int) *Lockstep {
+	if peers < 1 || local < 0 || local >= peers {
+		panic(fmt.Sprintf("Cannot be peer %d out of %d.", local, peers))
+	}
+	if delay < 0 {
+		panic(fmt.Sprintf("Input delay must not be negative, not %d.", delay))
+	}
+	l := &Lockstep{
+		local:   local,
+		peers:   peers,
+		frame:   int64(delay),
+		delay:   int64(delay),
+		pending: make(map[int64][]*Packet),
+		down:    make(map[KeyId]bool),
+	}
+	for frame := int64(0); frame < int64(delay); frame++ {
+		for peer := 0; peer < peers; peer++ {
+			l.add(&Packet{Peer: peer, Frame: frame})
+		}
+	}
+	return l
+}
+
+func (l *Lockstep) add(p *Packet) {
+	packets := l.pending[p.Frame]
+	if packets == nil {
+		packets = make([]*Packet, l.peers)
+		l.pending[p.Frame] = packets
+	}
+	packets[p.Peer] = p
+}
+
+// checkPacketEvents returns an error if any of the events can't be sent in a
+// Packet.  Only events from natural keys on devices with indexes in
+// [0, PeerDeviceStride) can be sent.
+func checkPacketEvents(device_events []DeviceEvent, events []OsEvent) error {
+	for _, e := range device_events {
+		if e.Type != DeviceConnected && e.Type != DeviceDisconnected {
+			return fmt.Errorf("Cannot send device events of type %d.", int(e.Type))
+		}
+		if err := checkPacketDevice(e.Device); err != nil {
+			return err
+		}
+	}
+	for _, e := range events {
+		if !e.KeyId.IsNatural() {
+			return fmt.Errorf("Cannot send events for key %v, it is not a natural key.", e.KeyId)
+		}
+		if err := checkPacketDevice(e.KeyId.Device); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func checkPacketDevice(device DeviceId) error {
+	if device.Type <= DeviceTypeAny || device.Type >= DeviceTypeMax {
+		return fmt.Errorf("Cannot send events from device type %d.", int(device.Type))
+	}
+	if device.Index < 0 || device.Index >= PeerDeviceStride {
+		return fmt.Errorf("Cannot send events from device index %d, indexes must be in [0, %d).", device.Index, PeerDeviceStride)
+	}
+	return nil
+}
+
+// releaseDown returns releases, at time t, for every local key that is down
+// and that matches.
+func (l *Lockstep) releaseDown(t int64, matches func(KeyId) bool) []OsEvent {
+	var ids []KeyId
+	for id := range l.down {
+		if matches(id) {
+			ids = append(ids, id)
+		}
+	}
+	sort.Slice(ids, func(i, j int) bool {
+		a, b := ids[i], ids[j]
+		if a.Device != b.Device {
+			if a.Device.Type != b.Device.Type {
+				return a.Device.Type < b.Device.Type
+			}
+			return a.Device.Index < b.Device.Index
+		}
+		return a.Index < b.Index
+	})
+	var releases []OsEvent
+	for _, id := range ids {
+		releases = append(releases, OsEvent{KeyId: id, Press_amt: 0, Timestamp: t})
+	}
+	return releases
+}
+
+// Local numbers the local peer's events for its next frame and returns the
+// Packet that must be sent to every other peer.  device_events are what would
+// normally be passed to Input.DispatchDeviceEvents(), and horizon, has_focus
+// and events are what would normally be passed to Input.Think().  Device
+// events reach Input through the Frames like everything else, so they should
+// not also be dispatched locally.  When has_focus is false the events are
+// replaced with releases for every key that is still down, and keys that are
+// still down on a disconnected device are released at the horizon, so other
+// peers don't see this peer's keys stuck down.
+func (l *Lockstep) Local(horizon int64, has_focus bool, device_events []DeviceEvent, events []OsEvent) (Packet, error) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	if !has_focus {
+		events = l.releaseDown(horizon, func(KeyId) bool { return true })
+	}
+	if err := checkPacketEvents(device_events, events); err != nil {
+		return Packet{}, err
+	}
+	events = append([]OsEvent(nil), events...)
+	for _, e := range events {
+		if e.Press_amt != 0 {
+			l.down[e.KeyId] = true
+		} else {
+			delete(l.down, e.KeyId)
+		}
+	}
+	for _, d := range device_events {
+		if d.Type != DeviceDisconnected {
+			continue
+		}
+		releases := l.releaseDown(horizon, func(id KeyId) bool { return id.Device == d.Device })
+		for _, e := range releases {
+			delete(l.down, e.KeyId)
+		}
+		events = append(events, releases...)
+	}
+	p := Packet{
+		Peer:         l.local,
+		Frame:        l.frame,
+		Horizon:      horizon,
+		DeviceEvents: append([]DeviceEvent(nil), device_events...),
+		Events:       events,
+	}
+	l.frame++
+	l.add(&p)
+	return p, nil
+}
+
+// MaxFrameLead is how many frames beyond what it could legitimately have sent a
+// peer's Packets can be before Receive() rejects them.  A peer can't get more
+// than delay frames ahead of the local peer, since it needs the local peer's
+// Packets to advance, so this only leaves room for frames that were sent
+// before Local() was called here.
+const MaxFrameLead = 8
+
+// Receive stores a Packet from another peer.  Packets for frames too far in
+// the future are rejected, so a misbehaving peer can't make pending Packets
+// pile up without bound.
+func (l *Lockstep) Receive(p Packet) error {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	if p.Peer < 0 || p.Peer >= l.peers || p.Peer == l.local {
+		return fmt.Errorf("Received a packet from peer %d, which is not a remote peer.", p.Peer)
+	}
+	if p.Frame < l.next {
+		return fmt.Errorf("Received frame %d from peer %d after it was processed.", p.Frame, p.Peer)
+	}
+	if limit := l.frame + l.delay + MaxFrameLead; p.Frame > limit {
+		return fmt.Errorf("Received frame %d from peer %d, but no peer can be past frame %d yet.", p.Frame, p.Peer, limit)
+	}
+	if packets := l.pending[p.Frame]; packets != nil && packets[p.Peer] != nil {
+		return fmt.Errorf("Received frame %d from peer %d twice.", p.Frame, p.Peer)
+	}
+	if err := checkPacketEvents(p.DeviceEvents, p.Events); err != nil {
+		return err
+	}
+	l.add(&p)
+	return nil
+}
+
+// ReceiveFrom reads Packets from r and passes them to Receive() until an error
+// occurs, which it returns.  A stream that ends cleanly returns io.EOF.
+func (l *Lockstep) ReceiveFrom(r io.Reader) error {
+	for {
+		p, err := ReadPacket(r)
+		if err != nil {
+			return err
+		}
+		if err := l.Receive(p); err != nil {
+			return err
+		}
+	}
+}
+
+// Waiting returns the peers whose Packets for the next frame haven't arrived
+// yet.  Next() returns a Frame once this is empty.
+func (l *Lockstep) Waiting() []int {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	var waiting []int
+	packets := l.pending[l.next]
+	for peer := 0; peer < l.peers; peer++ {
+		if packets == nil || packets[peer] == nil {
+			waiting = append(waiting, peer)
+		}
+	}
+	return waiting
+}
+
+// Next returns the next Frame if every peer's Packet for it has arrived.  Pass
+// the Frame's device events to Input.DispatchDeviceEvents() and the rest of it
+// to Input.Think(), or use Lockstep.Think().
+func (l *Lockstep) Next() (Frame, bool) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	packets := l.pending[l.next]
+	for peer := 0; peer < l.peers; peer++ {
+		if packets == nil || packets[peer] == nil {
+			return Frame{}, false
+		}
+	}
+	horizon := l.horizon
+	for _, p := range packets {
+		if p.Horizon > horizon {
+			horizon = p.Horizon
+		}
+	}
+	var device_events []DeviceEvent
+	var events []OsEvent
+	for peer, p := range packets {
+		for _, e := range p.DeviceEvents {
+			e.Device = PeerDevice(peer, e.Device)
+			e.Timestamp += horizon - p.Horizon
+			device_events = append(device_events, e)
+		}
+		for _, e := range p.Events {
+			e.KeyId.Device = PeerDevice(peer, e.KeyId.Device)
+			e.Timestamp += horizon - p.Horizon
+			if e.Timestamp <= l.horizon {
+				e.Timestamp = l.horizon + 1
+				if e.Timestamp > horizon {
+					e.Timestamp = horizon
+				}
+			}
+			events = append(events, e)
+		}
+	}
+	sort.SliceStable(events, func(i, j int) bool {
+		return events[i].Timestamp < events[j].Timestamp
+	})
+	delete(l.pending, l.next)
+	l.next++
+	l.horizon = horizon
+	return Frame{Horizon: horizon, HasFocus: true, DeviceEvents: device_events, Events: events}, true
+}
+
+// Think passes the next Frame's device events to input.DispatchDeviceEvents()
+// and the rest of it to input.Think(), and returns the EventGroups generated,
+// if every peer's Packet for that frame has arrived.  Otherwise it returns
+// false and input is not touched.
+func (l *Lockstep) Think(input *Input) ([]EventGroup, bool) {
+	frame, ok := l.Next()
+	if !ok {
+		return nil, false
+	}
+	input.DispatchDeviceEvents(frame.DeviceEvents)
+	return input.Think(frame.Horizon, frame.HasFocus, frame.Events), true
+}
@@ -0,0 +1,324 @@
+package gin_test
+
+import (
+	"bytes"
+	"github.com/orfjackal/gospec/src/gospec"
+	. "github.com/orfjackal/gospec/src/gospec"
+	"github.com/runningwild/glop/gin"
+	"io"
+	"net"
+	"strings"
+	"time"
+)
+
+func PacketSpec(c gospec.Context) {
+	p := gin.Packet{
+		Peer:    2,
+		Frame:   17,
+		Horizon: 1000050,
+		DeviceEvents: []gin.DeviceEvent{
+			{Type: gin.DeviceConnected, Device: gin.DeviceId{Type: gin.DeviceTypeController, Index: 2}, Name: "Pad", GUID: xbox_guid, Timestamp: 1000005},
+			{Type: gin.DeviceDisconnected, Device: gin.DeviceId{Type: gin.DeviceTypeController, Index: 1}, Timestamp: 1000050},
+		},
+	}
+	injectEvent(&p.Events, gin.KeyA, 1, gin.DeviceTypeKeyboard, 1, 1000010)
+	injectEvent(&p.Events, gin.KeyA, 1, gin.DeviceTypeKeyboard, 0, 1000020)
+	injectEvent(&p.Events, gin.MouseXAxis, 3, gin.DeviceTypeMouse, -2.5, 1000030)
+	p.Events = append(p.Events, gin.OsEvent{
+		KeyId:     gin.KeyId{Index: gin.TouchContact0 + 1, Device: gin.DeviceId{Type: gin.DeviceTypeTouch, Index: 1}},
+		Press_amt: 0.25,
+		Timestamp: 1000040,
+		X:         640,
+		Y:         -3,
+	})
+
+	c.Specify("Packets survive a round trip.", func() {
+		b := gin.AppendPacket(nil, p)
+		parsed, err := gin.ParsePacket(b)
+		c.Assume(err, IsNil)
+		c.Expect(parsed.Peer, Equals, p.Peer)
+		c.Expect(parsed.Frame, Equals, p.Frame)
+		c.Expect(parsed.Horizon, Equals, p.Horizon)
+		c.Assume(len(parsed.DeviceEvents), Equals, len(p.DeviceEvents))
+		for i := range p.DeviceEvents {
+			c.Expect(parsed.DeviceEvents[i], Equals, p.DeviceEvents[i])
+		}
+		c.Assume(len(parsed.Events), Equals, len(p.Events))
+		for i := range p.Events {
+			c.Expect(parsed.Events[i], Equals, p.Events[i])
+		}
+	})
+
+	c.Specify("Packets are compact.", func() {
+		key_only := gin.Packet{Horizon: p.Horizon, Events: p.Events[0:2]}
+		c.Expect(len(gin.AppendPacket(nil, key_only)) < 32, Equals, true)
+	})
+
+	c.Specify("Truncated and corrupt packets are rejected.", func() {
+		b := gin.AppendPacket(nil, p)
+		for n := 0; n < len(b); n++ {
+			_, err := gin.ParsePacket(b[0:n])
+			c.Expect(err, Not(IsNil))
+		}
+		_, err := gin.ParsePacket(append(b, 0))
+		c.Expect(err, Not(IsNil))
+		b[1] = gin.PacketVersion + 1
+		_, err = gin.ParsePacket(b)
+		c.Expect(err, Not(IsNil))
+	})
+
+	c.Specify("Packets can be streamed.", func() {
+		var buf bytes.Buffer
+		c.Assume(gin.WritePacket(&buf, p), IsNil)
+		c.Assume(gin.WritePacket(&buf, gin.Packet{Frame: 18}), IsNil)
+		first, err := gin.ReadPacket(&buf)
+		c.Expect(err, IsNil)
+		c.Expect(len(first.Events), Equals, 4)
+		second, err := gin.ReadPacket(&buf)
+		c.Expect(err, IsNil)
+		c.Expect(second.Frame, Equals, int64(18))
+		_, err = gin.ReadPacket(&buf)
+		c.Expect(err, Equals, io.EOF)
+	})
+}
+
+func LockstepSpec(c gospec.Context) {
+	keyboard := gin.DeviceId{Type: gin.DeviceTypeKeyboard, Index: 1}
+	c.Specify("Frames wait for every peer.", func() {
+		l := gin.MakeLockstep(0, 3, 0)
+		_, err := l.Local(100, true, nil, nil)
+		c.Assume(err, IsNil)
+		_, ok := l.Next()
+		c.Expect(ok, Equals, false)
+		c.Expect(l.Waiting(), ContainsInOrder, []int{1, 2})
+		c.Expect(l.Receive(gin.Packet{Peer: 2, Frame: 0, Horizon: 90}), IsNil)
+		c.Expect(l.Waiting(), ContainsInOrder, []int{1})
+		c.Expect(l.Receive(gin.Packet{Peer: 1, Frame: 1, Horizon: 190}), IsNil)
+		_, ok = l.Next()
+		c.Expect(ok, Equals, false)
+		c.Expect(l.Receive(gin.Packet{Peer: 1, Frame: 0, Horizon: 80}), IsNil)
+		frame, ok := l.Next()
+		c.Expect(ok, Equals, true)
+		c.Expect(frame.Horizon, Equals, int64(100))
+		c.Expect(frame.HasFocus, Equals, true)
+
+		c.Expect(l.Receive(gin.Packet{Peer: 1, Frame: 0}), Not(IsNil))
+		c.Expect(l.Receive(gin.Packet{Peer: 1, Frame: 1}), Not(IsNil))
+		c.Expect(l.Receive(gin.Packet{Peer: 0, Frame: 1}), Not(IsNil))
+		c.Expect(l.Receive(gin.Packet{Peer: 3, Frame: 1}), Not(IsNil))
+	})
+
+	c.Specify("Packets too far in the future are rejected.", func() {
+		l := gin.MakeLockstep(0, 2, 2)
+		limit := int64(2 + 2 + gin.MaxFrameLead)
+		c.Expect(l.Receive(gin.Packet{Peer: 1, Frame: limit}), IsNil)
+		c.Expect(l.Receive(gin.Packet{Peer: 1, Frame: limit + 1}), Not(IsNil))
+		c.Expect(l.Receive(gin.Packet{Peer: 1, Frame: 1 << 40}), Not(IsNil))
+		_, err := l.Local(100, true, nil, nil)
+		c.Assume(err, IsNil)
+		c.Expect(l.Receive(gin.Packet{Peer: 1, Frame: limit + 1}), IsNil)
+	})
+
+	c.Specify("Each peer's devices are remapped and events are merged in order.", func() {
+		l := gin.MakeLockstep(1, 2, 0)
+		var events []gin.OsEvent
+		injectEvent(&events, gin.KeyA, 1, gin.DeviceTypeKeyboard, 1, 95)
+		_, err := l.Local(100, true, nil, events)
+		c.Assume(err, IsNil)
+		events = nil
+		injectEvent(&events, gin.KeyB, 1, gin.DeviceTypeKeyboard, 1, 1005)
+		injectEvent(&events, gin.KeyC, 1, gin.DeviceTypeKeyboard, 1, 1020)
+		c.Assume(l.Receive(gin.Packet{Peer: 0, Frame: 0, Horizon: 1020, Events: events}), IsNil)
+		frame, ok := l.Next()
+		c.Assume(ok, Equals, true)
+		c.Expect(frame.Horizon, Equals, int64(1020))
+		c.Assume(len(frame.Events), Equals, 3)
+		c.Expect(frame.Events[0].KeyId, Equals, gin.KeyId{Index: gin.KeyB, Device: keyboard})
+		c.Expect(frame.Events[0].Timestamp, Equals, int64(1005))
+		c.Expect(frame.Events[1].KeyId, Equals, gin.KeyId{Index: gin.KeyA, Device: gin.PeerDevice(1, keyboard)})
+		c.Expect(frame.Events[1].Timestamp, Equals, int64(1015))
+		c.Expect(frame.Events[2].Timestamp, Equals, int64(1020))
+		peer, device := gin.DevicePeer(frame.Events[1].KeyId.Device)
+		c.Expect(peer, Equals, 1)
+		c.Expect(device, Equals, keyboard)
+	})
+
+	c.Specify("Events from a lagging peer stay after the previous horizon.", func() {
+		l := gin.MakeLockstep(1, 2, 0)
+		_, err := l.Local(10, true, nil, nil)
+		c.Assume(err, IsNil)
+		c.Assume(l.Receive(gin.Packet{Peer: 0, Frame: 0, Horizon: 100}), IsNil)
+		frame, ok := l.Next()
+		c.Assume(ok, Equals, true)
+		c.Expect(frame.Horizon, Equals, int64(100))
+
+		// Peer 1's horizon was behind, and now it is ahead, so its events aren't
+		// shifted at all, but some are older than the last Frame's horizon.
+		var events []gin.OsEvent
+		injectEvent(&events, gin.KeyA, 1, gin.DeviceTypeKeyboard, 1, 50)
+		injectEvent(&events, gin.KeyA, 1, gin.DeviceTypeKeyboard, 0, 100)
+		injectEvent(&events, gin.KeyB, 1, gin.DeviceTypeKeyboard, 1, 150)
+		_, err = l.Local(200, true, nil, events)
+		c.Assume(err, IsNil)
+		c.Assume(l.Receive(gin.Packet{Peer: 0, Frame: 1, Horizon: 110}), IsNil)
+		frame, ok = l.Next()
+		c.Assume(ok, Equals, true)
+		c.Expect(frame.Horizon, Equals, int64(200))
+		c.Assume(len(frame.Events), Equals, 3)
+		c.Expect(frame.Events[0].Timestamp, Equals, int64(101))
+		c.Expect(frame.Events[1].Timestamp, Equals, int64(101))
+		c.Expect(frame.Events[2].Timestamp, Equals, int64(150))
+	})
+
+	c.Specify("Input delay starts with empty frames.", func() {
+		l := gin.MakeLockstep(0, 2, 2)
+		p, err := l.Local(100, true, nil, nil)
+		c.Assume(err, IsNil)
+		c.Expect(p.Frame, Equals, int64(2))
+		for i := 0; i < 2; i++ {
+			frame, ok := l.Next()
+			c.Expect(ok, Equals, true)
+			c.Expect(len(frame.Events), Equals, 0)
+		}
+		_, ok := l.Next()
+		c.Expect(ok, Equals, false)
+	})
+
+	c.Specify("Losing focus releases local keys on every peer.", func() {
+		l := gin.MakeLockstep(0, 1, 0)
+		var events []gin.OsEvent
+		injectEvent(&events, gin.KeyA, 1, gin.DeviceTypeKeyboard, 1, 5)
+		injectEvent(&events, gin.KeyB, 1, gin.DeviceTypeKeyboard, 1, 6)
+		injectEvent(&events, gin.KeyB, 1, gin.DeviceTypeKeyboard, 0, 7)
+		_, err := l.Local(10, true, nil, events)
+		c.Assume(err, IsNil)
+		p, err := l.Local(20, false, nil, events)
+		c.Assume(err, IsNil)
+		c.Assume(len(p.Events), Equals, 1)
+		c.Expect(p.Events[0].KeyId, Equals, gin.KeyId{Index: gin.KeyA, Device: keyboard})
+		c.Expect(p.Events[0].Press_amt, Equals, 0.0)
+	})
+
+	c.Specify("Events that can't be remapped are rejected.", func() {
+		l := gin.MakeLockstep(0, 2, 0)
+		var events []gin.OsEvent
+		injectEvent(&events, gin.KeyA, gin.PeerDeviceStride, gin.DeviceTypeKeyboard, 1, 5)
+		_, err := l.Local(10, true, nil, events)
+		c.Expect(err, Not(IsNil))
+		c.Expect(l.Receive(gin.Packet{Peer: 1, Events: events}), Not(IsNil))
+		device_events := []gin.DeviceEvent{{Type: gin.DeviceConnected, Device: gin.DeviceId{Type: gin.DeviceTypeController, Index: gin.PeerDeviceStride}}}
+		_, err = l.Local(10, true, device_events, nil)
+		c.Expect(err, Not(IsNil))
+		c.Expect(l.Receive(gin.Packet{Peer: 1, DeviceEvents: device_events}), Not(IsNil))
+	})
+
+	c.Specify("Disconnecting a device releases its keys on every peer.", func() {
+		l := gin.MakeLockstep(0, 1, 0)
+		pad := gin.DeviceId{Type: gin.DeviceTypeController, Index: 1}
+		var events []gin.OsEvent
+		injectEvent(&events, gin.ControllerButton0, 1, gin.DeviceTypeController, 1, 5)
+		injectEvent(&events, gin.KeyA, 1, gin.DeviceTypeKeyboard, 1, 6)
+		_, err := l.Local(10, true, nil, events)
+		c.Assume(err, IsNil)
+		p, err := l.Local(20, true, []gin.DeviceEvent{{Type: gin.DeviceDisconnected, Device: pad, Timestamp: 15}}, nil)
+		c.Assume(err, IsNil)
+		c.Assume(len(p.Events), Equals, 1)
+		c.Expect(p.Events[0].KeyId, Equals, gin.KeyId{Index: gin.ControllerButton0, Device: pad})
+		c.Expect(p.Events[0].Press_amt, Equals, 0.0)
+	})
+
+	c.Specify("Every peer maps a peer's gamepad.", func() {
+		db := gin.MakeGamepadDatabase()
+		c.Assume(db.Load(strings.NewReader(gamepad_db_text)), IsNil)
+		pad := gin.DeviceId{Type: gin.DeviceTypeController, Index: 1}
+		peers := []*gin.Lockstep{gin.MakeLockstep(0, 2, 0), gin.MakeLockstep(1, 2, 0)}
+		inputs := []*gin.Input{gin.Make(), gin.Make()}
+		for i := range inputs {
+			inputs[i].SetGamepadDatabase(db)
+		}
+		// Peer 1 connects the same kind of pad, with the same local index, as
+		// peer 0 does, and presses a button on it.
+		var events []gin.OsEvent
+		injectEvent(&events, gin.ControllerButton0, 1, gin.DeviceTypeController, 1, 5)
+		for frame := int64(0); frame < 2; frame++ {
+			var packets []gin.Packet
+			for i := range peers {
+				var device_events []gin.DeviceEvent
+				var frame_events []gin.OsEvent
+				if frame == 0 {
+					device_events = []gin.DeviceEvent{{Type: gin.DeviceConnected, Device: pad, GUID: xbox_guid}}
+				} else if i == 1 {
+					frame_events = events
+				}
+				p, err := peers[i].Local(10*(frame+1), true, device_events, frame_events)
+				c.Assume(err, IsNil)
+				parsed, err := gin.ParsePacket(gin.AppendPacket(nil, p))
+				c.Assume(err, IsNil)
+				packets = append(packets, parsed)
+			}
+			for i := range peers {
+				c.Assume(peers[i].Receive(packets[1-i]), IsNil)
+				_, ok := peers[i].Think(inputs[i])
+				c.Assume(ok, Equals, true)
+			}
+		}
+		for i := range inputs {
+			for peer := 0; peer < 2; peer++ {
+				m := inputs[i].GamepadMapping(gin.PeerDevice(peer, pad))
+				c.Assume(m, Not(IsNil))
+				c.Expect(m.Name, Equals, "Xbox 360 Controller")
+			}
+			c.Expect(inputs[i].GetKey(gin.KeyId{Index: gin.GamepadA, Device: gin.PeerDevice(1, pad)}).IsDown(), Equals, true)
+			c.Expect(inputs[i].GetKey(gin.KeyId{Index: gin.GamepadA, Device: pad}).IsDown(), Equals, false)
+		}
+	})
+
+	c.Specify("Peers connected by pipes see identical frames.", func() {
+		conn0, conn1 := net.Pipe()
+		peers := []*gin.Lockstep{gin.MakeLockstep(0, 2, 1), gin.MakeLockstep(1, 2, 1)}
+		conns := []net.Conn{conn0, conn1}
+		inputs := []*gin.Input{gin.Make(), gin.Make()}
+		done := make(chan error, 2)
+		for i := range peers {
+			go func(i int) {
+				done <- peers[i].ReceiveFrom(conns[i])
+			}(i)
+		}
+
+		var results [2][][][]eventSummary
+		for frame := int64(0); frame < 4; frame++ {
+			for i := range peers {
+				var events []gin.OsEvent
+				injectEvent(&events, gin.KeyA+gin.KeyIndex(frame), 1, gin.DeviceTypeKeyboard, float64(frame%2), 100*frame+10*int64(i))
+				p, err := peers[i].Local(100*frame+50+int64(i), true, nil, events)
+				c.Assume(err, IsNil)
+				// net.Pipe is synchronous, so write from another goroutine.
+				go gin.WritePacket(conns[i], p)
+			}
+			for i := range peers {
+				for {
+					groups, ok := peers[i].Think(inputs[i])
+					if ok {
+						results[i] = append(results[i], summarizeGroups(groups))
+						break
+					}
+					time.Sleep(time.Millisecond)
+				}
+			}
+		}
+		conn0.Close()
+		conn1.Close()
+		<-done
+		<-done
+		c.Expect(len(results[0]), Equals, 4)
+		c.Assume(len(results[1]), Equals, 4)
+		for frame := range results[0] {
+			c.Expect(len(results[0][frame]), Equals, len(results[1][frame]))
+			for j := range results[0][frame] {
+				c.Expect(results[0][frame][j], ContainsInOrder, results[1][frame][j])
+			}
+		}
+		c.Expect(inputs[0].GetKey(gin.KeyId{Index: gin.KeyA + 1, Device: gin.PeerDevice(1, keyboard)}).IsDown(), Equals, true)
+		c.Expect(inputs[1].GetKey(gin.KeyId{Index: gin.KeyA + 1, Device: gin.PeerDevice(1, keyboard)}).IsDown(), Equals, true)
+	})
+}
@@ -0,0 +1,27 @@
+package gin
+
+// MouseDelta returns how far the mouse with the specified index moved during
+// the most recent frame, as reported on its MouseXAxis and MouseYAxis keys.
+// If index is DeviceIndexAny the motion of every mouse is added together.  In
+// relative mouse mode, see system.System.SetRelativeMouseMode(), this is raw
+// motion, and split-screen games can give each player their own mouse.
+func (input *Input) MouseDelta(index DeviceIndex) (dx, dy float64) {
+	input.mutex.Lock()
+	defer input.mutex.Unlock()
+	for _, key := range input.all_keys {
+		id := key.Id()
+		if !id.IsNatural() || id.Device.Type != DeviceTypeMouse {
+			continue
+		}
+		if index != DeviceIndexAny && id.Device.Index != index {
+			continue
+		}
+		switch id.Index {
+		case MouseXAxis:
+			dx += key.FramePressSum()
+		case MouseYAxis:
+			dy += key.FramePressSum()
+		}
+	}
+	return
+}
@@ -0,0 +1,33 @@
+package gin_test
+
+import (
+	"github.com/orfjackal/gospec/src/gospec"
+	. "github.com/orfjackal/gospec/src/gospec"
+	"github.com/runningwild/glop/gin"
+)
+
+func MouseDeltaSpec(c gospec.Context) {
+	input := gin.Make()
+	c.Specify("Motion is summed over a frame for each mouse.", func() {
+		events := make([]gin.OsEvent, 0)
+		injectEvent(&events, gin.MouseXAxis, 1, gin.DeviceTypeMouse, 3, 5)
+		injectEvent(&events, gin.MouseXAxis, 1, gin.DeviceTypeMouse, 3, 6)
+		injectEvent(&events, gin.MouseYAxis, 1, gin.DeviceTypeMouse, -2, 6)
+		injectEvent(&events, gin.MouseXAxis, 2, gin.DeviceTypeMouse, -10, 7)
+		input.Think(10, true, events)
+		dx, dy := input.MouseDelta(1)
+		c.Expect(dx, Equals, 6.0)
+		c.Expect(dy, Equals, -2.0)
+		dx, dy = input.MouseDelta(2)
+		c.Expect(dx, Equals, -10.0)
+		c.Expect(dy, Equals, 0.0)
+		dx, dy = input.MouseDelta(gin.DeviceIndexAny)
+		c.Expect(dx, Equals, -4.0)
+		c.Expect(dy, Equals, -2.0)
+
+		input.Think(20, true, nil)
+		dx, dy = input.MouseDelta(gin.DeviceIndexAny)
+		c.Expect(dx, Equals, 0.0)
+		c.Expect(dy, Equals, 0.0)
+	})
+}
@@ -0,0 +1,121 @@
+package gin
+
+import (
+	"encoding/gob"
+	"fmt"
+	"io"
+)
+
+// RecordingVersion is the version of the format written by a Recorder.  A
+// Player will refuse to read a recording with a different version.
+const RecordingVersion = 2
+
+const recordingMagic = "glop/gin recording"
+
+type recordingHeader struct {
+	Magic   string
+	Version int
+}
+
+// A Frame is everything that was passed to a single call to Input.Think(),
+// along with the device events that were passed to Input.DispatchDeviceEvents()
+// since the previous call.  Feeding the same Frames to a freshly made Input
+// will produce the same EventGroups, as long as it has the same bindings and
+// gamepad database.  Mappings set directly with SetGamepadMapping() are not
+// recorded and must be set again before replaying.
+type Frame struct {
+	Horizon      int64
+	HasFocus     bool
+	DeviceEvents []DeviceEvent
+	Events       []OsEvent
+}
+
+// A Recorder writes Frames to an io.Writer.  Attach one to an Input with
+// Input.SetRecorder() and it will record every call to Input.Think() and
+// Input.DispatchDeviceEvents().
+type Recorder struct {
+	enc *gob.Encoder
+
+	// First error encountered while writing, once this is set nothing else will
+	// be written.
+	err error
+}
+
+// MakeRecorder writes a recording header to w and returns a Recorder that will
+// write frames to it.
+func MakeRecorder(w io.Writer) (*Recorder, error) {
+	r := &Recorder{enc: gob.NewEncoder(w)}
+	err := r.enc.Encode(recordingHeader{Magic: recordingMagic, Version: RecordingVersion})
+	if err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// Record writes a single frame.  If an error has already occurred on this
+// Recorder it will be returned and nothing will be written.
+func (r *Recorder) Record(frame Frame) error {
+	if r.err != nil {
+		return r.err
+	}
+	r.err = r.enc.Encode(frame)
+	return r.err
+}
+
+// Err returns the first error encountered while recording, if any.
+func (r *Recorder) Err() error {
+	return r.err
+}
+
+// SetRecorder causes every subsequent call to input.Think() to be recorded to
+// r.  Specify nil to stop recording.  Errors while recording do not affect
+// input.Think(), check Recorder.Err() to find out about them.
+func (input *Input) SetRecorder(r *Recorder) {
+	input.mutex.Lock()
+	defer input.mutex.Unlock()
+	input.recorder = r
+	input.recorded_device_events = nil
+}
+
+// A Player reads Frames that were written by a Recorder.
+type Player struct {
+	dec *gob.Decoder
+}
+
+// MakePlayer reads the recording header from r and returns a Player that can
+// read the frames that follow it.
+func MakePlayer(r io.Reader) (*Player, error) {
+	p := &Player{dec: gob.NewDecoder(r)}
+	var header recordingHeader
+	if err := p.dec.Decode(&header); err != nil {
+		return nil, err
+	}
+	if header.Magic != recordingMagic {
+		return nil, fmt.Errorf("Not a gin recording.")
+	}
+	if header.Version != RecordingVersion {
+		return nil, fmt.Errorf("Cannot read gin recording version %d, expected version %d.", header.Version, RecordingVersion)
+	}
+	return p, nil
+}
+
+// Next returns the next Frame in the recording, or io.EOF if there are no more
+// frames.
+func (p *Player) Next() (Frame, error) {
+	var frame Frame
+	err := p.dec.Decode(&frame)
+	return frame, err
+}
+
+// Think reads the next Frame, passes its device events to
+// input.DispatchDeviceEvents() and then its other contents to input.Think(),
+// returning the EventGroups generated.  Returns io.EOF if there are no more
+// frames.
+func (p *Player) Think(input *Input) ([]EventGroup, error) {
+	frame, err := p.Next()
+	if err != nil {
+		return nil, err
+	}
+	input.DispatchDeviceEvents(frame.DeviceEvents)
+	return input.Think(frame.Horizon, frame.HasFocus, frame.Events), nil
+}
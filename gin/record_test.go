@@ -0,0 +1,135 @@
+package gin_test
+
+import (
+	"bytes"
+	"encoding/gob"
+	"github.com/orfjackal/gospec/src/gospec"
+	. "github.com/orfjackal/gospec/src/gospec"
+	"github.com/runningwild/glop/gin"
+	"io"
+	"strings"
+)
+
+// Derived key indexes are allocated globally, so events are identified by key
+// name and device rather than by KeyId.
+type eventSummary struct {
+	Name   string
+	Device gin.DeviceId
+	Type   gin.EventType
+}
+
+func summarizeGroups(groups []gin.EventGroup) [][]eventSummary {
+	var ret [][]eventSummary
+	for _, group := range groups {
+		var summary []eventSummary
+		for _, event := range group.Events {
+			summary = append(summary, eventSummary{event.Key.Name(), event.Key.Id().Device, event.Type})
+		}
+		ret = append(ret, summary)
+	}
+	return ret
+}
+
+func RecordSpec(c gospec.Context) {
+	input := gin.Make()
+	shift_a := input.BindDerivedKey("ShiftA", input.MakeBinding(
+		gin.KeyId{Index: gin.KeyA, Device: gin.DeviceId{Type: gin.DeviceTypeKeyboard, Index: 1}},
+		[]gin.KeyId{gin.KeyId{Index: gin.LeftShift, Device: gin.DeviceId{Type: gin.DeviceTypeKeyboard, Index: 1}}},
+		[]bool{true}))
+	var buf bytes.Buffer
+	recorder, err := gin.MakeRecorder(&buf)
+	c.Assume(err, Equals, nil)
+	input.SetRecorder(recorder)
+
+	var frames [][][]eventSummary
+	events := make([]gin.OsEvent, 0)
+	injectEvent(&events, gin.LeftShift, 1, gin.DeviceTypeKeyboard, 1, 3)
+	injectEvent(&events, gin.KeyA, 1, gin.DeviceTypeKeyboard, 1, 5)
+	frames = append(frames, summarizeGroups(input.Think(10, true, events)))
+	events = events[0:0]
+	injectEvent(&events, gin.MouseXAxis, 1, gin.DeviceTypeMouse, 4, 12)
+	frames = append(frames, summarizeGroups(input.Think(20, true, events)))
+	frames = append(frames, summarizeGroups(input.Think(30, false, nil)))
+	c.Assume(recorder.Err(), Equals, nil)
+	c.Assume(shift_a.FramePressCount(), Equals, 0)
+
+	c.Specify("Replaying a recording produces the same events.", func() {
+		replay := gin.Make()
+		replay.BindDerivedKey("ShiftA", replay.MakeBinding(
+			gin.KeyId{Index: gin.KeyA, Device: gin.DeviceId{Type: gin.DeviceTypeKeyboard, Index: 1}},
+			[]gin.KeyId{gin.KeyId{Index: gin.LeftShift, Device: gin.DeviceId{Type: gin.DeviceTypeKeyboard, Index: 1}}},
+			[]bool{true}))
+		player, err := gin.MakePlayer(bytes.NewReader(buf.Bytes()))
+		c.Assume(err, Equals, nil)
+		for i := range frames {
+			groups, err := player.Think(replay)
+			c.Assume(err, Equals, nil)
+			summary := summarizeGroups(groups)
+			c.Expect(len(summary), Equals, len(frames[i]))
+			for j := range summary {
+				c.Expect(summary[j], ContainsInOrder, frames[i][j])
+			}
+		}
+		_, err = player.Think(replay)
+		c.Expect(err, Equals, io.EOF)
+	})
+
+	c.Specify("Frames hold the arguments that were given to Think().", func() {
+		player, err := gin.MakePlayer(bytes.NewReader(buf.Bytes()))
+		c.Assume(err, Equals, nil)
+		frame, err := player.Next()
+		c.Assume(err, Equals, nil)
+		c.Expect(frame.Horizon, Equals, int64(10))
+		c.Expect(frame.HasFocus, Equals, true)
+		c.Expect(len(frame.Events), Equals, 2)
+		frame, err = player.Next()
+		c.Assume(err, Equals, nil)
+		c.Expect(frame.Events[0].Press_amt, Equals, 4.0)
+		frame, err = player.Next()
+		c.Assume(err, Equals, nil)
+		c.Expect(frame.HasFocus, Equals, false)
+	})
+
+	c.Specify("Gamepads connected while recording are mapped when replaying.", func() {
+		db := gin.MakeGamepadDatabase()
+		c.Assume(db.Load(strings.NewReader(gamepad_db_text)), IsNil)
+		pad := gin.DeviceId{Type: gin.DeviceTypeController, Index: 1}
+		record := gin.Make()
+		record.SetGamepadDatabase(db)
+		var buf bytes.Buffer
+		recorder, err := gin.MakeRecorder(&buf)
+		c.Assume(err, Equals, nil)
+		record.SetRecorder(recorder)
+		record.DispatchDeviceEvents([]gin.DeviceEvent{{Type: gin.DeviceConnected, Device: pad, GUID: xbox_guid}})
+		events := make([]gin.OsEvent, 0)
+		injectEvent(&events, gin.ControllerButton0, 1, gin.DeviceTypeController, 1, 5)
+		recorded := summarizeGroups(record.Think(10, true, events))
+		c.Assume(recorder.Err(), Equals, nil)
+		c.Assume(record.GetKey(gin.KeyId{Index: gin.GamepadA, Device: pad}).IsDown(), Equals, true)
+
+		replay := gin.Make()
+		replay.SetGamepadDatabase(db)
+		player, err := gin.MakePlayer(bytes.NewReader(buf.Bytes()))
+		c.Assume(err, Equals, nil)
+		groups, err := player.Think(replay)
+		c.Assume(err, Equals, nil)
+		c.Assume(replay.GamepadMapping(pad), Not(IsNil))
+		c.Expect(replay.GamepadMapping(pad).Name, Equals, "Xbox 360 Controller")
+		c.Expect(replay.GetKey(gin.KeyId{Index: gin.GamepadA, Device: pad}).IsDown(), Equals, true)
+		summary := summarizeGroups(groups)
+		c.Expect(len(summary), Equals, len(recorded))
+		for i := range summary {
+			c.Expect(summary[i], ContainsInOrder, recorded[i])
+		}
+	})
+
+	c.Specify("Recordings with the wrong version are rejected.", func() {
+		var bad bytes.Buffer
+		gob.NewEncoder(&bad).Encode(struct {
+			Magic   string
+			Version int
+		}{"glop/gin recording", gin.RecordingVersion + 1})
+		_, err := gin.MakePlayer(&bad)
+		c.Expect(err, Not(Equals), nil)
+	})
+}
@@ -0,0 +1,87 @@
+package gin
+
+// A KeyState is a copy of the state of a Key at a single point in time.
+type KeyState struct {
+	Id   KeyId
+	Name string
+
+	IsDown            bool
+	CurPressAmt       float64
+	FramePressCount   int
+	FrameReleaseCount int
+	FramePressAmt     float64
+	FramePressSum     float64
+	FramePressAvg     float64
+}
+
+// A Snapshot is an immutable copy of the state of every key as of the end of
+// a single call to Input.Think().  Unlike Keys, Snapshots can be queried from
+// any goroutine, so they are how the render thread or a network goroutine
+// should look at input.
+type Snapshot struct {
+	// The time passed to the Think() call that this Snapshot was taken after.
+	Timestamp int64
+
+	keys map[KeyId]KeyState
+}
+
+func (input *Input) makeSnapshot(t int64) *Snapshot {
+	s := &Snapshot{
+		Timestamp: t,
+		keys:      make(map[KeyId]KeyState, len(input.all_keys)),
+	}
+	for _, key := range input.all_keys {
+		s.keys[key.Id()] = KeyState{
+			Id:                key.Id(),
+			Name:              key.Name(),
+			IsDown:            key.IsDown(),
+			CurPressAmt:       key.CurPressAmt(),
+			FramePressCount:   key.FramePressCount(),
+			FrameReleaseCount: key.FrameReleaseCount(),
+			FramePressAmt:     key.FramePressAmt(),
+			FramePressSum:     key.FramePressSum(),
+			FramePressAvg:     key.FramePressAvg(),
+		}
+	}
+	return s
+}
+
+// Snapshot returns the state of every key as of the end of the most recent
+// call to Think().  Calling this while Think() is running, e.g. from another
+// goroutine, returns the state from the end of the previous frame rather than
+// a partially processed frame.
+func (input *Input) Snapshot() *Snapshot {
+	input.mutex.Lock()
+	defer input.mutex.Unlock()
+	if input.snapshot == nil {
+		input.snapshot = input.makeSnapshot(0)
+	}
+	return input.snapshot
+}
+
+// Key returns the state of the key with the specified id.  Keys that did not
+// exist when the Snapshot was taken are reported as up.
+func (s *Snapshot) Key(id KeyId) KeyState {
+	if state, ok := s.keys[id]; ok {
+		return state
+	}
+	return KeyState{Id: id}
+}
+
+func (s *Snapshot) IsDown(id KeyId) bool {
+	return s.Key(id).IsDown
+}
+
+func (s *Snapshot) CurPressAmt(id KeyId) float64 {
+	return s.Key(id).CurPressAmt
+}
+
+// Keys returns the states of every key in the Snapshot, in no particular
+// order.
+func (s *Snapshot) Keys() []KeyState {
+	states := make([]KeyState, 0, len(s.keys))
+	for _, state := range s.keys {
+		states = append(states, state)
+	}
+	return states
+}
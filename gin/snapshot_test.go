@@ -0,0 +1,85 @@
+package gin_test
+
+import (
+	"fmt"
+	"github.com/orfjackal/gospec/src/gospec"
+	. "github.com/orfjackal/gospec/src/gospec"
+	"github.com/runningwild/glop/gin"
+	"sync"
+)
+
+// A reentrantListener binds a new derived key every time it handles an event
+// group, which requires calling back into the Input from inside Think().
+type reentrantListener struct {
+	input *gin.Input
+	keys  []gin.Key
+}
+
+func (l *reentrantListener) HandleEventGroup(group gin.EventGroup) {
+	kb1 := gin.DeviceId{Type: gin.DeviceTypeKeyboard, Index: 1}
+	binding := l.input.MakeBinding(gin.KeyId{Index: gin.KeyB, Device: kb1}, nil, nil)
+	l.keys = append(l.keys, l.input.BindDerivedKey("reentrant", binding))
+}
+
+func (l *reentrantListener) Think() {
+	l.input.GetKey(gin.AnyAnyKey)
+}
+
+func SnapshotSpec(c gospec.Context) {
+	input := gin.Make()
+	kb1 := gin.DeviceId{Type: gin.DeviceTypeKeyboard, Index: 1}
+	keya := gin.KeyId{Index: gin.KeyA, Device: kb1}
+
+	c.Specify("Snapshots reflect the end of the most recent frame.", func() {
+		events := make([]gin.OsEvent, 0)
+		injectEvent(&events, gin.KeyA, 1, gin.DeviceTypeKeyboard, 1, 5)
+		injectEvent(&events, gin.KeyA, 1, gin.DeviceTypeKeyboard, 0, 6)
+		injectEvent(&events, gin.KeyA, 1, gin.DeviceTypeKeyboard, 1, 7)
+		input.Think(10, true, events)
+		snapshot := input.Snapshot()
+		c.Expect(snapshot.Timestamp, Equals, int64(10))
+		c.Expect(snapshot.IsDown(keya), Equals, true)
+		c.Expect(snapshot.Key(keya).FramePressCount, Equals, 2)
+		c.Expect(snapshot.Key(keya).FrameReleaseCount, Equals, 1)
+		c.Expect(snapshot.IsDown(gin.KeyId{Index: gin.KeyZ, Device: kb1}), Equals, false)
+
+		events = events[0:0]
+		injectEvent(&events, gin.KeyA, 1, gin.DeviceTypeKeyboard, 0, 15)
+		input.Think(20, true, events)
+		c.Expect(snapshot.IsDown(keya), Equals, true)
+		c.Expect(input.Snapshot().IsDown(keya), Equals, false)
+	})
+
+	c.Specify("Listeners can call back into the Input.", func() {
+		l := &reentrantListener{input: input}
+		input.RegisterEventListener(l)
+		events := make([]gin.OsEvent, 0)
+		injectEvent(&events, gin.KeyA, 1, gin.DeviceTypeKeyboard, 1, 5)
+		input.Think(10, true, events)
+		c.Expect(len(l.keys), Equals, 1)
+	})
+
+	c.Specify("The Input can be used from several goroutines at once.", func() {
+		var wg sync.WaitGroup
+		for g := 0; g < 4; g++ {
+			wg.Add(1)
+			go func(g int) {
+				defer wg.Done()
+				for i := 0; i < 50; i++ {
+					id := gin.KeyId{Index: gin.KeyA + gin.KeyIndex(i%26), Device: kb1}
+					key := input.BindDerivedKey(fmt.Sprintf("key%d-%d", g, i), input.MakeBinding(id, nil, nil))
+					input.GetKey(id)
+					input.Snapshot().IsDown(id)
+					input.UnbindDerivedKey(key)
+				}
+			}(g)
+		}
+		for i := 0; i < 50; i++ {
+			events := make([]gin.OsEvent, 0)
+			injectEvent(&events, gin.KeyA, 1, gin.DeviceTypeKeyboard, float64(i%2), int64(i))
+			input.Think(int64(i), true, events)
+		}
+		wg.Wait()
+		c.Expect(input.Snapshot().Timestamp, Equals, int64(49))
+	})
+}
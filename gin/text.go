@@ -0,0 +1,75 @@
+package gin
+
+import (
+	"fmt"
+)
+
+// Key events only describe which physical keys were pressed.  Text events
+// describe the text that the user typed, after the keyboard layout, dead keys,
+// compose sequences and input methods have all been taken into account.  This
+// is what should be used for chat boxes, name entry, and so on.
+
+type TextEventType int
+
+const (
+	// The user committed some text.  TextEvent.Text holds the text.
+	TextInput TextEventType = iota
+
+	// An input method is composing text that has not been committed yet.
+	// TextEvent.Text holds the entire pre-edit string, which replaces any
+	// previous pre-edit string, and TextEvent.Cursor is the position of the
+	// cursor within it, in runes.  An empty pre-edit string means that
+	// composition has ended, either because it was committed, in which case a
+	// TextInput event will follow, or because it was cancelled.
+	TextComposition
+)
+
+func (t TextEventType) String() string {
+	switch t {
+	case TextInput:
+		return "input"
+	case TextComposition:
+		return "composition"
+	}
+	panic(fmt.Sprintf("%d is not a valid TextEventType", t))
+}
+
+type TextEvent struct {
+	Type TextEventType
+
+	// UTF-8 encoded text.
+	Text string
+
+	// Only used for TextComposition events.
+	Cursor int
+
+	// The device that generated this text, this is typically a keyboard.
+	Device DeviceId
+
+	Timestamp int64
+}
+
+func (e TextEvent) String() string {
+	return fmt.Sprintf("'%v %q'", e.Type, e.Text)
+}
+
+// A Listener that also implements TextHandler will receive text events that
+// are passed to Input.DispatchTextEvents().
+type TextHandler interface {
+	HandleTextEvent(TextEvent)
+}
+
+// DispatchTextEvents sends each event, in order, to every registered Listener
+// that implements TextHandler and is in a context that is not blocked.
+func (input *Input) DispatchTextEvents(events []TextEvent) {
+	for _, event := range events {
+		input.mutex.Lock()
+		listeners := input.activeListeners()
+		input.mutex.Unlock()
+		for _, listener := range listeners {
+			if handler, ok := listener.(TextHandler); ok {
+				handler.HandleTextEvent(event)
+			}
+		}
+	}
+}
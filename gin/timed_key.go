@@ -0,0 +1,261 @@
+package gin
+
+import (
+	"fmt"
+)
+
+// Timed keys are derived keys that care about when their dependencies are
+// pressed, not just whether or not they are down.  All times are in the same
+// units as EventGroup.Timestamp, which is milliseconds.
+
+// A SequenceStep is one step in a key sequence.  The step is completed when
+// one of its Keys is pressed while all of its other Keys are down.  If Within
+// is non-zero the step must be completed no more than Within ms after the
+// previous step was completed, Within is ignored for the first step.
+type SequenceStep struct {
+	Keys   []KeyId
+	Within int64
+}
+
+// A sequenceKey is pressed when all of its steps are completed in order, and
+// released when any of the keys in its final step are released.
+type sequenceKey struct {
+	keyState
+	input *Input
+	steps []SequenceStep
+
+	// If non-zero, the entire sequence must be completed within this many ms.
+	total int64
+
+	// Index of the next step to be completed, and the times at which the first
+	// step and the most recent step were completed.
+	next        int
+	start, last int64
+}
+
+// BindSequenceKey creates a key that is pressed when a sequence of steps are
+// completed in order, like a fighting game combo.  If total is non-zero the
+// entire sequence must be completed within total ms.  The key stays down until
+// any of the keys in the final step are released.  Example, down, down-forward,
+// forward + punch within 300ms:
+//
+//	input.BindSequenceKey("Hadouken", 300,
+//	  SequenceStep{Keys: []KeyId{down}},
+//	  SequenceStep{Keys: []KeyId{down, forward}},
+//	  SequenceStep{Keys: []KeyId{forward, punch}})
+func (input *Input) BindSequenceKey(name string, total int64, steps ...SequenceStep) Key {
+	input.mutex.Lock()
+	defer input.mutex.Unlock()
+	return input.bindSequenceKey(name, total, steps...)
+}
+
+func (input *Input) bindSequenceKey(name string, total int64, steps ...SequenceStep) Key {
+	if len(steps) == 0 {
+		panic("BindSequenceKey() requires at least one step.")
+	}
+	for _, step := range steps {
+		if len(step.Keys) == 0 {
+			panic("Every step passed to BindSequenceKey() must have at least one key.")
+		}
+	}
+	sk := &sequenceKey{
+		keyState: keyState{
+			id: KeyId{
+				Index:  genDerivedKeyIndex(),
+				Device: DeviceId{Index: 1, Type: DeviceTypeDerived},
+			},
+			name:       name,
+			aggregator: &standardAggregator{},
+		},
+		input: input,
+		steps: steps,
+		total: total,
+	}
+	var deps []KeyId
+	for _, step := range steps {
+		deps = append(deps, step.Keys...)
+	}
+	input.addTimedKey(sk, deps)
+	return sk
+}
+
+// BindMultiTapKey creates a key that is pressed when the key with the
+// specified id is pressed taps times in a row, with each press coming no more
+// than within ms after the previous press.  This is how double-clicks and
+// double-taps are done.  The key stays down until id is released.
+func (input *Input) BindMultiTapKey(name string, id KeyId, taps int, within int64) Key {
+	if taps < 1 {
+		panic(fmt.Sprintf("BindMultiTapKey() requires at least one tap, not %d.", taps))
+	}
+	steps := make([]SequenceStep, taps)
+	for i := range steps {
+		steps[i] = SequenceStep{Keys: []KeyId{id}, Within: within}
+	}
+	input.mutex.Lock()
+	defer input.mutex.Unlock()
+	return input.bindSequenceKey(name, 0, steps...)
+}
+
+// addTimedKey registers key as depending on all of the specified ids and adds
+// it to the Input.
+func (input *Input) addTimedKey(key Key, deps []KeyId) {
+	registered := make(map[KeyId]bool)
+	for _, dep := range deps {
+		if registered[dep] {
+			continue
+		}
+		registered[dep] = true
+		input.registerDependence(key, dep)
+	}
+	input.key_map[key.Id()] = key
+	input.all_keys = append(input.all_keys, key)
+}
+
+// stepDown returns true iff all of the keys in the specified step are down.
+func (sk *sequenceKey) stepDown(step int) bool {
+	for _, id := range sk.steps[step].Keys {
+		if !sk.input.getKey(id).IsDown() {
+			return false
+		}
+	}
+	return true
+}
+
+func (sk *sequenceKey) stepContains(step int, id KeyId) bool {
+	for _, key := range sk.steps[step].Keys {
+		if key == id {
+			return true
+		}
+	}
+	return false
+}
+
+// expired returns true iff it is too late at time ms to complete the next step.
+func (sk *sequenceKey) expired(ms int64) bool {
+	if sk.next == 0 {
+		return false
+	}
+	within := sk.steps[sk.next].Within
+	if within > 0 && ms-sk.last > within {
+		return true
+	}
+	return sk.total > 0 && ms-sk.start > sk.total
+}
+
+// advance completes the next step if the press of id at time ms does so, and
+// returns true iff it did.
+func (sk *sequenceKey) advance(id KeyId, ms int64) bool {
+	if !sk.stepContains(sk.next, id) || !sk.stepDown(sk.next) {
+		return false
+	}
+	if sk.next == 0 {
+		sk.start = ms
+	}
+	sk.last = ms
+	sk.next++
+	return true
+}
+
+func (sk *sequenceKey) SetPressAmt(amt float64, ms int64, cause Event) (event Event) {
+	event.Type = NoEvent
+	event.Key = &sk.keyState
+	if sk.IsDown() {
+		if !sk.stepDown(len(sk.steps) - 1) {
+			event.Type = Release
+			sk.keyState.aggregator.SetPressAmt(0, ms, event.Type)
+		}
+		return
+	}
+	if cause.Key == nil || cause.Type != Press {
+		return
+	}
+	id := cause.Key.Id()
+	if sk.expired(ms) {
+		sk.next = 0
+	}
+	if !sk.advance(id, ms) && sk.next > 0 && !sk.stepContains(sk.next, id) {
+		// This press doesn't fit in the sequence, but it might start it over.
+		sk.next = 0
+		sk.advance(id, ms)
+	}
+	if sk.next == len(sk.steps) {
+		sk.next = 0
+		event.Type = Press
+		sk.keyState.aggregator.SetPressAmt(1, ms, event.Type)
+	}
+	return
+}
+
+// A holdKey is pressed once another key has been held down continuously for a
+// certain amount of time, and released when that key is released.
+type holdKey struct {
+	keyState
+	input *Input
+	dep   KeyId
+	hold  int64
+
+	// Whether or not dep is down, and if so when it was pressed.
+	dep_down   bool
+	pressed_at int64
+}
+
+// BindHoldKey creates a key that is pressed once the key with the specified id
+// has been held down for hold ms, and is released when id is released.  Unless
+// another event for id happens after hold ms have passed, the press will
+// happen at the end of the frame in which hold ms have passed, and it will have
+// that frame's timestamp.
+func (input *Input) BindHoldKey(name string, id KeyId, hold int64) Key {
+	input.mutex.Lock()
+	defer input.mutex.Unlock()
+	hk := &holdKey{
+		keyState: keyState{
+			id: KeyId{
+				Index:  genDerivedKeyIndex(),
+				Device: DeviceId{Index: 1, Type: DeviceTypeDerived},
+			},
+			name:       name,
+			aggregator: &standardAggregator{},
+		},
+		input: input,
+		dep:   id,
+		hold:  hold,
+	}
+	input.addTimedKey(hk, []KeyId{id})
+	return hk
+}
+
+func (hk *holdKey) ready(ms int64) bool {
+	return hk.dep_down && !hk.IsDown() && ms-hk.pressed_at >= hk.hold
+}
+
+func (hk *holdKey) SetPressAmt(amt float64, ms int64, cause Event) (event Event) {
+	event.Type = NoEvent
+	event.Key = &hk.keyState
+	if cause.Key != nil {
+		down := hk.input.getKey(hk.dep).IsDown()
+		if down && !hk.dep_down {
+			hk.pressed_at = ms
+		}
+		hk.dep_down = down
+		if !down {
+			if hk.IsDown() {
+				event.Type = Release
+				hk.keyState.aggregator.SetPressAmt(0, ms, event.Type)
+			}
+			return
+		}
+	}
+	if hk.ready(ms) {
+		event.Type = Press
+		hk.keyState.aggregator.SetPressAmt(1, ms, event.Type)
+	}
+	return
+}
+
+func (hk *holdKey) Think(ms int64) (bool, float64) {
+	hk.keyState.Think(ms)
+	if hk.ready(ms) {
+		return true, 1
+	}
+	return false, 0
+}
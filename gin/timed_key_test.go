@@ -0,0 +1,152 @@
+package gin_test
+
+import (
+	"github.com/orfjackal/gospec/src/gospec"
+	. "github.com/orfjackal/gospec/src/gospec"
+	"github.com/runningwild/glop/gin"
+)
+
+func SequenceKeySpec(c gospec.Context) {
+	input := gin.Make()
+	kb := gin.DeviceId{Type: gin.DeviceTypeKeyboard, Index: 1}
+	down := gin.KeyId{Index: gin.Down, Device: kb}
+	forward := gin.KeyId{Index: gin.Right, Device: kb}
+	punch := gin.KeyId{Index: gin.KeyJ, Device: kb}
+	combo := input.BindSequenceKey("Combo", 300,
+		gin.SequenceStep{Keys: []gin.KeyId{down}},
+		gin.SequenceStep{Keys: []gin.KeyId{down, forward}},
+		gin.SequenceStep{Keys: []gin.KeyId{forward, punch}, Within: 100})
+
+	c.Specify("Sequences completed in time press the key.", func() {
+		events := make([]gin.OsEvent, 0)
+		injectEvent(&events, gin.Down, 1, gin.DeviceTypeKeyboard, 1, 10)
+		injectEvent(&events, gin.Right, 1, gin.DeviceTypeKeyboard, 1, 60)
+		injectEvent(&events, gin.Down, 1, gin.DeviceTypeKeyboard, 0, 110)
+		injectEvent(&events, gin.KeyJ, 1, gin.DeviceTypeKeyboard, 1, 150)
+		input.Think(200, true, events)
+		c.Expect(combo.FramePressCount(), Equals, 1)
+		c.Expect(combo.IsDown(), Equals, true)
+
+		events = events[0:0]
+		injectEvent(&events, gin.KeyJ, 1, gin.DeviceTypeKeyboard, 0, 250)
+		input.Think(300, true, events)
+		c.Expect(combo.FrameReleaseCount(), Equals, 1)
+		c.Expect(combo.IsDown(), Equals, false)
+	})
+
+	c.Specify("Sequences can span several frames.", func() {
+		events := make([]gin.OsEvent, 0)
+		injectEvent(&events, gin.Down, 1, gin.DeviceTypeKeyboard, 1, 10)
+		input.Think(20, true, events)
+		events = events[0:0]
+		injectEvent(&events, gin.Right, 1, gin.DeviceTypeKeyboard, 1, 60)
+		input.Think(70, true, events)
+		events = events[0:0]
+		injectEvent(&events, gin.KeyJ, 1, gin.DeviceTypeKeyboard, 1, 150)
+		input.Think(200, true, events)
+		c.Expect(combo.FramePressCount(), Equals, 1)
+	})
+
+	c.Specify("Sequences that take too long do not press the key.", func() {
+		events := make([]gin.OsEvent, 0)
+		injectEvent(&events, gin.Down, 1, gin.DeviceTypeKeyboard, 1, 10)
+		injectEvent(&events, gin.Right, 1, gin.DeviceTypeKeyboard, 1, 60)
+		injectEvent(&events, gin.KeyJ, 1, gin.DeviceTypeKeyboard, 1, 170)
+		input.Think(200, true, events)
+		c.Expect(combo.FramePressCount(), Equals, 0)
+
+		events = events[0:0]
+		injectEvent(&events, gin.KeyJ, 1, gin.DeviceTypeKeyboard, 0, 210)
+		injectEvent(&events, gin.Right, 1, gin.DeviceTypeKeyboard, 0, 220)
+		injectEvent(&events, gin.Right, 1, gin.DeviceTypeKeyboard, 1, 300)
+		injectEvent(&events, gin.KeyJ, 1, gin.DeviceTypeKeyboard, 1, 400)
+		input.Think(500, true, events)
+		c.Expect(combo.FramePressCount(), Equals, 0)
+	})
+
+	c.Specify("Sequences done out of order do not press the key.", func() {
+		events := make([]gin.OsEvent, 0)
+		injectEvent(&events, gin.Right, 1, gin.DeviceTypeKeyboard, 1, 10)
+		injectEvent(&events, gin.Down, 1, gin.DeviceTypeKeyboard, 1, 20)
+		injectEvent(&events, gin.KeyJ, 1, gin.DeviceTypeKeyboard, 1, 30)
+		input.Think(200, true, events)
+		c.Expect(combo.FramePressCount(), Equals, 0)
+	})
+}
+
+func MultiTapKeySpec(c gospec.Context) {
+	input := gin.Make()
+	mouse := gin.KeyId{Index: gin.MouseLButton, Device: gin.DeviceId{Type: gin.DeviceTypeMouse, Index: 1}}
+	double_click := input.BindMultiTapKey("DoubleClick", mouse, 2, 250)
+
+	c.Specify("Quick taps press the key.", func() {
+		events := make([]gin.OsEvent, 0)
+		injectEvent(&events, gin.MouseLButton, 1, gin.DeviceTypeMouse, 1, 10)
+		injectEvent(&events, gin.MouseLButton, 1, gin.DeviceTypeMouse, 0, 50)
+		injectEvent(&events, gin.MouseLButton, 1, gin.DeviceTypeMouse, 1, 200)
+		input.Think(220, true, events)
+		c.Expect(double_click.FramePressCount(), Equals, 1)
+		events = events[0:0]
+		injectEvent(&events, gin.MouseLButton, 1, gin.DeviceTypeMouse, 0, 230)
+		input.Think(240, true, events)
+		c.Expect(double_click.FrameReleaseCount(), Equals, 1)
+	})
+
+	c.Specify("Slow taps do not press the key.", func() {
+		events := make([]gin.OsEvent, 0)
+		injectEvent(&events, gin.MouseLButton, 1, gin.DeviceTypeMouse, 1, 10)
+		injectEvent(&events, gin.MouseLButton, 1, gin.DeviceTypeMouse, 0, 50)
+		injectEvent(&events, gin.MouseLButton, 1, gin.DeviceTypeMouse, 1, 300)
+		input.Think(320, true, events)
+		c.Expect(double_click.FramePressCount(), Equals, 0)
+
+		events = events[0:0]
+		injectEvent(&events, gin.MouseLButton, 1, gin.DeviceTypeMouse, 0, 330)
+		injectEvent(&events, gin.MouseLButton, 1, gin.DeviceTypeMouse, 1, 340)
+		input.Think(350, true, events)
+		c.Expect(double_click.FramePressCount(), Equals, 1)
+	})
+}
+
+func HoldKeySpec(c gospec.Context) {
+	input := gin.Make()
+	space := gin.KeyId{Index: gin.Space, Device: gin.DeviceId{Type: gin.DeviceTypeKeyboard, Index: 1}}
+	charge := input.BindHoldKey("Charge", space, 500)
+
+	c.Specify("Holding a key long enough presses the hold key.", func() {
+		events := make([]gin.OsEvent, 0)
+		injectEvent(&events, gin.Space, 1, gin.DeviceTypeKeyboard, 1, 10)
+		groups := input.Think(100, true, events)
+		c.Expect(charge.IsDown(), Equals, false)
+		for _, group := range groups {
+			found, _ := group.FindEvent(charge.Id())
+			c.Expect(found, Equals, false)
+		}
+
+		groups = input.Think(510, true, nil)
+		c.Expect(charge.IsDown(), Equals, true)
+		c.Expect(len(groups), Equals, 1)
+		found, event := groups[0].FindEvent(charge.Id())
+		c.Expect(found, Equals, true)
+		c.Expect(event.Type, Equals, gin.Press)
+		c.Expect(groups[0].Timestamp, Equals, int64(510))
+
+		events = events[0:0]
+		injectEvent(&events, gin.Space, 1, gin.DeviceTypeKeyboard, 0, 600)
+		input.Think(610, true, events)
+		c.Expect(charge.IsDown(), Equals, false)
+		c.Expect(charge.FrameReleaseCount(), Equals, 1)
+	})
+
+	c.Specify("Releasing a key early does not press the hold key.", func() {
+		events := make([]gin.OsEvent, 0)
+		injectEvent(&events, gin.Space, 1, gin.DeviceTypeKeyboard, 1, 10)
+		input.Think(100, true, events)
+		events = events[0:0]
+		injectEvent(&events, gin.Space, 1, gin.DeviceTypeKeyboard, 0, 400)
+		input.Think(410, true, events)
+		input.Think(1000, true, nil)
+		c.Expect(charge.IsDown(), Equals, false)
+		c.Expect(charge.FramePressCount(), Equals, 0)
+	})
+}
@@ -0,0 +1,178 @@
+package gin
+
+import (
+	"fmt"
+	"sort"
+)
+
+// Touch devices, touchscreens and touchpads that report absolute positions,
+// have one key for each contact that can be down at once.  A contact keeps
+// the same key from the time it touches down until it lifts, and the key's
+// press amount is the pressure of the contact, which is always greater than
+// zero while it is down.  The position of a contact is the Point() of its
+// key's Cursor.
+
+// MaxTouchContacts is the number of simultaneous contacts that gin tracks on
+// each touch device.
+const MaxTouchContacts = 10
+
+func isTouchContact(index KeyIndex) bool {
+	return index >= TouchContact0 && index < TouchContact0+MaxTouchContacts
+}
+
+type TouchPhase int
+
+const (
+	touchIdle TouchPhase = iota
+
+	// The contact touched down during the frame.
+	TouchBegin
+
+	// The contact moved, or its pressure changed, during the frame.
+	TouchMove
+
+	// The contact was down for the entire frame and did not change.
+	TouchHeld
+
+	// The contact lifted during the frame.
+	TouchEnd
+)
+
+func (p TouchPhase) String() string {
+	switch p {
+	case TouchBegin:
+		return "begin"
+	case TouchMove:
+		return "move"
+	case TouchHeld:
+		return "held"
+	case TouchEnd:
+		return "end"
+	}
+	panic(fmt.Sprintf("%d is not a valid TouchPhase", p))
+}
+
+// A touchKey is the natural key for a single contact on a touch device.
+type touchKey struct {
+	keyState
+	input *Input
+
+	// Id of the current, or most recent, contact on this key.
+	contact int
+
+	// Set when the contact moves, until the next call to SetPressAmt().
+	moved bool
+
+	// Phase of the contact during the current frame and the previous frame.
+	phase, frame_phase TouchPhase
+
+	// Most recent non-zero pressure, so that a contact still has a pressure
+	// during the frame in which it ends.
+	pressure float64
+}
+
+func (tk *touchKey) moveTo(x, y int) {
+	if tk.cursor.X != x || tk.cursor.Y != y {
+		tk.cursor.X, tk.cursor.Y = x, y
+		tk.moved = true
+	}
+}
+
+func (tk *touchKey) SetPressAmt(amt float64, ms int64, cause Event) (event Event) {
+	event = tk.keyState.SetPressAmt(amt, ms, cause)
+	if event.Type == NoEvent && tk.moved && amt != 0 {
+		event.Type = Adjust
+	}
+	tk.moved = false
+	if amt != 0 {
+		tk.pressure = amt
+	}
+	switch event.Type {
+	case Press:
+		tk.input.touch_seq++
+		tk.contact = tk.input.touch_seq
+		tk.phase = TouchBegin
+	case Adjust:
+		if tk.phase != TouchBegin {
+			tk.phase = TouchMove
+		}
+	case Release:
+		tk.phase = TouchEnd
+	}
+	return
+}
+
+func (tk *touchKey) Think(ms int64) (bool, float64) {
+	tk.frame_phase = tk.phase
+	if tk.IsDown() {
+		tk.phase = TouchHeld
+	} else {
+		tk.phase = touchIdle
+	}
+	return tk.keyState.Think(ms)
+}
+
+// A Contact is a single finger, or stylus, on a touch device.
+type Contact struct {
+	// Unique among all contacts on all devices, a contact that lifts and then
+	// touches down again gets a new Id.
+	Id int
+
+	// The TouchContact0+N key that this contact is on.
+	Key Key
+
+	Phase TouchPhase
+
+	// Position of the contact, in the same coordinates as every other Cursor.
+	Cursor Cursor
+
+	// Pressure of the contact, in (0, 1].  Devices that don't report pressure
+	// always report 1.
+	Pressure float64
+}
+
+// touchKeys returns the touch keys on device, or on every touch device if
+// device.Index is DeviceIndexAny, sorted by device and then by key.
+func (input *Input) touchKeys(device DeviceId) []*touchKey {
+	var keys []*touchKey
+	for _, key := range input.all_keys {
+		tk, ok := key.(*touchKey)
+		if !ok {
+			continue
+		}
+		if device.Index != DeviceIndexAny && tk.id.Device != device {
+			continue
+		}
+		keys = append(keys, tk)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].id.Device.Index != keys[j].id.Device.Index {
+			return keys[i].id.Device.Index < keys[j].id.Device.Index
+		}
+		return keys[i].id.Index < keys[j].id.Index
+	})
+	return keys
+}
+
+// Contacts returns every contact that was down during the most recent frame on
+// the specified touch device, or on every touch device if device.Index is
+// DeviceIndexAny.  Contacts that ended during the frame are included, with a
+// Phase of TouchEnd.
+func (input *Input) Contacts(device DeviceId) []Contact {
+	input.mutex.Lock()
+	defer input.mutex.Unlock()
+	var contacts []Contact
+	for _, tk := range input.touchKeys(device) {
+		if tk.frame_phase == touchIdle {
+			continue
+		}
+		contacts = append(contacts, Contact{
+			Id:       tk.contact,
+			Key:      tk,
+			Phase:    tk.frame_phase,
+			Cursor:   tk.cursor,
+			Pressure: tk.pressure,
+		})
+	}
+	return contacts
+}
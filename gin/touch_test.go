@@ -0,0 +1,165 @@
+package gin_test
+
+import (
+	"github.com/orfjackal/gospec/src/gospec"
+	. "github.com/orfjackal/gospec/src/gospec"
+	"github.com/runningwild/glop/gin"
+)
+
+var touchscreen = gin.DeviceId{Type: gin.DeviceTypeTouch, Index: 1}
+
+func touchEvent(contact int, pressure float64, x, y int, ms int64) gin.OsEvent {
+	return gin.OsEvent{
+		KeyId:     gin.KeyId{Index: gin.TouchContact0 + gin.KeyIndex(contact), Device: touchscreen},
+		Press_amt: pressure,
+		Timestamp: ms,
+		X:         x,
+		Y:         y,
+	}
+}
+
+func TouchSpec(c gospec.Context) {
+	input := gin.Make()
+	c.Specify("Contacts report their phase, position and pressure.", func() {
+		input.Think(10, true, []gin.OsEvent{
+			touchEvent(0, 0.5, 100, 200, 5),
+			touchEvent(1, 1, 300, 400, 6),
+		})
+		contacts := input.Contacts(touchscreen)
+		c.Assume(len(contacts), Equals, 2)
+		c.Expect(contacts[0].Phase, Equals, gin.TouchBegin)
+		c.Expect(contacts[0].Pressure, Equals, 0.5)
+		x, y := contacts[0].Cursor.Point()
+		c.Expect(x, Equals, 100)
+		c.Expect(y, Equals, 200)
+		c.Expect(contacts[1].Key.Id().Index, Equals, gin.KeyIndex(gin.TouchContact0+1))
+		c.Expect(contacts[1].Id, Not(Equals), contacts[0].Id)
+		first := contacts[0].Id
+
+		input.Think(20, true, []gin.OsEvent{touchEvent(0, 0.5, 110, 200, 15)})
+		contacts = input.Contacts(touchscreen)
+		c.Assume(len(contacts), Equals, 2)
+		c.Expect(contacts[0].Phase, Equals, gin.TouchMove)
+		c.Expect(contacts[0].Id, Equals, first)
+		x, _ = contacts[0].Cursor.Point()
+		c.Expect(x, Equals, 110)
+		c.Expect(contacts[1].Phase, Equals, gin.TouchHeld)
+
+		input.Think(30, true, []gin.OsEvent{touchEvent(0, 0, 0, 0, 25)})
+		contacts = input.Contacts(touchscreen)
+		c.Assume(len(contacts), Equals, 2)
+		c.Expect(contacts[0].Phase, Equals, gin.TouchEnd)
+		c.Expect(contacts[0].Pressure, Equals, 0.5)
+		x, y = contacts[0].Cursor.Point()
+		c.Expect(x, Equals, 110)
+		c.Expect(y, Equals, 200)
+
+		input.Think(40, true, nil)
+		contacts = input.Contacts(touchscreen)
+		c.Assume(len(contacts), Equals, 1)
+		c.Expect(contacts[0].Key.Id().Index, Equals, gin.KeyIndex(gin.TouchContact0+1))
+
+		input.Think(50, true, []gin.OsEvent{touchEvent(0, 1, 0, 0, 45)})
+		contacts = input.Contacts(gin.DeviceId{Type: gin.DeviceTypeTouch, Index: gin.DeviceIndexAny})
+		c.Assume(len(contacts), Equals, 2)
+		c.Expect(contacts[0].Phase, Equals, gin.TouchBegin)
+		c.Expect(contacts[0].Id, Not(Equals), first)
+	})
+
+	c.Specify("Touch keys are ordinary keys.", func() {
+		input.Think(10, true, []gin.OsEvent{touchEvent(0, 1, 100, 200, 5)})
+		key := input.GetKey(gin.KeyId{Index: gin.TouchContact0, Device: touchscreen})
+		c.Expect(key.IsDown(), Equals, true)
+		c.Expect(key.Cursor(), Not(IsNil))
+		any_touch := input.GetKey(gin.KeyId{Index: gin.AnyKey, Device: gin.DeviceId{Type: gin.DeviceTypeTouch, Index: gin.DeviceIndexAny}})
+		c.Expect(any_touch.IsDown(), Equals, true)
+	})
+}
+
+func GestureSpec(c gospec.Context) {
+	input := gin.Make()
+	c.Specify("Tap keys are pressed for one frame by short, still contacts.", func() {
+		tap := input.BindTapKey("tap", touchscreen, 200, 10)
+		input.Think(10, true, []gin.OsEvent{touchEvent(0, 1, 100, 100, 5)})
+		c.Expect(tap.IsDown(), Equals, false)
+		input.Think(20, true, []gin.OsEvent{
+			touchEvent(0, 1, 105, 100, 15),
+			touchEvent(0, 0, 0, 0, 18),
+		})
+		c.Expect(tap.FramePressCount(), Equals, 1)
+		c.Expect(tap.IsDown(), Equals, false)
+		x, y := tap.Cursor().Point()
+		c.Expect(x, Equals, 100)
+		c.Expect(y, Equals, 100)
+
+		// Too slow
+		input.Think(30, true, []gin.OsEvent{touchEvent(0, 1, 100, 100, 25)})
+		input.Think(300, true, []gin.OsEvent{touchEvent(0, 0, 0, 0, 295)})
+		c.Expect(tap.FramePressCount(), Equals, 0)
+
+		// Moved too far, even though it came back
+		input.Think(310, true, []gin.OsEvent{
+			touchEvent(0, 1, 100, 100, 301),
+			touchEvent(0, 1, 150, 100, 302),
+			touchEvent(0, 1, 100, 100, 303),
+			touchEvent(0, 0, 0, 0, 304),
+		})
+		c.Expect(tap.FramePressCount(), Equals, 0)
+	})
+
+	c.Specify("Swipe keys are pressed by fast moves in their direction.", func() {
+		up := input.BindSwipeKey("up", touchscreen, gin.SwipeUp, 50, 300)
+		right := input.BindSwipeKey("right", touchscreen, gin.SwipeRight, 50, 300)
+		input.Think(10, true, []gin.OsEvent{
+			touchEvent(0, 1, 100, 100, 5),
+			touchEvent(0, 1, 110, 130, 6),
+		})
+		c.Expect(up.FramePressCount(), Equals, 0)
+		input.Think(20, true, []gin.OsEvent{touchEvent(0, 1, 120, 160, 15)})
+		c.Expect(up.FramePressCount(), Equals, 1)
+		c.Expect(right.FramePressCount(), Equals, 0)
+		x, y := up.Cursor().Point()
+		c.Expect(x, Equals, 100)
+		c.Expect(y, Equals, 100)
+
+		// A contact only swipes once.
+		input.Think(30, true, []gin.OsEvent{touchEvent(0, 1, 120, 260, 25)})
+		c.Expect(up.FramePressCount(), Equals, 0)
+		input.Think(40, true, []gin.OsEvent{touchEvent(0, 0, 0, 0, 35)})
+
+		// Too slow
+		input.Think(50, true, []gin.OsEvent{touchEvent(0, 1, 100, 100, 45)})
+		input.Think(500, true, []gin.OsEvent{touchEvent(0, 1, 200, 100, 495)})
+		c.Expect(right.FramePressCount(), Equals, 0)
+	})
+
+	c.Specify("Pinch keys follow the distance between two contacts.", func() {
+		pinch := input.BindPinchKey("pinch", touchscreen, 0.1)
+		input.Think(10, true, []gin.OsEvent{
+			touchEvent(0, 1, 100, 100, 5),
+			touchEvent(1, 1, 200, 100, 6),
+		})
+		c.Expect(pinch.IsDown(), Equals, false)
+		input.Think(20, true, []gin.OsEvent{touchEvent(1, 1, 205, 100, 15)})
+		c.Expect(pinch.IsDown(), Equals, false)
+		input.Think(30, true, []gin.OsEvent{touchEvent(1, 1, 300, 100, 25)})
+		c.Expect(pinch.IsDown(), Equals, true)
+		c.Expect(pinch.CurPressAmt(), IsWithin(1e-9), 2.0)
+		x, y := pinch.Cursor().Point()
+		c.Expect(x, Equals, 200)
+		c.Expect(y, Equals, 100)
+		input.Think(40, true, []gin.OsEvent{touchEvent(0, 1, 250, 100, 35)})
+		c.Expect(pinch.CurPressAmt(), IsWithin(1e-9), 0.5)
+		input.Think(50, true, []gin.OsEvent{touchEvent(0, 0, 0, 0, 45)})
+		c.Expect(pinch.IsDown(), Equals, false)
+	})
+
+	c.Specify("Gesture keys can watch every touch device.", func() {
+		tap := input.BindTapKey("tap", gin.DeviceId{Type: gin.DeviceTypeTouch, Index: gin.DeviceIndexAny}, 200, 10)
+		input.Think(10, true, []gin.OsEvent{
+			touchEvent(3, 1, 100, 100, 5),
+			touchEvent(3, 0, 0, 0, 6),
+		})
+		c.Expect(tap.FramePressCount(), Equals, 1)
+	})
+}
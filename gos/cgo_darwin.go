@@ -5,8 +5,10 @@ package gos
 import "C"
 
 import (
+	"fmt"
 	"github.com/runningwild/glop/gin"
 	"github.com/runningwild/glop/system"
+	"image"
 	"sync"
 	"unsafe"
 )
@@ -122,6 +124,25 @@ func (osx *osxSystemObject) GetInputEvents() ([]gin.OsEvent, int64) {
 	return events, osx.horizon
 }
 
+func (osx *osxSystemObject) GetTextEvents() []gin.TextEvent {
+	// TODO: Implement me!
+	return nil
+}
+
+func (osx *osxSystemObject) GetDeviceEvents() []gin.DeviceEvent {
+	// TODO: Implement me!
+	return nil
+}
+
+func (osx *osxSystemObject) GetWindowEvents() []system.WindowEvent {
+	// TODO: Implement me!
+	return nil
+}
+
+func (osx *osxSystemObject) DestroyWindow() {
+	// TODO: Implement me!
+}
+
 func (osx *osxSystemObject) GetCursorPos() (int, int) {
 	globalLock.Lock()
 	var x, y C.int
@@ -143,6 +164,11 @@ func (osx *osxSystemObject) HideCursor(hide bool) {
 	}
 }
 
+// TODO: The deltas reported while the cursor is locked are still accelerated.
+func (osx *osxSystemObject) SetRelativeMouseMode(enable bool) {
+	osx.HideCursor(enable)
+}
+
 func (osx *osxSystemObject) GetWindowDims() (int, int, int, int) {
 	globalLock.Lock()
 	defer globalLock.Unlock()
@@ -151,6 +177,54 @@ func (osx *osxSystemObject) GetWindowDims() (int, int, int, int) {
 	return int(x), int(y), int(dx), int(dy)
 }
 
+func (osx *osxSystemObject) SetWindowTitle(title string) {
+	// TODO: Implement me!
+}
+
+func (osx *osxSystemObject) SetWindowIcon(icon image.Image) {
+	// TODO: Implement me!
+}
+
+func (osx *osxSystemObject) SetCursorImage(img image.Image, hot_x, hot_y int) error {
+	// TODO: Implement me!
+	return system.CheckCursorImage(img, hot_x, hot_y)
+}
+
+func (osx *osxSystemObject) SetStandardCursor(cursor system.StandardCursor) {
+	// TODO: Implement me!
+}
+
+func (osx *osxSystemObject) GetClipboardText() string {
+	// TODO: Implement me!
+	return ""
+}
+
+func (osx *osxSystemObject) SetClipboardText(text string) {
+	// TODO: Implement me!
+}
+
+func (osx *osxSystemObject) GetMonitors() []system.Monitor {
+	// TODO: Implement me!
+	return nil
+}
+
+func (osx *osxSystemObject) SetWindowMode(mode system.WindowMode, monitor int, display_mode system.DisplayMode) error {
+	if mode == system.Windowed {
+		return nil
+	}
+	// TODO: Implement me!
+	return fmt.Errorf("Can't switch to %v, it isn't implemented yet.", mode)
+}
+
+func (osx *osxSystemObject) GetWindowMode() system.WindowMode {
+	return system.Windowed
+}
+
+func (osx *osxSystemObject) GetContentScale() float64 {
+	// TODO: Implement me!
+	return 1
+}
+
 func (osx *osxSystemObject) EnableVSync(enable bool) {
 	globalLock.Lock()
 	defer globalLock.Unlock()
@@ -1,35 +1,41 @@
 package gos
 
-// #cgo LDFLAGS: -Llinux/lib -lglop -lX11 -lGL
+// #cgo LDFLAGS: -Llinux/lib -lglop -lX11 -lXi -lXrandr -lXcursor -lGL
+// #include <stdlib.h>
 // #include "linux/include/glop.h"
 import "C"
 
 import (
 	"fmt"
 	"github.com/runningwild/glop/gin"
+	"github.com/runningwild/glop/gos/evdev"
 	"github.com/runningwild/glop/system"
+	"image"
+	"image/color"
 	"os"
 	"sort"
 	"strings"
 	"sync"
+	"syscall"
 	"time"
 	"unsafe"
 )
 
 type linuxSystemObject struct {
 	horizon int64
+	title   string
 }
 
 var (
-	linux_system_object linuxSystemObject
-	jsCollect           chan jsInput
+	linux_system_object = linuxSystemObject{title: "glop"}
+	evdevCollect        chan []gin.OsEvent
 )
 
 // Call after runtime.LockOSThread(), *NOT* in an init function
 func (linux *linuxSystemObject) Startup() {
 	C.GlopInit()
-	jsCollect = make(chan jsInput, 100)
-	go trackJoysticks(jsCollect)
+	evdevCollect = make(chan []gin.OsEvent, 100)
+	go trackDevices(evdevCollect)
 }
 
 func GetSystemInterface() system.Os {
@@ -45,7 +51,76 @@ func (linux *linuxSystemObject) Quit() {
 }
 
 func (linux *linuxSystemObject) CreateWindow(x, y, width, height int) {
-	C.GlopCreateWindow(unsafe.Pointer(&(([]byte("linux window"))[0])), C.int(x), C.int(y), C.int(width), C.int(height))
+	title := C.CString(linux.title)
+	defer C.free(unsafe.Pointer(title))
+	C.GlopCreateWindow(unsafe.Pointer(title), C.int(x), C.int(y), C.int(width), C.int(height))
+}
+
+func (linux *linuxSystemObject) SetWindowTitle(title string) {
+	linux.title = title
+	c_title := C.CString(title)
+	defer C.free(unsafe.Pointer(c_title))
+	C.GlopSetWindowTitle(c_title)
+}
+
+// imageToARGB returns the pixels of img, row by row, as 0xAARRGGBB.  X wants
+// cursors premultiplied by alpha, and icons not.
+func imageToARGB(img image.Image, premultiplied bool) []C.uint {
+	bounds := img.Bounds()
+	pixels := make([]C.uint, 0, bounds.Dx()*bounds.Dy())
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			var r, g, b, a uint32
+			if premultiplied {
+				r, g, b, a = img.At(x, y).RGBA()
+			} else {
+				c := color.NRGBA64Model.Convert(img.At(x, y)).(color.NRGBA64)
+				r, g, b, a = uint32(c.R), uint32(c.G), uint32(c.B), uint32(c.A)
+			}
+			pixels = append(pixels, C.uint((a>>8)<<24|(r>>8)<<16|(g>>8)<<8|b>>8))
+		}
+	}
+	return pixels
+}
+
+func (linux *linuxSystemObject) SetWindowIcon(icon image.Image) {
+	if icon == nil || icon.Bounds().Empty() {
+		C.GlopSetWindowIcon(0, 0, nil)
+		return
+	}
+	pixels := imageToARGB(icon, false)
+	C.GlopSetWindowIcon(C.int(icon.Bounds().Dx()), C.int(icon.Bounds().Dy()), &pixels[0])
+}
+
+func (linux *linuxSystemObject) SetCursorImage(img image.Image, hot_x, hot_y int) error {
+	if err := system.CheckCursorImage(img, hot_x, hot_y); err != nil {
+		return err
+	}
+	if img == nil {
+		C.GlopSetCursorImage(0, 0, nil, 0, 0)
+		return nil
+	}
+	pixels := imageToARGB(img, true)
+	C.GlopSetCursorImage(C.int(img.Bounds().Dx()), C.int(img.Bounds().Dy()), &pixels[0], C.int(hot_x), C.int(hot_y))
+	return nil
+}
+
+func (linux *linuxSystemObject) SetStandardCursor(cursor system.StandardCursor) {
+	C.GlopSetStandardCursor(C.int(cursor))
+}
+
+func (linux *linuxSystemObject) GetClipboardText() string {
+	return C.GoString(C.GlopGetClipboardText())
+}
+
+func (linux *linuxSystemObject) SetClipboardText(text string) {
+	c_text := C.CString(text)
+	defer C.free(unsafe.Pointer(c_text))
+	C.GlopSetClipboardText(c_text)
+}
+
+func (linux *linuxSystemObject) DestroyWindow() {
+	C.GlopDestroyWindow()
 }
 
 func (linux *linuxSystemObject) SwapBuffers() {
@@ -56,128 +131,279 @@ func (linux *linuxSystemObject) Think() {
 	C.GlopThink()
 }
 
+// X11 only reports the core keyboard and pointer, which merge every keyboard
+// and every mouse together.  They are used as keyboard and mouse linuxCoreIndex
+// only if no keyboard or mouse, respectively, can be read through evdev, which
+// typically requires the user to be in the input group.  Devices read through
+// evdev are numbered from 1.
+const linuxCoreIndex = 0
+
 func (linux *linuxSystemObject) GetActiveDevices() map[gin.DeviceType][]gin.DeviceIndex {
-	return nil
+	active := devices.activeIndexes()
+	for _, device_type := range []gin.DeviceType{gin.DeviceTypeKeyboard, gin.DeviceTypeMouse} {
+		if len(active[device_type]) == 0 {
+			active[device_type] = []gin.DeviceIndex{linuxCoreIndex}
+		}
+	}
+	return active
+}
+
+func (linux *linuxSystemObject) GetDeviceEvents() []gin.DeviceEvent {
+	events := devices.takeEvents()
+	for i := range events {
+		events[i].Timestamp = linux.horizon
+	}
+	return events
+}
+
+// deviceTracker keeps track of which evdev devices are plugged in.  Devices
+// are identified by evdev.Info.Identity(), which doesn't change if a device is
+// unplugged and plugged back into the same port, so a device keeps its
+// DeviceIndex when it is reconnected.
+type deviceTracker struct {
+	mutex sync.Mutex
+
+	// Indexes assigned to each identity, for each type of device.
+	indexes map[gin.DeviceType]map[string]gin.DeviceIndex
+
+	// Paths of devices that are being polled, and the subset of those that have
+	// been opened successfully and reported as connected.
+	polling   map[string]bool
+	connected map[string]connectedDevice
+
+	// Device events that haven't been returned by takeEvents() yet.
+	events []gin.DeviceEvent
+}
+
+type connectedDevice struct {
+	id       gin.DeviceId
+	identity string
 }
 
-type jsInput struct {
-	TimestampMs uint32
-	Value       int16
-	Type        uint8
+var devices = deviceTracker{
+	indexes:   make(map[gin.DeviceType]map[string]gin.DeviceIndex),
+	polling:   make(map[string]bool),
+	connected: make(map[string]connectedDevice),
+}
 
-	// This is read from an 8-bit value, but glop supports more values so it
-	// gets read into a 32 bit int.
-	Key uint32
+// index returns the DeviceIndex for the device of the specified type with the
+// specified identity, assigning a new one if this device has never been seen
+// before.
+func (dt *deviceTracker) index(device_type gin.DeviceType, identity string) gin.DeviceIndex {
+	indexes := dt.indexes[device_type]
+	if indexes == nil {
+		indexes = make(map[string]gin.DeviceIndex)
+		dt.indexes[device_type] = indexes
+	}
+	if index, ok := indexes[identity]; ok {
+		return index
+	}
+	index := gin.DeviceIndex(len(indexes) + 1)
+	indexes[identity] = index
+	return index
+}
 
-	// Since we translate axis values into floats we want this value here for that.
-	FValue float64
+// startPolling returns true iff path was not already being polled, in which
+// case the caller should start polling it.
+func (dt *deviceTracker) startPolling(path string) bool {
+	dt.mutex.Lock()
+	defer dt.mutex.Unlock()
+	if dt.polling[path] {
+		return false
+	}
+	dt.polling[path] = true
+	return true
+}
 
-	Index int
+// connect returns the DeviceId for the device at path.  Devices that aren't
+// keyboards, mice or gamepads are not reported and get a DeviceId with a Type
+// of DeviceTypeAny.
+func (dt *deviceTracker) connect(path string, info *evdev.Info) gin.DeviceId {
+	device_type := info.DeviceType()
+	if device_type == gin.DeviceTypeAny {
+		return gin.DeviceId{Type: gin.DeviceTypeAny, Index: gin.DeviceIndexAny}
+	}
+	dt.mutex.Lock()
+	defer dt.mutex.Unlock()
+
+	// Two identical devices on the same port, e.g. a wireless receiver for two
+	// gamepads, need different identities.
+	identity := info.Identity()
+	for n := 2; dt.inUse(device_type, identity); n++ {
+		identity = fmt.Sprintf("%s#%d", info.Identity(), n)
+	}
+	id := gin.DeviceId{Type: device_type, Index: dt.index(device_type, identity)}
+	dt.connected[path] = connectedDevice{id: id, identity: identity}
+	dt.events = append(dt.events, gin.DeviceEvent{
+		Type:   gin.DeviceConnected,
+		Device: id,
+		Name:   info.Name,
+		GUID:   info.GUID(),
+	})
+	return id
 }
 
-const kControllerButton0 = 500
-const kControllerAxis0Positive = 70000
-const kControllerAxis0Negative = 80000
-const kControllerHatSwitchUp = 90000
-const kControllerHatSwitchUpRight = 90001
-const kControllerHatSwitchRight = 90002
-const kControllerHatSwitchDownRight = 90003
-const kControllerHatSwitchDown = 90004
-const kControllerHatSwitchDownLeft = 90005
-const kControllerHatSwitchLeft = 90006
-const kControllerHatSwitchUpLeft = 90007
+func (dt *deviceTracker) inUse(device_type gin.DeviceType, identity string) bool {
+	for _, device := range dt.connected {
+		if device.id.Type == device_type && device.identity == identity {
+			return true
+		}
+	}
+	return false
+}
 
-func parsejsInput(b []byte) (jsInput, error) {
-	var js jsInput
-	if len(b) != 8 {
-		return js, fmt.Errorf("Expected 8 bytes, got %d.", len(b))
+// stopPolling is called when a device can't be read anymore, if it had been
+// connected then it has now been disconnected.
+func (dt *deviceTracker) stopPolling(path string) {
+	dt.mutex.Lock()
+	defer dt.mutex.Unlock()
+	delete(dt.polling, path)
+	device, ok := dt.connected[path]
+	if !ok {
+		return
 	}
-	js.TimestampMs = uint32(b[0]) | uint32(b[1])<<8 | uint32(b[2])<<16 | uint32(b[3])<<24
-	js.Value = int16(b[4]) | int16(b[5])<<8
-	js.Type = b[6]
-	js.Key = uint32(b[7])
-	return js, nil
+	delete(dt.connected, path)
+	dt.events = append(dt.events, gin.DeviceEvent{
+		Type:   gin.DeviceDisconnected,
+		Device: device.id,
+	})
 }
 
-func pollJoysticks(name string, index int, jsCollect chan<- jsInput, onDeath func(string)) {
-	defer onDeath(name)
+// hasType returns true iff at least one device of the specified type is
+// connected.
+func (dt *deviceTracker) hasType(device_type gin.DeviceType) bool {
+	dt.mutex.Lock()
+	defer dt.mutex.Unlock()
+	for _, device := range dt.connected {
+		if device.id.Type == device_type {
+			return true
+		}
+	}
+	return false
+}
 
-	f, err := os.Open("/dev/input/by-path/" + name)
+func (dt *deviceTracker) activeIndexes() map[gin.DeviceType][]gin.DeviceIndex {
+	dt.mutex.Lock()
+	defer dt.mutex.Unlock()
+	active := make(map[gin.DeviceType][]gin.DeviceIndex)
+	for _, device := range dt.connected {
+		active[device.id.Type] = append(active[device.id.Type], device.id.Index)
+	}
+	for _, indexes := range active {
+		sort.Sort(deviceIndexSlice(indexes))
+	}
+	return active
+}
+
+func (dt *deviceTracker) takeEvents() []gin.DeviceEvent {
+	dt.mutex.Lock()
+	defer dt.mutex.Unlock()
+	events := dt.events
+	dt.events = nil
+	return events
+}
+
+type deviceIndexSlice []gin.DeviceIndex
+
+func (dis deviceIndexSlice) Len() int           { return len(dis) }
+func (dis deviceIndexSlice) Swap(i, j int)      { dis[i], dis[j] = dis[j], dis[i] }
+func (dis deviceIndexSlice) Less(i, j int) bool { return dis[i] < dis[j] }
+
+// pollDevice reads the evdev device at path until it is unplugged.  Devices
+// that aren't keyboards, mice or gamepads are still read, and their events
+// discarded, so that the path isn't reopened on every scan.
+func pollDevice(path string, collect chan<- []gin.OsEvent) {
+	defer devices.stopPolling(path)
+
+	d, err := evdev.Open(path)
 	if err != nil {
+		// This happens if the device node exists but its permissions haven't been
+		// set up yet, or if we aren't allowed to read it at all.  We'll try again
+		// on the next scan.
 		return
 	}
-	defer f.Close()
-	buf := make([]byte, 1024)
+	defer d.Close()
+	id := devices.connect(path, d.Info)
+	translator := evdev.MakeTranslator(d.Info, id)
 	for {
-		n, err := f.Read(buf)
+		event, err := d.ReadEvent()
 		if err != nil {
 			return
 		}
-		tmp := buf[0:n]
-		for len(tmp) >= 8 {
-			js, err := parsejsInput(tmp[0:8])
-			if err != nil {
-				continue
-			}
-			tmp = tmp[8:]
-			switch js.Type {
-			case 1: // A regular button
-				js.Key += kControllerButton0
-				js.FValue = float64(js.Value)
-
-			case 2: // An Axis value
-				if js.Value < 0 {
-					js.Key += kControllerAxis0Negative
-					js.FValue = float64(-js.Value) / 32768
-				} else {
-					js.Key += kControllerAxis0Positive
-					js.FValue = float64(js.Value) / 32768
-				}
+		if id.Type == gin.DeviceTypeAny {
+			continue
+		}
+		if events := translator.Translate(event); len(events) > 0 {
+			collect <- events
+		}
+	}
+}
 
+// watchInputDir returns a channel that receives a value whenever something in
+// /dev/input is created, removed or has its permissions changed, or nil if
+// inotify isn't available.
+func watchInputDir() <-chan bool {
+	fd, err := syscall.InotifyInit()
+	if err != nil {
+		return nil
+	}
+	mask := uint32(syscall.IN_CREATE | syscall.IN_DELETE | syscall.IN_ATTRIB)
+	if _, err := syscall.InotifyAddWatch(fd, "/dev/input", mask); err != nil {
+		syscall.Close(fd)
+		return nil
+	}
+	changed := make(chan bool, 1)
+	go func() {
+		defer syscall.Close(fd)
+		buf := make([]byte, 4096)
+		for {
+			if _, err := syscall.Read(fd, buf); err != nil {
+				return
+			}
+			select {
+			case changed <- true:
 			default:
-				continue
 			}
-			js.Index = index
-			jsCollect <- js
 		}
-	}
+	}()
+	return changed
 }
 
-func trackJoysticks(jsCollect chan<- jsInput) error {
-	defer close(jsCollect)
-	var jsMutex sync.Mutex
-	active := make(map[string]bool)
-	onDeath := func(name string) {
-		jsMutex.Lock()
-		delete(active, name)
-		jsMutex.Unlock()
-	}
-	index := 0
+// trackDevices starts polling every evdev device as soon as it is plugged in.
+// Devices that are unplugged are noticed by pollDevice when reads fail.
+func trackDevices(collect chan<- []gin.OsEvent) {
+	changed := watchInputDir()
 	for {
-		f, err := os.Open("/dev/input/by-path")
-		if err != nil {
-			return err
-		}
-		names, err := f.Readdirnames(0)
-		f.Close()
-		if err != nil {
-			return err
+		var names []string
+		if f, err := os.Open("/dev/input"); err == nil {
+			names, _ = f.Readdirnames(0)
+			f.Close()
 		}
 		for _, name := range names {
-			if strings.Contains(name, "event") {
+			if !strings.HasPrefix(name, "event") {
 				continue
 			}
-			jsMutex.Lock()
-			if !active[name] {
-				active[name] = true
-				index++
-				go pollJoysticks(name, index, jsCollect, onDeath)
+			path := "/dev/input/" + name
+			if devices.startPolling(path) {
+				go pollDevice(path, collect)
 			}
-			jsMutex.Unlock()
 		}
-		time.Sleep(time.Second * 5)
+		select {
+		case <-changed:
+			// udev sets the permissions on a device a moment after creating it.
+			time.Sleep(100 * time.Millisecond)
+		case <-time.After(time.Second):
+		}
 	}
-	return nil
+}
+
+// coreDeviceType returns the type of device that an X11 event for the key with
+// the specified index came from.
+func coreDeviceType(index gin.KeyIndex) gin.DeviceType {
+	if index >= gin.MouseXAxis && index <= gin.MouseMButton {
+		return gin.DeviceTypeMouse
+	}
+	return gin.DeviceTypeKeyboard
 }
 
 type osEventSlice []gin.OsEvent
@@ -198,35 +424,51 @@ func (linux *linuxSystemObject) GetInputEvents() ([]gin.OsEvent, int64) {
 	C.GlopGetInputEvents(cp, unsafe.Pointer(&length), unsafe.Pointer(&horizon))
 	linux.horizon = int64(horizon)
 	c_events := (*[1000]C.GlopKeyEvent)(unsafe.Pointer(first_event))[:length]
-	events := make([]gin.OsEvent, length)
+	var events []gin.OsEvent
+	use_core := map[gin.DeviceType]bool{
+		gin.DeviceTypeKeyboard: !devices.hasType(gin.DeviceTypeKeyboard),
+		gin.DeviceTypeMouse:    !devices.hasType(gin.DeviceTypeMouse),
+	}
 	for i := range c_events {
-		events[i] = gin.OsEvent{
+		index := gin.KeyIndex(c_events[i].index)
+		device_type := coreDeviceType(index)
+		if !use_core[device_type] {
+			continue
+		}
+		events = append(events, gin.OsEvent{
 			KeyId: gin.KeyId{
 				Device: gin.DeviceId{
-					Index: 5,
-					Type:  gin.DeviceTypeKeyboard,
+					Index: linuxCoreIndex,
+					Type:  device_type,
 				},
-				Index: gin.KeyIndex(c_events[i].index),
+				Index: index,
 			},
 			Press_amt: float64(c_events[i].press_amt),
 			Timestamp: int64(c_events[i].timestamp),
-		}
+		})
 	}
+
+	// evdev reads devices whether or not our window has focus, X11 only sends
+	// us keyboard and mouse events while it does.
+	focused := linux.HasFocus()
+	var touch *touchTransform
 	done := false
 	for !done {
 		select {
-		case event := <-jsCollect:
-			events = append(events, gin.OsEvent{
-				KeyId: gin.KeyId{
-					Device: gin.DeviceId{
-						Index: gin.DeviceIndex(event.Index + 1),
-						Type:  gin.DeviceTypeController,
-					},
-					Index: gin.KeyIndex(event.Key),
-				},
-				Press_amt: event.FValue,
-				Timestamp: int64(event.TimestampMs),
-			})
+		case packet := <-evdevCollect:
+			device_type := packet[0].KeyId.Device.Type
+			if device_type != gin.DeviceTypeController && !focused {
+				continue
+			}
+			if device_type == gin.DeviceTypeTouch {
+				if touch == nil {
+					touch = linux.makeTouchTransform()
+				}
+				for i := range packet {
+					packet[i].X, packet[i].Y = touch.apply(packet[i].X, packet[i].Y)
+				}
+			}
+			events = append(events, packet...)
 		default:
 			done = true
 		}
@@ -236,7 +478,91 @@ func (linux *linuxSystemObject) GetInputEvents() ([]gin.OsEvent, int64) {
 	// return nil, 0
 }
 
+// touchTransform converts touch positions from evdev, which are scaled to the
+// whole screen, to window coordinates.  This assumes that the touchscreen
+// covers the entire X screen, which isn't true if it is one of several
+// monitors.
+type touchTransform struct {
+	screen_dx, screen_dy int
+	x, y, dy             int
+}
+
+func (linux *linuxSystemObject) makeTouchTransform() *touchTransform {
+	var t touchTransform
+	var dx, dy C.int
+	C.GlopGetScreenSize(&dx, &dy)
+	t.screen_dx, t.screen_dy = int(dx), int(dy)
+	t.x, t.y, _, t.dy = linux.GetWindowDims()
+	return &t
+}
+
+func (t *touchTransform) apply(x, y int) (int, int) {
+	x = x*t.screen_dx/evdev.TouchScale - t.x
+	y = y*t.screen_dy/evdev.TouchScale - t.y
+	return x, t.dy - y
+}
+
+func (linux *linuxSystemObject) GetTextEvents() []gin.TextEvent {
+	var first_event *C.GlopTextEvent
+	cp := (*unsafe.Pointer)(unsafe.Pointer(&first_event))
+	var length C.int
+	C.GlopGetTextEvents(cp, unsafe.Pointer(&length))
+	c_events := (*[1000]C.GlopTextEvent)(unsafe.Pointer(first_event))[:length]
+	events := make([]gin.TextEvent, length)
+	for i := range c_events {
+		events[i] = gin.TextEvent{
+			Text:   C.GoString(c_events[i].text),
+			Cursor: int(c_events[i].cursor),
+			Device: gin.DeviceId{
+				Index: linuxCoreIndex,
+				Type:  gin.DeviceTypeKeyboard,
+			},
+			Timestamp: int64(c_events[i].timestamp),
+		}
+		if c_events[i]._type == C.glopTextComposition {
+			events[i].Type = gin.TextComposition
+		}
+	}
+	return events
+}
+
+func (linux *linuxSystemObject) GetWindowEvents() []system.WindowEvent {
+	var first_event *C.GlopWindowEvent
+	cp := (*unsafe.Pointer)(unsafe.Pointer(&first_event))
+	var length C.int
+	C.GlopGetWindowEvents(cp, unsafe.Pointer(&length))
+	c_events := (*[1000]C.GlopWindowEvent)(unsafe.Pointer(first_event))[:length]
+	events := make([]system.WindowEvent, length)
+	for i := range c_events {
+		events[i] = system.WindowEvent{
+			Type:      system.WindowEventType(c_events[i]._type),
+			X:         int(c_events[i].x),
+			Y:         int(c_events[i].y),
+			Dx:        int(c_events[i].dx),
+			Dy:        int(c_events[i].dy),
+			Timestamp: int64(c_events[i].timestamp),
+		}
+	}
+	return events
+}
+
 func (linux *linuxSystemObject) HideCursor(hide bool) {
+	var _hide C.int
+	if hide {
+		_hide = 1
+	}
+	C.GlopHideCursor(_hide)
+}
+
+// In relative mode X11 reports raw motion through XInput2, but only for the
+// core pointer.  Each mouse only reports its own motion if it can be read
+// through evdev, which always reports raw motion.
+func (linux *linuxSystemObject) SetRelativeMouseMode(enable bool) {
+	var _enable C.int
+	if enable {
+		_enable = 1
+	}
+	C.GlopSetRelativeMouseMode(_enable)
 }
 
 func (linux *linuxSystemObject) rawCursorToWindowCoords(x, y int) (int, int) {
@@ -256,6 +582,74 @@ func (linux *linuxSystemObject) GetWindowDims() (int, int, int, int) {
 	return int(x), int(y), int(dx), int(dy)
 }
 
+func (linux *linuxSystemObject) GetMonitors() []system.Monitor {
+	var first_monitor *C.GlopMonitor
+	var first_mode *C.GlopDisplayMode
+	var num_monitors, num_modes C.int
+	C.GlopGetMonitors(
+		(*unsafe.Pointer)(unsafe.Pointer(&first_monitor)), unsafe.Pointer(&num_monitors),
+		(*unsafe.Pointer)(unsafe.Pointer(&first_mode)), unsafe.Pointer(&num_modes))
+	// The arrays are NULL when they're empty, and slicing a nil array pointer
+	// panics even for an empty slice.
+	if num_monitors == 0 {
+		return nil
+	}
+	c_monitors := (*[1000]C.GlopMonitor)(unsafe.Pointer(first_monitor))[:num_monitors]
+	var c_modes []C.GlopDisplayMode
+	if num_modes > 0 {
+		c_modes = (*[100000]C.GlopDisplayMode)(unsafe.Pointer(first_mode))[:num_modes]
+	}
+	scale := float64(C.GlopGetContentScale())
+	monitors := make([]system.Monitor, num_monitors)
+	for i := range c_monitors {
+		c_monitor := &c_monitors[i]
+		monitors[i] = system.Monitor{
+			Name:         C.GoString(&c_monitor.name[0]),
+			X:            int(c_monitor.x),
+			Y:            int(c_monitor.y),
+			Dx:           int(c_monitor.dx),
+			Dy:           int(c_monitor.dy),
+			Primary:      c_monitor.primary != 0,
+			Current:      goDisplayMode(c_monitor.current),
+			ContentScale: scale,
+		}
+		var modes []system.DisplayMode
+		for _, c_mode := range c_modes[c_monitor.first_mode : c_monitor.first_mode+c_monitor.num_modes] {
+			modes = append(modes, goDisplayMode(c_mode))
+		}
+		monitors[i].Modes = system.SortDisplayModes(modes)
+	}
+	return monitors
+}
+
+func goDisplayMode(c_mode C.GlopDisplayMode) system.DisplayMode {
+	return system.DisplayMode{
+		Width:   int(c_mode.width),
+		Height:  int(c_mode.height),
+		Refresh: float64(c_mode.refresh),
+	}
+}
+
+func (linux *linuxSystemObject) SetWindowMode(mode system.WindowMode, monitor int, display_mode system.DisplayMode) error {
+	if err := system.CheckWindowMode(linux.GetMonitors(), mode, monitor, display_mode); err != nil {
+		return err
+	}
+	if C.GlopSetWindowMode(C.int(mode), C.int(monitor), C.int(display_mode.Width), C.int(display_mode.Height), C.double(display_mode.Refresh)) != 0 {
+		return fmt.Errorf("Unable to switch to %v on monitor %d.", mode, monitor)
+	}
+	return nil
+}
+
+func (linux *linuxSystemObject) GetWindowMode() system.WindowMode {
+	return system.WindowMode(C.GlopGetWindowMode())
+}
+
+// X11 only has a single content scale, from Xft.dpi, rather than one per
+// monitor.
+func (linux *linuxSystemObject) GetContentScale() float64 {
+	return float64(C.GlopGetContentScale())
+}
+
 func (linux *linuxSystemObject) EnableVSync(enable bool) {
 	var _enable C.int
 	if enable {
@@ -265,6 +659,5 @@ func (linux *linuxSystemObject) EnableVSync(enable bool) {
 }
 
 func (linux *linuxSystemObject) HasFocus() bool {
-	// TODO: Implement me!
-	return true
+	return C.GlopHasFocus() != 0
 }
@@ -5,18 +5,21 @@ package gos
 import "C"
 
 import (
+	"fmt"
 	"github.com/runningwild/glop/gin"
 	"github.com/runningwild/glop/system"
+	"image"
 	"unsafe"
 )
 
 type win32SystemObject struct {
 	horizon int64
 	window  uintptr
+	title   string
 }
 
 var (
-	win32_system_object win32SystemObject
+	win32_system_object = win32SystemObject{title: "Glop"}
 )
 
 // Call after runtime.LockOSThread(), *NOT* in an init function
@@ -41,7 +44,7 @@ func (win32 *win32SystemObject) Quit() {
 }
 
 func (win32 *win32SystemObject) CreateWindow(x, y, width, height int) {
-	title := []byte("Glop")
+	title := []byte(win32.title)
 	title = append(title, 0)
 	win32.window = uintptr(unsafe.Pointer(C.GlopCreateWindow(
 		unsafe.Pointer(&title[0]),
@@ -91,6 +94,25 @@ func (win32 *win32SystemObject) rawCursorToWindowCoords(x, y int) (int, int) {
 	return x - wx, wy + wdy - y
 }
 
+func (win32 *win32SystemObject) GetTextEvents() []gin.TextEvent {
+	// TODO: Implement me!
+	return nil
+}
+
+func (win32 *win32SystemObject) GetDeviceEvents() []gin.DeviceEvent {
+	// TODO: Implement me!
+	return nil
+}
+
+func (win32 *win32SystemObject) GetWindowEvents() []system.WindowEvent {
+	// TODO: Implement me!
+	return nil
+}
+
+func (win32 *win32SystemObject) DestroyWindow() {
+	// TODO: Implement me!
+}
+
 func (win32 *win32SystemObject) GetCursorPos() (int, int) {
 	var x, y C.int
 	C.GlopGetMousePosition(&x, &y)
@@ -103,6 +125,55 @@ func (win32 *win32SystemObject) GetWindowDims() (int, int, int, int) {
 	return int(x), int(y), int(dx), int(dy)
 }
 
+func (win32 *win32SystemObject) SetWindowTitle(title string) {
+	// TODO: Change the title of a window that already exists.
+	win32.title = title
+}
+
+func (win32 *win32SystemObject) SetWindowIcon(icon image.Image) {
+	// TODO: Implement me!
+}
+
+func (win32 *win32SystemObject) SetCursorImage(img image.Image, hot_x, hot_y int) error {
+	// TODO: Implement me!
+	return system.CheckCursorImage(img, hot_x, hot_y)
+}
+
+func (win32 *win32SystemObject) SetStandardCursor(cursor system.StandardCursor) {
+	// TODO: Implement me!
+}
+
+func (win32 *win32SystemObject) GetClipboardText() string {
+	// TODO: Implement me!
+	return ""
+}
+
+func (win32 *win32SystemObject) SetClipboardText(text string) {
+	// TODO: Implement me!
+}
+
+func (win32 *win32SystemObject) GetMonitors() []system.Monitor {
+	// TODO: Implement me!
+	return nil
+}
+
+func (win32 *win32SystemObject) SetWindowMode(mode system.WindowMode, monitor int, display_mode system.DisplayMode) error {
+	if mode == system.Windowed {
+		return nil
+	}
+	// TODO: Implement me!
+	return fmt.Errorf("Can't switch to %v, it isn't implemented yet.", mode)
+}
+
+func (win32 *win32SystemObject) GetWindowMode() system.WindowMode {
+	return system.Windowed
+}
+
+func (win32 *win32SystemObject) GetContentScale() float64 {
+	// TODO: Implement me!
+	return 1
+}
+
 func (win32 *win32SystemObject) EnableVSync(enable bool) {
 	var _enable C.int
 	if enable {
@@ -114,6 +185,10 @@ func (win32 *win32SystemObject) EnableVSync(enable bool) {
 func (win32 *win32SystemObject) HideCursor(hide bool) {
 }
 
+func (win32 *win32SystemObject) SetRelativeMouseMode(enable bool) {
+	// TODO: Implement me!
+}
+
 func (win32 *win32SystemObject) HasFocus() bool {
 	// TODO: Implement me!
 	return true
@@ -0,0 +1,15 @@
+package evdev_test
+
+import (
+	"github.com/orfjackal/gospec/src/gospec"
+	"testing"
+)
+
+func TestAllSpecs(t *testing.T) {
+	r := gospec.NewRunner()
+	r.AddSpec(ParseSpec)
+	r.AddSpec(DeviceTypeSpec)
+	r.AddSpec(TranslatorSpec)
+	r.AddSpec(TranslatedAxisSpec)
+	gospec.MainGoTest(r, t)
+}
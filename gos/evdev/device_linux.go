@@ -0,0 +1,129 @@
+package evdev
+
+import (
+	"bufio"
+	"os"
+	"strings"
+	"syscall"
+	"unsafe"
+)
+
+// ioctl request numbers from linux/input.h
+const (
+	iocRead = 2
+
+	evdevNameNr = 0x06
+	evdevPhysNr = 0x07
+	evdevIdNr   = 0x02
+	evdevPropNr = 0x09
+	evdevBitNr  = 0x20
+	evdevAbsNr  = 0x40
+)
+
+func ioc(dir, nr, size uintptr) uintptr {
+	return dir<<30 | size<<16 | 'E'<<8 | nr
+}
+
+func ioctl(f *os.File, request uintptr, buf unsafe.Pointer) (int, error) {
+	n, _, errno := syscall.Syscall(syscall.SYS_IOCTL, f.Fd(), request, uintptr(buf))
+	if errno != 0 {
+		return 0, errno
+	}
+	return int(n), nil
+}
+
+func ioctlString(f *os.File, nr uintptr) string {
+	buf := make([]byte, 256)
+	n, err := ioctl(f, ioc(iocRead, nr, uintptr(len(buf))), unsafe.Pointer(&buf[0]))
+	if err != nil || n <= 0 {
+		return ""
+	}
+	return strings.TrimRight(string(buf[0:n]), "\x00")
+}
+
+func ioctlBits(f *os.File, ev uintptr, max int) Bits {
+	bits := make(Bits, max/8+1)
+	if _, err := ioctl(f, ioc(iocRead, evdevBitNr+ev, uintptr(len(bits))), unsafe.Pointer(&bits[0])); err != nil {
+		return nil
+	}
+	return bits
+}
+
+func ioctlProps(f *os.File) Bits {
+	bits := make(Bits, PropMax/8+1)
+	if _, err := ioctl(f, ioc(iocRead, evdevPropNr, uintptr(len(bits))), unsafe.Pointer(&bits[0])); err != nil {
+		return nil
+	}
+	return bits
+}
+
+// ReadInfo queries the device open as f for its name, ids and capabilities.
+func ReadInfo(f *os.File) (*Info, error) {
+	var id [4]uint16
+	if _, err := ioctl(f, ioc(iocRead, evdevIdNr, unsafe.Sizeof(id)), unsafe.Pointer(&id[0])); err != nil {
+		return nil, err
+	}
+	info := &Info{
+		Name:    ioctlString(f, evdevNameNr),
+		Phys:    ioctlString(f, evdevPhysNr),
+		Bustype: id[0],
+		Vendor:  id[1],
+		Product: id[2],
+		Version: id[3],
+		Keys:    ioctlBits(f, EvKey, KeyMax),
+		Rel:     ioctlBits(f, EvRel, RelMax),
+		Abs:     ioctlBits(f, EvAbs, AbsMax),
+		Props:   ioctlProps(f),
+		AbsInfo: make(map[uint16]AbsInfo),
+	}
+	for code := 0; code <= AbsMax; code++ {
+		if !info.Abs.Has(code) {
+			continue
+		}
+		var abs AbsInfo
+		if _, err := ioctl(f, ioc(iocRead, evdevAbsNr+uintptr(code), unsafe.Sizeof(abs)), unsafe.Pointer(&abs)); err != nil {
+			continue
+		}
+		info.AbsInfo[uint16(code)] = abs
+	}
+	return info, nil
+}
+
+// A Device is an open evdev device.
+type Device struct {
+	Path string
+	Info *Info
+
+	file   *os.File
+	reader *Reader
+}
+
+// Open opens the evdev device at path, e.g. /dev/input/event3.  Most systems
+// only let users in the input group open keyboards and mice.
+func Open(path string) (*Device, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	info, err := ReadInfo(f)
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	return &Device{
+		Path:   path,
+		Info:   info,
+		file:   f,
+		reader: MakeReader(bufio.NewReaderSize(f, 64*NativeEventSize), NativeEventSize),
+	}, nil
+}
+
+// ReadEvent blocks until the next event is available.  It returns an error
+// once the device has been unplugged or closed.
+func (d *Device) ReadEvent() (Event, error) {
+	return d.reader.ReadEvent()
+}
+
+func (d *Device) Close() error {
+	return d.file.Close()
+}
@@ -0,0 +1,318 @@
+// Package evdev reads input from Linux evdev devices, /dev/input/event*, and
+// translates it into gin.OsEvents.  Every keyboard, mouse and gamepad shows up
+// as its own evdev device, so each one gets its own gin.DeviceId.
+//
+// Parsing and translation are pure Go and work on any io.Reader, so recorded
+// byte streams can be fed through them in tests.  Only Open() requires Linux.
+package evdev
+
+import (
+	"encoding/binary"
+	"fmt"
+	"github.com/runningwild/glop/gin"
+	"io"
+	"unsafe"
+)
+
+// Event types
+const (
+	EvSyn = 0x00
+	EvKey = 0x01
+	EvRel = 0x02
+	EvAbs = 0x03
+	EvMsc = 0x04
+	EvMax = 0x1f
+)
+
+// Synchronization codes
+const (
+	SynReport  = 0
+	SynDropped = 3
+)
+
+// Relative axis codes
+const (
+	RelX      = 0x00
+	RelY      = 0x01
+	RelHWheel = 0x06
+	RelWheel  = 0x08
+	RelMax    = 0x0f
+)
+
+// Absolute axis codes
+const (
+	AbsX        = 0x00
+	AbsY        = 0x01
+	AbsZ        = 0x02
+	AbsRX       = 0x03
+	AbsRY       = 0x04
+	AbsRZ       = 0x05
+	AbsThrottle = 0x06
+	AbsRudder   = 0x07
+	AbsWheel    = 0x08
+	AbsGas      = 0x09
+	AbsBrake    = 0x0a
+	AbsHat0X    = 0x10
+	AbsHat0Y    = 0x11
+	AbsHat3Y    = 0x17
+
+	// Multi-touch, see Documentation/input/multi-touch-protocol.rst
+	AbsMTSlot       = 0x2f
+	AbsMTPositionX  = 0x35
+	AbsMTPositionY  = 0x36
+	AbsMTTrackingId = 0x39
+	AbsMTPressure   = 0x3a
+
+	AbsMax = 0x3f
+)
+
+// Device properties
+const (
+	PropPointer = 0x00
+	PropDirect  = 0x01
+	PropMax     = 0x1f
+)
+
+// Button codes, keyboard key codes are all below BtnMisc.
+const (
+	BtnMisc      = 0x100
+	BtnLeft      = 0x110
+	BtnRight     = 0x111
+	BtnMiddle    = 0x112
+	BtnJoystick  = 0x120
+	BtnGamepad   = 0x130
+	BtnTouch     = 0x14a
+	BtnDpadUp    = 0x220
+	BtnDpadDown  = 0x221
+	BtnDpadLeft  = 0x222
+	BtnDpadRight = 0x223
+	KeyMax       = 0x2ff
+)
+
+// Values of EvKey events
+const (
+	KeyReleased = 0
+	KeyPressed  = 1
+	KeyRepeated = 2
+)
+
+// An Event is a single struct input_event.
+type Event struct {
+	// Time of the event, in seconds and microseconds since the epoch.
+	Sec, Usec int64
+
+	Type  uint16
+	Code  uint16
+	Value int32
+}
+
+// Ms returns the time of the event in milliseconds since the epoch.
+func (e Event) Ms() int64 {
+	return e.Sec*1000 + e.Usec/1000
+}
+
+func (e Event) String() string {
+	return fmt.Sprintf("{%d.%06d type: %d code: %d value: %d}", e.Sec, e.Usec, e.Type, e.Code, e.Value)
+}
+
+// The size of struct input_event depends on the size of a long, which is the
+// size of each of the two fields in a struct timeval.
+const (
+	EventSize32 = 16
+	EventSize64 = 24
+)
+
+// NativeEventSize is the size of struct input_event on this machine.
+const NativeEventSize = int(8 + 2*unsafe.Sizeof(uintptr(0)))
+
+// ParseEvent parses a single event of the specified size from b, which must
+// be at least size bytes long.  Events are little-endian.
+func ParseEvent(b []byte, size int) (Event, error) {
+	var e Event
+	if (size == EventSize32 || size == EventSize64) && len(b) < size {
+		return e, fmt.Errorf("Expected %d bytes, got %d.", size, len(b))
+	}
+	switch size {
+	case EventSize32:
+		e.Sec = int64(int32(binary.LittleEndian.Uint32(b[0:4])))
+		e.Usec = int64(int32(binary.LittleEndian.Uint32(b[4:8])))
+	case EventSize64:
+		e.Sec = int64(binary.LittleEndian.Uint64(b[0:8]))
+		e.Usec = int64(binary.LittleEndian.Uint64(b[8:16]))
+	default:
+		return e, fmt.Errorf("Events must be %d or %d bytes, not %d.", EventSize32, EventSize64, size)
+	}
+	rest := b[size-8 : size]
+	e.Type = binary.LittleEndian.Uint16(rest[0:2])
+	e.Code = binary.LittleEndian.Uint16(rest[2:4])
+	e.Value = int32(binary.LittleEndian.Uint32(rest[4:8]))
+	return e, nil
+}
+
+// AppendEvent appends the encoding of e to b, this is the inverse of
+// ParseEvent().
+func AppendEvent(b []byte, e Event, size int) []byte {
+	var buf [EventSize64]byte
+	switch size {
+	case EventSize32:
+		binary.LittleEndian.PutUint32(buf[0:4], uint32(e.Sec))
+		binary.LittleEndian.PutUint32(buf[4:8], uint32(e.Usec))
+	case EventSize64:
+		binary.LittleEndian.PutUint64(buf[0:8], uint64(e.Sec))
+		binary.LittleEndian.PutUint64(buf[8:16], uint64(e.Usec))
+	default:
+		panic(fmt.Sprintf("Events must be %d or %d bytes, not %d.", EventSize32, EventSize64, size))
+	}
+	rest := buf[size-8 : size]
+	binary.LittleEndian.PutUint16(rest[0:2], e.Type)
+	binary.LittleEndian.PutUint16(rest[2:4], e.Code)
+	binary.LittleEndian.PutUint32(rest[4:8], uint32(e.Value))
+	return append(b, buf[0:size]...)
+}
+
+// A Reader reads a stream of events.
+type Reader struct {
+	r    io.Reader
+	size int
+	buf  []byte
+}
+
+// MakeReader returns a Reader that reads events of the specified size from r.
+// Use NativeEventSize when reading from a device.
+func MakeReader(r io.Reader, size int) *Reader {
+	if size != EventSize32 && size != EventSize64 {
+		panic(fmt.Sprintf("Events must be %d or %d bytes, not %d.", EventSize32, EventSize64, size))
+	}
+	return &Reader{r: r, size: size, buf: make([]byte, size)}
+}
+
+// ReadEvent reads the next event.  It returns io.EOF if the stream ends
+// cleanly between events and io.ErrUnexpectedEOF if it ends partway through
+// one.
+func (r *Reader) ReadEvent() (Event, error) {
+	if _, err := io.ReadFull(r.r, r.buf); err != nil {
+		return Event{}, err
+	}
+	return ParseEvent(r.buf, r.size)
+}
+
+// Bits is a capability bitmask, as returned by the EVIOCGBIT ioctl.
+type Bits []byte
+
+// Has returns true iff bit code is set.
+func (b Bits) Has(code int) bool {
+	return code/8 < len(b) && b[code/8]&(1<<uint(code%8)) != 0
+}
+
+// Set sets bit code, growing b if necessary.
+func (b *Bits) Set(code int) {
+	for len(*b) <= code/8 {
+		*b = append(*b, 0)
+	}
+	(*b)[code/8] |= 1 << uint(code%8)
+}
+
+// AbsInfo is a struct input_absinfo, describing the range of an absolute axis.
+type AbsInfo struct {
+	Value, Min, Max, Fuzz, Flat, Resolution int32
+}
+
+// Info describes an evdev device.
+type Info struct {
+	Name string
+
+	// Physical location of the device, e.g. "usb-0000:00:14.0-2/input0".  This
+	// stays the same if a device is unplugged and plugged back into the same
+	// port.
+	Phys string
+
+	Bustype, Vendor, Product, Version uint16
+
+	// Capabilities of the device.
+	Keys, Rel, Abs Bits
+
+	// Properties of the device, PropDirect is set for touchscreens.
+	Props Bits
+
+	// Ranges of each of the absolute axes in Abs.
+	AbsInfo map[uint16]AbsInfo
+}
+
+// DeviceType returns the type of gin device that this is, or DeviceTypeAny if
+// it is not a keyboard, mouse, gamepad or touchscreen.
+func (info *Info) DeviceType() gin.DeviceType {
+	has_buttons := false
+	for code := BtnJoystick; code < BtnJoystick+0x20; code++ {
+		if info.Keys.Has(code) {
+			has_buttons = true
+		}
+	}
+	switch {
+	case info.Keys.Has(BtnTouch) && info.Props.Has(PropDirect) && info.Abs.Has(AbsMTPositionX):
+		return gin.DeviceTypeTouch
+	case info.Keys.Has(BtnTouch):
+		// Touchpads have absolute axes but aren't gamepads, and X already turns
+		// them into a mouse.
+		return gin.DeviceTypeAny
+	case has_buttons && info.Abs.Has(AbsX):
+		return gin.DeviceTypeController
+	case info.Rel.Has(RelX) && info.Rel.Has(RelY) && info.Keys.Has(BtnLeft):
+		return gin.DeviceTypeMouse
+	case info.Keys.Has(keyA) && info.Keys.Has(keyZ) && info.Keys.Has(keySpace):
+		return gin.DeviceTypeKeyboard
+	}
+	return gin.DeviceTypeAny
+}
+
+// Buttons returns the codes of the buttons that a controller has, in the order
+// that they are numbered as ControllerButton0+N.  This is the same numbering
+// used by the joystick API and SDL: joystick and gamepad buttons first, then
+// everything above them, then the buttons from BtnMisc up to BtnJoystick.
+func (info *Info) Buttons() []uint16 {
+	var buttons []uint16
+	for code := BtnJoystick; code <= KeyMax; code++ {
+		if info.Keys.Has(code) {
+			buttons = append(buttons, uint16(code))
+		}
+	}
+	for code := BtnMisc; code < BtnJoystick; code++ {
+		if info.Keys.Has(code) {
+			buttons = append(buttons, uint16(code))
+		}
+	}
+	return buttons
+}
+
+// Axes returns the codes of the absolute axes that a controller has, in the
+// order that they are numbered as ControllerAxis0Positive+N and
+// ControllerAxis0Negative+N.  Like the joystick API and SDL this skips the
+// hats, which are reported separately.
+func (info *Info) Axes() []uint16 {
+	var axes []uint16
+	for code := 0; code <= AbsMax; code++ {
+		if code >= AbsHat0X && code <= AbsHat3Y {
+			continue
+		}
+		if info.Abs.Has(code) {
+			axes = append(axes, uint16(code))
+		}
+	}
+	return axes
+}
+
+// GUID returns the device's GUID in the format used by SDL and by
+// gamecontrollerdb.txt, which is what gin.GamepadDatabase expects.
+func (info *Info) GUID() string {
+	var b [16]byte
+	binary.LittleEndian.PutUint16(b[0:2], info.Bustype)
+	binary.LittleEndian.PutUint16(b[4:6], info.Vendor)
+	binary.LittleEndian.PutUint16(b[8:10], info.Product)
+	binary.LittleEndian.PutUint16(b[12:14], info.Version)
+	return fmt.Sprintf("%x", b[:])
+}
+
+// Identity returns a string that identifies this device, and is the same if
+// the device is unplugged and plugged back in to the same port.
+func (info *Info) Identity() string {
+	return fmt.Sprintf("%s/%04x:%04x/%s", info.Phys, info.Vendor, info.Product, info.Name)
+}
@@ -0,0 +1,358 @@
+package evdev_test
+
+import (
+	"bytes"
+	"github.com/orfjackal/gospec/src/gospec"
+	. "github.com/orfjackal/gospec/src/gospec"
+	"github.com/runningwild/glop/gin"
+	"github.com/runningwild/glop/gos/evdev"
+	"io"
+)
+
+// Recorded from a USB keyboard on x86-64: the 'A' key being pressed.
+var keyboard_press_a = []byte{
+	0x00, 0x00, 0x00, 0x5f, 0x00, 0x00, 0x00, 0x00, 0x40, 0xe2, 0x01, 0x00, 0x00, 0x00, 0x00, 0x00,
+	0x04, 0x00, 0x04, 0x00, 0x04, 0x00, 0x07, 0x00, // EV_MSC MSC_SCAN 0x70004
+	0x00, 0x00, 0x00, 0x5f, 0x00, 0x00, 0x00, 0x00, 0x40, 0xe2, 0x01, 0x00, 0x00, 0x00, 0x00, 0x00,
+	0x01, 0x00, 0x1e, 0x00, 0x01, 0x00, 0x00, 0x00, // EV_KEY KEY_A 1
+	0x00, 0x00, 0x00, 0x5f, 0x00, 0x00, 0x00, 0x00, 0x40, 0xe2, 0x01, 0x00, 0x00, 0x00, 0x00, 0x00,
+	0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, // EV_SYN SYN_REPORT
+}
+
+func readAll(r *evdev.Reader) ([]evdev.Event, error) {
+	var events []evdev.Event
+	for {
+		e, err := r.ReadEvent()
+		if err != nil {
+			return events, err
+		}
+		events = append(events, e)
+	}
+}
+
+func ParseSpec(c gospec.Context) {
+	c.Specify("Recorded 64-bit events are parsed.", func() {
+		events, err := readAll(evdev.MakeReader(bytes.NewReader(keyboard_press_a), evdev.EventSize64))
+		c.Expect(err, Equals, io.EOF)
+		c.Expect(len(events), Equals, 3)
+		c.Expect(events[1], Equals, evdev.Event{Sec: 0x5f000000, Usec: 123456, Type: evdev.EvKey, Code: 30, Value: 1})
+		c.Expect(events[1].Ms(), Equals, int64(0x5f000000)*1000+123)
+		c.Expect(events[0].Value, Equals, int32(0x70004))
+	})
+
+	c.Specify("Events survive a round trip in both sizes.", func() {
+		e := evdev.Event{Sec: 12, Usec: 345678, Type: evdev.EvAbs, Code: evdev.AbsY, Value: -32768}
+		for _, size := range []int{evdev.EventSize32, evdev.EventSize64} {
+			b := evdev.AppendEvent(nil, e, size)
+			c.Expect(len(b), Equals, size)
+			parsed, err := evdev.ParseEvent(b, size)
+			c.Expect(err, IsNil)
+			c.Expect(parsed, Equals, e)
+		}
+	})
+
+	c.Specify("Truncated streams are reported.", func() {
+		_, err := readAll(evdev.MakeReader(bytes.NewReader(keyboard_press_a[0:30]), evdev.EventSize64))
+		c.Expect(err, Equals, io.ErrUnexpectedEOF)
+		_, err = evdev.ParseEvent(keyboard_press_a[0:10], evdev.EventSize64)
+		c.Expect(err, Not(IsNil))
+	})
+}
+
+func gamepadInfo() *evdev.Info {
+	info := &evdev.Info{
+		Name:    "Generic Gamepad",
+		Vendor:  0x045e,
+		Product: 0x028e,
+		AbsInfo: map[uint16]evdev.AbsInfo{
+			evdev.AbsX:     {Min: -32768, Max: 32767},
+			evdev.AbsZ:     {Min: 0, Max: 255},
+			evdev.AbsHat0X: {Min: -1, Max: 1},
+			evdev.AbsHat0Y: {Min: -1, Max: 1},
+		},
+	}
+	info.Keys.Set(evdev.BtnGamepad)
+	info.Keys.Set(evdev.BtnDpadRight)
+	info.Keys.Set(evdev.BtnJoystick - 1)
+	for code := range info.AbsInfo {
+		info.Abs.Set(int(code))
+	}
+	return info
+}
+
+func DeviceTypeSpec(c gospec.Context) {
+	c.Specify("Devices are classified by their capabilities.", func() {
+		c.Expect(gamepadInfo().DeviceType(), Equals, gin.DeviceTypeController)
+
+		var mouse evdev.Info
+		mouse.Rel.Set(evdev.RelX)
+		mouse.Rel.Set(evdev.RelY)
+		mouse.Keys.Set(evdev.BtnLeft)
+		c.Expect(mouse.DeviceType(), Equals, gin.DeviceTypeMouse)
+
+		var keyboard evdev.Info
+		for code := 1; code < 128; code++ {
+			keyboard.Keys.Set(code)
+		}
+		c.Expect(keyboard.DeviceType(), Equals, gin.DeviceTypeKeyboard)
+
+		touchpad := gamepadInfo()
+		touchpad.Keys.Set(evdev.BtnTouch)
+		c.Expect(touchpad.DeviceType(), Equals, gin.DeviceTypeAny)
+
+		c.Expect(touchscreenInfo().DeviceType(), Equals, gin.DeviceTypeTouch)
+	})
+}
+
+func touchscreenInfo() *evdev.Info {
+	info := &evdev.Info{
+		Name: "Generic Touchscreen",
+		AbsInfo: map[uint16]evdev.AbsInfo{
+			evdev.AbsX:            {Min: 0, Max: 4095},
+			evdev.AbsY:            {Min: 0, Max: 4095},
+			evdev.AbsMTSlot:       {Min: 0, Max: 9},
+			evdev.AbsMTPositionX:  {Min: 0, Max: 4095},
+			evdev.AbsMTPositionY:  {Min: 0, Max: 4095},
+			evdev.AbsMTTrackingId: {Min: 0, Max: 65535},
+			evdev.AbsMTPressure:   {Min: 0, Max: 255},
+		},
+	}
+	info.Keys.Set(evdev.BtnTouch)
+	info.Props.Set(evdev.PropDirect)
+	for code := range info.AbsInfo {
+		info.Abs.Set(int(code))
+	}
+	return info
+}
+
+// translateAll feeds events through t and returns everything it produces.
+func translateAll(t *evdev.Translator, events ...evdev.Event) []gin.OsEvent {
+	var ret []gin.OsEvent
+	for _, e := range events {
+		ret = append(ret, t.Translate(e)...)
+	}
+	return ret
+}
+
+func syn() evdev.Event {
+	return evdev.Event{Type: evdev.EvSyn, Code: evdev.SynReport}
+}
+
+func TranslatorSpec(c gospec.Context) {
+	pad := gin.DeviceId{Type: gin.DeviceTypeController, Index: 3}
+	c.Specify("Keyboard events come from the keyboard's own device.", func() {
+		kb := gin.DeviceId{Type: gin.DeviceTypeKeyboard, Index: 2}
+		var info evdev.Info
+		t := evdev.MakeTranslator(&info, kb)
+		events, _ := readAll(evdev.MakeReader(bytes.NewReader(keyboard_press_a), evdev.EventSize64))
+		os_events := translateAll(t, events...)
+		c.Expect(len(os_events), Equals, 1)
+		c.Expect(os_events[0].KeyId, Equals, gin.KeyId{Index: gin.KeyA, Device: kb})
+		c.Expect(os_events[0].Press_amt, Equals, 1.0)
+		c.Expect(os_events[0].Timestamp, Equals, int64(0x5f000000)*1000+123)
+	})
+
+	c.Specify("Events are held until the end of their packet.", func() {
+		t := evdev.MakeTranslator(gamepadInfo(), pad)
+		c.Expect(len(t.Translate(evdev.Event{Type: evdev.EvKey, Code: evdev.BtnGamepad, Value: 1})), Equals, 0)
+		os_events := t.Translate(syn())
+		c.Expect(len(os_events), Equals, 1)
+		c.Expect(os_events[0].KeyId.Index, Equals, gin.KeyIndex(gin.ControllerButton0))
+	})
+
+	c.Specify("Buttons and axes are numbered like the joystick API numbers them.", func() {
+		info := gamepadInfo()
+		c.Expect(info.Buttons(), ContainsExactly, []uint16{evdev.BtnGamepad, evdev.BtnDpadRight, evdev.BtnJoystick - 1})
+		c.Expect(info.Axes(), ContainsExactly, []uint16{evdev.AbsX, evdev.AbsZ})
+
+		t := evdev.MakeTranslator(info, pad)
+		os_events := translateAll(t,
+			evdev.Event{Type: evdev.EvKey, Code: evdev.BtnJoystick - 1, Value: 1},
+			evdev.Event{Type: evdev.EvKey, Code: evdev.BtnDpadRight, Value: 1},
+			syn())
+		c.Expect(len(os_events), Equals, 2)
+		c.Expect(os_events[0].KeyId.Index, Equals, gin.KeyIndex(gin.ControllerButton0+2))
+		c.Expect(os_events[1].KeyId.Index, Equals, gin.KeyIndex(gin.ControllerButton0+1))
+	})
+
+	c.Specify("GUIDs match the format used by SDL.", func() {
+		info := gamepadInfo()
+		info.Bustype = 0x03
+		info.Version = 0x0114
+		c.Expect(info.GUID(), Equals, "030000005e0400008e02000014010000")
+	})
+
+	c.Specify("Autorepeat is ignored.", func() {
+		t := evdev.MakeTranslator(gamepadInfo(), pad)
+		os_events := translateAll(t, evdev.Event{Type: evdev.EvKey, Code: 30, Value: evdev.KeyRepeated}, syn())
+		c.Expect(len(os_events), Equals, 0)
+	})
+
+	c.Specify("Dropped packets are discarded.", func() {
+		t := evdev.MakeTranslator(gamepadInfo(), pad)
+		os_events := translateAll(t,
+			evdev.Event{Type: evdev.EvKey, Code: evdev.BtnGamepad, Value: 1},
+			evdev.Event{Type: evdev.EvSyn, Code: evdev.SynDropped},
+			evdev.Event{Type: evdev.EvKey, Code: evdev.BtnGamepad, Value: 0},
+			syn(),
+			evdev.Event{Type: evdev.EvKey, Code: evdev.BtnGamepad, Value: 1},
+			syn())
+		c.Expect(len(os_events), Equals, 1)
+		c.Expect(os_events[0].Press_amt, Equals, 1.0)
+	})
+
+	c.Specify("Sticks are centered and triggers are not.", func() {
+		t := evdev.MakeTranslator(gamepadInfo(), pad)
+		os_events := translateAll(t, evdev.Event{Type: evdev.EvAbs, Code: evdev.AbsX, Value: -32768}, syn())
+		c.Expect(len(os_events), Equals, 2)
+		c.Expect(os_events[0].KeyId.Index, Equals, gin.KeyIndex(gin.ControllerAxis0Positive))
+		c.Expect(os_events[0].Press_amt, Equals, 0.0)
+		c.Expect(os_events[1].KeyId.Index, Equals, gin.KeyIndex(gin.ControllerAxis0Negative))
+		c.Expect(os_events[1].Press_amt, Equals, 1.0)
+
+		os_events = translateAll(t, evdev.Event{Type: evdev.EvAbs, Code: evdev.AbsZ, Value: 255}, syn())
+		c.Expect(os_events[1].KeyId.Index, Equals, gin.KeyIndex(gin.ControllerAxis0Positive+1))
+		c.Expect(os_events[1].Press_amt, Equals, 1.0)
+		os_events = translateAll(t, evdev.Event{Type: evdev.EvAbs, Code: evdev.AbsZ, Value: 0}, syn())
+		c.Expect(os_events[1].Press_amt, Equals, 0.0)
+	})
+
+	c.Specify("The hat is reported on the hat switch keys.", func() {
+		t := evdev.MakeTranslator(gamepadInfo(), pad)
+		os_events := translateAll(t, evdev.Event{Type: evdev.EvAbs, Code: evdev.AbsHat0Y, Value: -1}, syn())
+		c.Expect(len(os_events), Equals, 1)
+		c.Expect(os_events[0].KeyId.Index, Equals, gin.KeyIndex(gin.ControllerHatSwitchUp))
+
+		os_events = translateAll(t, evdev.Event{Type: evdev.EvAbs, Code: evdev.AbsHat0X, Value: 1}, syn())
+		c.Expect(len(os_events), Equals, 2)
+		c.Expect(os_events[0].KeyId.Index, Equals, gin.KeyIndex(gin.ControllerHatSwitchUp))
+		c.Expect(os_events[0].Press_amt, Equals, 0.0)
+		c.Expect(os_events[1].KeyId.Index, Equals, gin.KeyIndex(gin.ControllerHatSwitchUpRight))
+
+		// Releasing both halves of a diagonal in one packet must not pass
+		// through Up or Right on the way.
+		os_events = translateAll(t,
+			evdev.Event{Type: evdev.EvAbs, Code: evdev.AbsHat0X, Value: 0},
+			evdev.Event{Type: evdev.EvAbs, Code: evdev.AbsHat0Y, Value: 0},
+			syn())
+		c.Expect(len(os_events), Equals, 1)
+		c.Expect(os_events[0].KeyId.Index, Equals, gin.KeyIndex(gin.ControllerHatSwitchUpRight))
+		c.Expect(os_events[0].Press_amt, Equals, 0.0)
+	})
+
+	c.Specify("Mice report relative motion and buttons.", func() {
+		mouse := gin.DeviceId{Type: gin.DeviceTypeMouse, Index: 1}
+		t := evdev.MakeTranslator(&evdev.Info{}, mouse)
+		os_events := translateAll(t,
+			evdev.Event{Type: evdev.EvRel, Code: evdev.RelX, Value: -3},
+			evdev.Event{Type: evdev.EvRel, Code: evdev.RelY, Value: 4},
+			evdev.Event{Type: evdev.EvKey, Code: evdev.BtnLeft, Value: 1},
+			syn())
+		c.Expect(len(os_events), Equals, 3)
+		c.Expect(os_events[0].KeyId, Equals, gin.KeyId{Index: gin.MouseXAxis, Device: mouse})
+		c.Expect(os_events[0].Press_amt, Equals, -3.0)
+		c.Expect(os_events[2].KeyId.Index, Equals, gin.KeyIndex(gin.MouseLButton))
+	})
+
+	c.Specify("Touchscreens report each slot as a contact.", func() {
+		screen := gin.DeviceId{Type: gin.DeviceTypeTouch, Index: 1}
+		t := evdev.MakeTranslator(touchscreenInfo(), screen)
+		abs := func(code uint16, value int32) evdev.Event {
+			return evdev.Event{Type: evdev.EvAbs, Code: code, Value: value}
+		}
+		os_events := translateAll(t,
+			abs(evdev.AbsMTSlot, 0),
+			abs(evdev.AbsMTTrackingId, 45),
+			abs(evdev.AbsMTPositionX, 0),
+			abs(evdev.AbsMTPositionY, 4095),
+			abs(evdev.AbsMTPressure, 255),
+			abs(evdev.AbsMTSlot, 1),
+			abs(evdev.AbsMTTrackingId, 46),
+			abs(evdev.AbsMTPositionX, 4095),
+			abs(evdev.AbsMTPositionY, 0),
+			abs(evdev.AbsMTPressure, 0),
+			evdev.Event{Type: evdev.EvKey, Code: evdev.BtnTouch, Value: 1},
+			abs(evdev.AbsX, 0),
+			syn())
+		c.Assume(len(os_events), Equals, 2)
+		c.Expect(os_events[0].KeyId, Equals, gin.KeyId{Index: gin.TouchContact0, Device: screen})
+		c.Expect(os_events[0].Press_amt, Equals, 1.0)
+		c.Expect(os_events[0].X, Equals, 0)
+		c.Expect(os_events[0].Y, Equals, evdev.TouchScale)
+		c.Expect(os_events[1].KeyId.Index, Equals, gin.KeyIndex(gin.TouchContact0+1))
+		c.Expect(os_events[1].Press_amt > 0, Equals, true)
+		c.Expect(os_events[1].X, Equals, evdev.TouchScale)
+
+		// Only slots that change are reported.
+		os_events = translateAll(t,
+			abs(evdev.AbsMTPositionX, 2048),
+			syn())
+		c.Assume(len(os_events), Equals, 1)
+		c.Expect(os_events[0].KeyId.Index, Equals, gin.KeyIndex(gin.TouchContact0+1))
+		c.Expect(os_events[0].X, Equals, evdev.TouchScale*2048/4095)
+
+		os_events = translateAll(t,
+			abs(evdev.AbsMTSlot, 0),
+			abs(evdev.AbsMTTrackingId, -1),
+			syn())
+		c.Assume(len(os_events), Equals, 1)
+		c.Expect(os_events[0].KeyId.Index, Equals, gin.KeyIndex(gin.TouchContact0))
+		c.Expect(os_events[0].Press_amt, Equals, 0.0)
+	})
+}
+
+func TranslatedAxisSpec(c gospec.Context) {
+	pad := gin.DeviceId{Type: gin.DeviceTypeController, Index: 1}
+	info := gamepadInfo()
+	info.AbsInfo[evdev.AbsY] = evdev.AbsInfo{Min: -32768, Max: 32767}
+	info.Abs.Set(evdev.AbsY)
+	input := gin.Make()
+	t := evdev.MakeTranslator(info, pad)
+	x_pos := input.GetKeyFlat(gin.ControllerAxis0Positive, gin.DeviceTypeController, 1)
+	x_neg := input.GetKeyFlat(gin.ControllerAxis0Negative, gin.DeviceTypeController, 1)
+	y_pos := input.GetKeyFlat(gin.ControllerAxis0Positive+1, gin.DeviceTypeController, 1)
+	now := int64(0)
+	move := func(events ...evdev.Event) {
+		now += 10
+		input.Think(now, true, translateAll(t, append(events, syn())...))
+	}
+	x := func(value int32) evdev.Event {
+		return evdev.Event{Type: evdev.EvAbs, Code: evdev.AbsX, Value: value}
+	}
+	y := func(value int32) evdev.Event {
+		return evdev.Event{Type: evdev.EvAbs, Code: evdev.AbsY, Value: value}
+	}
+	half := evdev.NormalizeAbs(evdev.AbsX, 16384, info.AbsInfo[evdev.AbsX])
+	more := evdev.NormalizeAbs(evdev.AbsX, 19661, info.AbsInfo[evdev.AbsX])
+
+	c.Specify("Configured axes don't pass through the center while they move.", func() {
+		input.SetAxisConfig(pad, 0, gin.AxisConfig{})
+		move(x(16384))
+		c.Expect(x_pos.CurPressAmt(), Equals, half)
+		move(x(19661))
+		c.Expect(x_pos.CurPressAmt(), Equals, more)
+		c.Expect(x_pos.FrameReleaseCount(), Equals, 0)
+		c.Expect(x_neg.IsDown(), Equals, false)
+
+		move(x(-16384))
+		c.Expect(x_pos.IsDown(), Equals, false)
+		c.Expect(x_pos.FrameReleaseCount(), Equals, 1)
+		c.Expect(x_neg.IsDown(), Equals, true)
+	})
+
+	c.Specify("Sticks don't pass through the center while they move.", func() {
+		input.SetStickConfig(pad, 0, 1, gin.StickConfig{})
+		move(x(16384), y(16384))
+		c.Expect(x_pos.CurPressAmt(), IsWithin(1e-9), half)
+		c.Expect(y_pos.CurPressAmt(), IsWithin(1e-9), half)
+		move(x(19661))
+		c.Expect(x_pos.CurPressAmt(), IsWithin(1e-9), more)
+		c.Expect(x_pos.FrameReleaseCount(), Equals, 0)
+		c.Expect(y_pos.CurPressAmt(), IsWithin(1e-9), half)
+		c.Expect(y_pos.FrameReleaseCount(), Equals, 0)
+		move(y(19661))
+		c.Expect(x_pos.FrameReleaseCount(), Equals, 0)
+		c.Expect(y_pos.FrameReleaseCount(), Equals, 0)
+		c.Expect(y_pos.CurPressAmt(), IsWithin(1e-9), more)
+	})
+}
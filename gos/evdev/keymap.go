@@ -0,0 +1,140 @@
+package evdev
+
+import (
+	"github.com/runningwild/glop/gin"
+)
+
+// Keyboard key codes from linux/input-event-codes.h that are used elsewhere
+// in this package.
+const (
+	keyA     = 30
+	keyZ     = 44
+	keySpace = 57
+)
+
+// key_map maps evdev keyboard key codes to gin key indexes.  Key codes are
+// positions on a US layout keyboard, not the symbols printed on the keys.
+var key_map = map[uint16]gin.KeyIndex{
+	1:   gin.Escape,
+	2:   gin.Key1,
+	3:   gin.Key2,
+	4:   gin.Key3,
+	5:   gin.Key4,
+	6:   gin.Key5,
+	7:   gin.Key6,
+	8:   gin.Key7,
+	9:   gin.Key8,
+	10:  gin.Key9,
+	11:  gin.Key0,
+	12:  '-',
+	13:  '=',
+	14:  gin.Backspace,
+	15:  gin.Tab,
+	16:  gin.KeyQ,
+	17:  gin.KeyW,
+	18:  gin.KeyE,
+	19:  gin.KeyR,
+	20:  gin.KeyT,
+	21:  gin.KeyY,
+	22:  gin.KeyU,
+	23:  gin.KeyI,
+	24:  gin.KeyO,
+	25:  gin.KeyP,
+	26:  '[',
+	27:  ']',
+	28:  gin.Return,
+	29:  gin.LeftControl,
+	30:  gin.KeyA,
+	31:  gin.KeyS,
+	32:  gin.KeyD,
+	33:  gin.KeyF,
+	34:  gin.KeyG,
+	35:  gin.KeyH,
+	36:  gin.KeyJ,
+	37:  gin.KeyK,
+	38:  gin.KeyL,
+	39:  ';',
+	40:  '\'',
+	41:  '`',
+	42:  gin.LeftShift,
+	43:  '\\',
+	44:  gin.KeyZ,
+	45:  gin.KeyX,
+	46:  gin.KeyC,
+	47:  gin.KeyV,
+	48:  gin.KeyB,
+	49:  gin.KeyN,
+	50:  gin.KeyM,
+	51:  ',',
+	52:  '.',
+	53:  '/',
+	54:  gin.RightShift,
+	55:  gin.KeyPadMultiply,
+	56:  gin.LeftAlt,
+	57:  gin.Space,
+	58:  gin.CapsLock,
+	59:  gin.F1,
+	60:  gin.F2,
+	61:  gin.F3,
+	62:  gin.F4,
+	63:  gin.F5,
+	64:  gin.F6,
+	65:  gin.F7,
+	66:  gin.F8,
+	67:  gin.F9,
+	68:  gin.F10,
+	69:  gin.NumLock,
+	70:  gin.ScrollLock,
+	71:  gin.KeyPad7,
+	72:  gin.KeyPad8,
+	73:  gin.KeyPad9,
+	74:  gin.KeyPadSubtract,
+	75:  gin.KeyPad4,
+	76:  gin.KeyPad5,
+	77:  gin.KeyPad6,
+	78:  gin.KeyPadAdd,
+	79:  gin.KeyPad1,
+	80:  gin.KeyPad2,
+	81:  gin.KeyPad3,
+	82:  gin.KeyPad0,
+	83:  gin.KeyPadDecimal,
+	87:  gin.F11,
+	88:  gin.F12,
+	96:  gin.KeyPadEnter,
+	97:  gin.RightControl,
+	98:  gin.KeyPadDivide,
+	99:  gin.PrintScreen,
+	100: gin.RightAlt,
+	102: gin.KeyHome,
+	103: gin.Up,
+	104: gin.KeyPageUp,
+	105: gin.Left,
+	106: gin.Right,
+	107: gin.KeyEnd,
+	108: gin.Down,
+	109: gin.KeyPageDown,
+	110: gin.KeyInsert,
+	111: gin.KeyDelete,
+	117: gin.KeyPadEquals,
+	119: gin.Pause,
+	125: gin.LeftGui,
+	126: gin.RightGui,
+}
+
+// KeyIndex returns the gin key index for an evdev keyboard key or mouse button
+// code, and false if there isn't one.  Controller buttons depend on which
+// buttons the controller has, see Info.Buttons().
+func KeyIndex(code uint16) (gin.KeyIndex, bool) {
+	switch {
+	case code < BtnMisc:
+		index, ok := key_map[code]
+		return index, ok
+	case code == BtnLeft:
+		return gin.MouseLButton, true
+	case code == BtnRight:
+		return gin.MouseRButton, true
+	case code == BtnMiddle:
+		return gin.MouseMButton, true
+	}
+	return 0, false
+}
@@ -0,0 +1,356 @@
+package evdev
+
+import (
+	"github.com/runningwild/glop/gin"
+)
+
+// A Translator turns the events from a single device into gin.OsEvents.
+// Events are held until the SYN_REPORT that ends their packet, and packets
+// that the kernel reports as dropped are discarded, so a Translator never
+// returns half of a packet.
+//
+// Controller buttons and axes are numbered the same way as the joystick API
+// and SDL number them, see Info.Buttons() and Info.Axes(), so that SDL
+// gamepad mappings apply to them.  Axes that can go negative, and sticks, are
+// centered, so their values are in [-1, 1].  Triggers that only go from 0 up
+// are in [0, 1].  The first hat is reported on the hat switch keys.
+//
+// Each slot of a multi-touch device is reported on the gin.TouchContact0+N
+// key, with the contact's pressure as its press amount.  The X and Y of touch
+// events are the position on the device, scaled to [0, TouchScale] with the
+// origin at the top-left, they must be converted to window coordinates before
+// the events are passed to gin.
+type Translator struct {
+	info   *Info
+	device gin.DeviceId
+
+	// Controller button and axis numbers, indexed by evdev code.
+	buttons map[uint16]gin.KeyIndex
+	axes    map[uint16]gin.KeyIndex
+
+	// Events for the current packet.
+	pending []gin.OsEvent
+
+	// Set after a SYN_DROPPED until the next SYN_REPORT.
+	dropped bool
+
+	// Current position of the hat, each of x and y are -1, 0 or 1.
+	hat_x, hat_y   int
+	hat            gin.KeyIndex
+	hat_is_pressed bool
+
+	// Set when the hat changes, the hat key is only updated at the end of a
+	// packet so that a diagonal move doesn't pass through a neighbouring
+	// direction.
+	hat_changed bool
+	hat_event   Event
+
+	// Multi-touch slots and the slot that events currently apply to, only used
+	// by touch devices.
+	slots []touchSlot
+	slot  int
+}
+
+type touchSlot struct {
+	// Tracking id of the contact in this slot, or -1 if there is none.
+	tracking int32
+
+	x, y, pressure int32
+
+	// Set when any of the above change, until the end of the packet.
+	changed bool
+
+	// Whether or not the slot's key is down.
+	down bool
+}
+
+// gin only has keys for this many controller axes.
+const maxAxes = 10
+
+// Touch positions are scaled to [0, TouchScale].
+const TouchScale = 1 << 16
+
+func MakeTranslator(info *Info, device gin.DeviceId) *Translator {
+	t := &Translator{
+		info:    info,
+		device:  device,
+		buttons: make(map[uint16]gin.KeyIndex),
+		axes:    make(map[uint16]gin.KeyIndex),
+	}
+	if device.Type == gin.DeviceTypeController {
+		for n, code := range info.Buttons() {
+			t.buttons[code] = gin.KeyIndex(n)
+		}
+		for n, code := range info.Axes() {
+			if n < maxAxes {
+				t.axes[code] = gin.KeyIndex(n)
+			}
+		}
+	}
+	if device.Type == gin.DeviceTypeTouch {
+		num_slots := 1
+		if info.Abs.Has(AbsMTSlot) {
+			num_slots = int(info.AbsInfo[AbsMTSlot].Max) + 1
+		}
+		if num_slots > gin.MaxTouchContacts {
+			num_slots = gin.MaxTouchContacts
+		}
+		t.slots = make([]touchSlot, num_slots)
+		for i := range t.slots {
+			t.slots[i].tracking = -1
+		}
+	}
+	return t
+}
+
+func (t *Translator) Device() gin.DeviceId {
+	return t.device
+}
+
+// Translate processes a single event.  When the event completes a packet the
+// gin.OsEvents for the whole packet are returned, otherwise nil is returned.
+func (t *Translator) Translate(e Event) []gin.OsEvent {
+	if e.Type == EvSyn {
+		switch e.Code {
+		case SynReport:
+			if t.dropped {
+				t.dropped = false
+				t.pending = nil
+				return nil
+			}
+			if t.hat_changed {
+				t.hat_changed = false
+				t.updateHat(t.hat_event)
+			}
+			t.updateTouches(e)
+			events := t.pending
+			t.pending = nil
+			return events
+		case SynDropped:
+			t.dropped = true
+			t.pending = nil
+		}
+		return nil
+	}
+	if t.dropped {
+		return nil
+	}
+	switch e.Type {
+	case EvKey:
+		t.translateKey(e)
+	case EvRel:
+		t.translateRel(e)
+	case EvAbs:
+		t.translateAbs(e)
+	}
+	return nil
+}
+
+func (t *Translator) add(index gin.KeyIndex, amt float64, e Event) {
+	t.pending = append(t.pending, gin.OsEvent{
+		KeyId:     gin.KeyId{Index: index, Device: t.device},
+		Press_amt: amt,
+		Timestamp: e.Ms(),
+	})
+}
+
+func (t *Translator) translateKey(e Event) {
+	if e.Value == KeyRepeated {
+		return
+	}
+	index, ok := KeyIndex(e.Code)
+	if button, is_button := t.buttons[e.Code]; is_button {
+		index, ok = gin.ControllerButton0+button, true
+	}
+	if !ok {
+		return
+	}
+	amt := 0.0
+	if e.Value == KeyPressed {
+		amt = 1
+	}
+	t.add(index, amt, e)
+}
+
+func (t *Translator) translateRel(e Event) {
+	switch e.Code {
+	case RelX:
+		t.add(gin.MouseXAxis, float64(e.Value), e)
+	case RelY:
+		t.add(gin.MouseYAxis, float64(e.Value), e)
+	case RelWheel:
+		t.add(gin.MouseWheelVertical, float64(e.Value), e)
+	case RelHWheel:
+		t.add(gin.MouseWheelHorizontal, float64(e.Value), e)
+	}
+}
+
+// isTrigger returns true iff code is an axis that rests at its minimum rather
+// than its center.
+func isTrigger(code uint16) bool {
+	switch code {
+	case AbsZ, AbsRZ, AbsThrottle, AbsGas, AbsBrake:
+		return true
+	}
+	return false
+}
+
+// NormalizeAbs returns the value of an absolute axis in [-1, 1], or [0, 1] if
+// it is a trigger.
+func NormalizeAbs(code uint16, value int32, info AbsInfo) float64 {
+	if info.Max <= info.Min {
+		return 0
+	}
+	v := float64(value-info.Min) / float64(info.Max-info.Min)
+	if info.Min >= 0 && isTrigger(code) {
+		return clamp(v, 0, 1)
+	}
+	return clamp(2*v-1, -1, 1)
+}
+
+func clamp(v, min, max float64) float64 {
+	if v < min {
+		return min
+	}
+	if v > max {
+		return max
+	}
+	return v
+}
+
+func (t *Translator) translateAbs(e Event) {
+	switch {
+	case t.slots != nil:
+		t.translateTouch(e)
+
+	case e.Code == AbsHat0X || e.Code == AbsHat0Y:
+		v := 0
+		if e.Value < 0 {
+			v = -1
+		} else if e.Value > 0 {
+			v = 1
+		}
+		if e.Code == AbsHat0X {
+			t.hat_x = v
+		} else {
+			t.hat_y = v
+		}
+		t.hat_changed, t.hat_event = true, e
+
+	default:
+		axis, ok := t.axes[e.Code]
+		if !ok {
+			return
+		}
+		v := NormalizeAbs(e.Code, e.Value, t.info.AbsInfo[e.Code])
+		// Send the release before the press so that the axis is never down in
+		// both directions at once.
+		if v < 0 {
+			t.add(gin.ControllerAxis0Positive+axis, 0, e)
+			t.add(gin.ControllerAxis0Negative+axis, -v, e)
+		} else {
+			t.add(gin.ControllerAxis0Negative+axis, 0, e)
+			t.add(gin.ControllerAxis0Positive+axis, v, e)
+		}
+	}
+}
+
+// hat_keys[y+1][x+1] is the hat switch key for the hat position x, y.  Up is
+// negative y, just like evdev.
+var hat_keys = [3][3]gin.KeyIndex{
+	{gin.ControllerHatSwitchUpLeft, gin.ControllerHatSwitchUp, gin.ControllerHatSwitchUpRight},
+	{gin.ControllerHatSwitchLeft, 0, gin.ControllerHatSwitchRight},
+	{gin.ControllerHatSwitchDownLeft, gin.ControllerHatSwitchDown, gin.ControllerHatSwitchDownRight},
+}
+
+func (t *Translator) updateHat(e Event) {
+	key := hat_keys[t.hat_y+1][t.hat_x+1]
+	if t.hat_is_pressed && key == t.hat {
+		return
+	}
+	if t.hat_is_pressed {
+		t.add(t.hat, 0, e)
+	}
+	t.hat = key
+	t.hat_is_pressed = key != 0
+	if t.hat_is_pressed {
+		t.add(t.hat, 1, e)
+	}
+}
+
+func (t *Translator) translateTouch(e Event) {
+	if e.Code == AbsMTSlot {
+		t.slot = int(e.Value)
+		return
+	}
+	if t.slot < 0 || t.slot >= len(t.slots) {
+		return
+	}
+	slot := &t.slots[t.slot]
+	switch e.Code {
+	case AbsMTTrackingId:
+		slot.tracking = e.Value
+	case AbsMTPositionX:
+		slot.x = e.Value
+	case AbsMTPositionY:
+		slot.y = e.Value
+	case AbsMTPressure:
+		slot.pressure = e.Value
+	default:
+		return
+	}
+	slot.changed = true
+}
+
+// scaleTouch scales value on the specified axis to [0, TouchScale].
+func (t *Translator) scaleTouch(code uint16, value int32) int {
+	info := t.info.AbsInfo[code]
+	if info.Max <= info.Min {
+		return 0
+	}
+	v := float64(value-info.Min) / float64(info.Max-info.Min)
+	return int(clamp(v, 0, 1) * TouchScale)
+}
+
+// touchPressure returns the pressure of a contact, which must be non-zero so
+// that the contact's key stays down.  Devices that don't report pressure
+// always report 1.
+func (t *Translator) touchPressure(slot *touchSlot) float64 {
+	if !t.info.Abs.Has(AbsMTPressure) {
+		return 1
+	}
+	info := t.info.AbsInfo[AbsMTPressure]
+	if info.Max <= info.Min {
+		return 1
+	}
+	v := float64(slot.pressure-info.Min) / float64(info.Max-info.Min)
+	return clamp(v, 1e-3, 1)
+}
+
+// updateTouches adds events for every slot that changed during the packet
+// that ends with e.
+func (t *Translator) updateTouches(e Event) {
+	for i := range t.slots {
+		slot := &t.slots[i]
+		if !slot.changed {
+			continue
+		}
+		slot.changed = false
+		index := gin.TouchContact0 + gin.KeyIndex(i)
+		if slot.tracking < 0 {
+			if slot.down {
+				slot.down = false
+				t.add(index, 0, e)
+			}
+			continue
+		}
+		slot.down = true
+		t.pending = append(t.pending, gin.OsEvent{
+			KeyId:     gin.KeyId{Index: index, Device: t.device},
+			Press_amt: t.touchPressure(slot),
+			Timestamp: e.Ms(),
+			X:         t.scaleTouch(AbsMTPositionX, slot.x),
+			Y:         t.scaleTouch(AbsMTPositionY, slot.y),
+		})
+	}
+}
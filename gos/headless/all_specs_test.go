@@ -0,0 +1,22 @@
+package headless_test
+
+import (
+	"github.com/orfjackal/gospec/src/gospec"
+	"testing"
+)
+
+func TestAllSpecs(t *testing.T) {
+	r := gospec.NewRunner()
+	r.AddSpec(HeadlessSpec)
+	r.AddSpec(HeadlessSystemSpec)
+	r.AddSpec(HeadlessTextSpec)
+	r.AddSpec(HeadlessDeviceSpec)
+	r.AddSpec(HeadlessMouseSpec)
+	r.AddSpec(HeadlessTouchSpec)
+	r.AddSpec(HeadlessRunnerSpec)
+	r.AddSpec(HeadlessWindowSpec)
+	r.AddSpec(HeadlessDisplaySpec)
+	r.AddSpec(DisplayModeSpec)
+	r.AddSpec(HeadlessDesktopSpec)
+	gospec.MainGoTest(r, t)
+}
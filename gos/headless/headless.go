@@ -0,0 +1,694 @@
+// Package headless provides a pure-Go implementation of system.Os that has no
+// window, no OpenGl context and no real input devices.  Input is scripted by
+// injecting gin.OsEvents, and the event horizon and focus state are controlled
+// explicitly, so code that runs through system.System can be used in tests and
+// on dedicated servers.
+package headless
+
+import (
+	"fmt"
+	"github.com/runningwild/glop/gin"
+	"github.com/runningwild/glop/system"
+	"image"
+	"sort"
+	"sync"
+)
+
+type window struct {
+	x, y, dx, dy int
+	minimized    bool
+}
+
+// Os is a fake operating system.  All of its methods are safe to call from
+// multiple goroutines, so events can be injected from a different goroutine
+// than the one calling Think().
+type Os struct {
+	mutex sync.Mutex
+
+	started bool
+	window  *window
+
+	cursor_x, cursor_y int
+	cursor_hidden      bool
+	relative_mouse     bool
+
+	title        string
+	icon         image.Image
+	cursor_image image.Image
+	cursor_hot   image.Point
+	cursor       system.StandardCursor
+	clipboard    string
+
+	// Events that have been injected but not yet returned from GetInputEvents().
+	events        []gin.OsEvent
+	text_events   []gin.TextEvent
+	device_events []gin.DeviceEvent
+	window_events []system.WindowEvent
+	horizon       int64
+
+	has_focus bool
+	vsync     bool
+	devices   map[gin.DeviceType][]gin.DeviceIndex
+
+	monitors    []system.Monitor
+	window_mode system.WindowMode
+
+	// While the window is fullscreen these are the dimensions it had before it
+	// went fullscreen.  While it is exclusive fullscreen, mode_monitor is the
+	// monitor whose mode was changed and saved_mode is the mode it had before.
+	windowed     window
+	mode_monitor int
+	saved_mode   system.DisplayMode
+
+	think_count int
+	swap_count  int
+}
+
+// Make returns a new headless Os.  It starts with focus, with a horizon of 0,
+// with no window, and with a single 1920x1080 monitor, see SetMonitors().
+func Make() *Os {
+	return &Os{
+		has_focus: true,
+		devices:   make(map[gin.DeviceType][]gin.DeviceIndex),
+		monitors: []system.Monitor{
+			{
+				Name:    "headless",
+				Dx:      1920,
+				Dy:      1080,
+				Primary: true,
+				Current: system.DisplayMode{Width: 1920, Height: 1080, Refresh: 60},
+				Modes: []system.DisplayMode{
+					{Width: 1920, Height: 1080, Refresh: 60},
+					{Width: 1280, Height: 720, Refresh: 60},
+				},
+				ContentScale: 1,
+			},
+		},
+	}
+}
+
+// Verify that Os implements system.Os
+var _ system.Os = (*Os)(nil)
+
+func (h *Os) Startup() {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	h.started = true
+}
+
+func (h *Os) Think() {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	h.think_count++
+}
+
+func (h *Os) CreateWindow(x, y, width, height int) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	h.window = &window{x: x, y: y, dx: width, dy: height}
+}
+
+func (h *Os) DestroyWindow() {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	if h.window_mode == system.ExclusiveFullscreen {
+		h.restoreMode()
+	}
+	h.window_mode = system.Windowed
+	h.window = nil
+	h.window_events = nil
+}
+
+func (h *Os) GetCursorPos() (int, int) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	return h.cursor_x, h.cursor_y
+}
+
+func (h *Os) HideCursor(hide bool) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	h.cursor_hidden = hide
+}
+
+func (h *Os) SetRelativeMouseMode(enable bool) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	h.relative_mouse = enable
+}
+
+func (h *Os) GetWindowDims() (int, int, int, int) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	if h.window == nil {
+		return 0, 0, 0, 0
+	}
+	return h.window.x, h.window.y, h.window.dx, h.window.dy
+}
+
+func (h *Os) SetWindowTitle(title string) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	h.title = title
+}
+
+func (h *Os) SetWindowIcon(icon image.Image) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	h.icon = icon
+}
+
+func (h *Os) SetCursorImage(img image.Image, hot_x, hot_y int) error {
+	if err := system.CheckCursorImage(img, hot_x, hot_y); err != nil {
+		return err
+	}
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	h.cursor_image = img
+	h.cursor_hot = image.Pt(hot_x, hot_y)
+	h.cursor = system.CursorArrow
+	return nil
+}
+
+func (h *Os) SetStandardCursor(cursor system.StandardCursor) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	h.cursor_image = nil
+	h.cursor = cursor
+}
+
+// The clipboard is only shared with whatever else uses this Os.
+func (h *Os) GetClipboardText() string {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	return h.clipboard
+}
+
+func (h *Os) SetClipboardText(text string) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	h.clipboard = text
+}
+
+func copyMonitors(monitors []system.Monitor) []system.Monitor {
+	ret := make([]system.Monitor, len(monitors))
+	copy(ret, monitors)
+	for i := range ret {
+		ret[i].Modes = append([]system.DisplayMode(nil), ret[i].Modes...)
+	}
+	return ret
+}
+
+func (h *Os) GetMonitors() []system.Monitor {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	return copyMonitors(h.monitors)
+}
+
+// SetWindowMode changes the window's dimensions, queueing WindowMoved and
+// WindowResized events as appropriate, and changes the Current mode of the
+// monitor when going exclusive fullscreen.
+func (h *Os) SetWindowMode(mode system.WindowMode, monitor int, display_mode system.DisplayMode) error {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	if h.window == nil {
+		return fmt.Errorf("Can't set the window mode without a window.")
+	}
+	if err := system.CheckWindowMode(h.monitors, mode, monitor, display_mode); err != nil {
+		return err
+	}
+	if h.window_mode == system.ExclusiveFullscreen {
+		h.restoreMode()
+	}
+	if mode == system.Windowed {
+		if h.window_mode != system.Windowed {
+			h.setWindowDims(h.windowed.x, h.windowed.y, h.windowed.dx, h.windowed.dy)
+		}
+		h.window_mode = mode
+		return nil
+	}
+	if h.window_mode == system.Windowed {
+		h.windowed = *h.window
+	}
+	m := &h.monitors[monitor]
+	if mode == system.ExclusiveFullscreen {
+		h.mode_monitor = monitor
+		h.saved_mode = m.Current
+		m.Current = display_mode
+		m.Dx, m.Dy = display_mode.Width, display_mode.Height
+	}
+	h.window_mode = mode
+	h.setWindowDims(m.X, m.Y, m.Dx, m.Dy)
+	return nil
+}
+
+// restoreMode undoes the display mode change from going exclusive fullscreen.
+// h.mutex must be held.
+func (h *Os) restoreMode() {
+	if h.mode_monitor >= len(h.monitors) {
+		return
+	}
+	m := &h.monitors[h.mode_monitor]
+	m.Current = h.saved_mode
+	m.Dx, m.Dy = h.saved_mode.Width, h.saved_mode.Height
+}
+
+func (h *Os) GetWindowMode() system.WindowMode {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	return h.window_mode
+}
+
+func (h *Os) GetContentScale() float64 {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	if len(h.monitors) == 0 {
+		return 1
+	}
+	index := 0
+	if h.window != nil {
+		index = system.MonitorAt(h.monitors, h.window.x+h.window.dx/2, h.window.y+h.window.dy/2)
+		if index == -1 {
+			index = 0
+		}
+	}
+	return h.monitors[index].ContentScale
+}
+
+// SetMonitors sets the monitors returned by GetMonitors(), the primary monitor
+// should be first.
+func (h *Os) SetMonitors(monitors []system.Monitor) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	h.monitors = copyMonitors(monitors)
+}
+
+func (h *Os) SwapBuffers() {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	h.swap_count++
+}
+
+func (h *Os) GetActiveDevices() map[gin.DeviceType][]gin.DeviceIndex {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	ret := make(map[gin.DeviceType][]gin.DeviceIndex, len(h.devices))
+	for device_type, indexes := range h.devices {
+		ret[device_type] = append([]gin.DeviceIndex(nil), indexes...)
+	}
+	return ret
+}
+
+type osEventSlice []gin.OsEvent
+
+func (oes osEventSlice) Len() int           { return len(oes) }
+func (oes osEventSlice) Swap(i, j int)      { oes[i], oes[j] = oes[j], oes[i] }
+func (oes osEventSlice) Less(i, j int) bool { return oes[i].Timestamp < oes[j].Timestamp }
+
+type textEventSlice []gin.TextEvent
+
+func (tes textEventSlice) Len() int           { return len(tes) }
+func (tes textEventSlice) Swap(i, j int)      { tes[i], tes[j] = tes[j], tes[i] }
+func (tes textEventSlice) Less(i, j int) bool { return tes[i].Timestamp < tes[j].Timestamp }
+
+// GetInputEvents returns, in timestamp order, all injected events whose
+// timestamps are less than or equal to the current horizon.  Events injected
+// with a timestamp beyond the horizon are held until the horizon passes them.
+func (h *Os) GetInputEvents() ([]gin.OsEvent, int64) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	sort.Stable(osEventSlice(h.events))
+	n := sort.Search(len(h.events), func(i int) bool {
+		return h.events[i].Timestamp > h.horizon
+	})
+	ret := make([]gin.OsEvent, n)
+	copy(ret, h.events[0:n])
+	h.events = append(h.events[0:0], h.events[n:]...)
+	return ret, h.horizon
+}
+
+// GetTextEvents returns, in timestamp order, all injected text events whose
+// timestamps are less than or equal to the current horizon.
+func (h *Os) GetTextEvents() []gin.TextEvent {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	sort.Stable(textEventSlice(h.text_events))
+	n := sort.Search(len(h.text_events), func(i int) bool {
+		return h.text_events[i].Timestamp > h.horizon
+	})
+	ret := make([]gin.TextEvent, n)
+	copy(ret, h.text_events[0:n])
+	h.text_events = append(h.text_events[0:0], h.text_events[n:]...)
+	return ret
+}
+
+// GetDeviceEvents returns all device events that have happened since the last
+// call, see ConnectDevice() and DisconnectDevice().
+func (h *Os) GetDeviceEvents() []gin.DeviceEvent {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	ret := h.device_events
+	h.device_events = nil
+	return ret
+}
+
+// GetWindowEvents returns all window events that have happened since the last
+// call, see RequestClose(), MoveWindow(), ResizeWindow(), SetFocus() and
+// SetMinimized().
+func (h *Os) GetWindowEvents() []system.WindowEvent {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	ret := h.window_events
+	h.window_events = nil
+	return ret
+}
+
+func (h *Os) EnableVSync(enable bool) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	h.vsync = enable
+}
+
+func (h *Os) HasFocus() bool {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	return h.has_focus
+}
+
+// InjectEvents queues events to be returned by GetInputEvents().  They do not
+// need to be in timestamp order.
+func (h *Os) InjectEvents(events ...gin.OsEvent) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	h.events = append(h.events, events...)
+}
+
+// InjectTextEvents queues text events to be returned by GetTextEvents().
+func (h *Os) InjectTextEvents(events ...gin.TextEvent) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	h.text_events = append(h.text_events, events...)
+}
+
+// InjectText is a convenience function for injecting committed text that was
+// typed on the specified keyboard.
+func (h *Os) InjectText(keyboard gin.DeviceIndex, text string, timestamp int64) {
+	h.InjectTextEvents(gin.TextEvent{
+		Type:      gin.TextInput,
+		Text:      text,
+		Device:    gin.DeviceId{Type: gin.DeviceTypeKeyboard, Index: keyboard},
+		Timestamp: timestamp,
+	})
+}
+
+// InjectPress is a convenience function for injecting a single event.
+func (h *Os) InjectPress(id gin.KeyId, press_amt float64, timestamp int64) {
+	h.InjectEvents(gin.OsEvent{
+		KeyId:     id,
+		Press_amt: press_amt,
+		Timestamp: timestamp,
+	})
+}
+
+// InjectMouseMotion injects motion on the specified mouse, as MouseXAxis and
+// MouseYAxis events.
+func (h *Os) InjectMouseMotion(mouse gin.DeviceIndex, dx, dy float64, timestamp int64) {
+	device := gin.DeviceId{Type: gin.DeviceTypeMouse, Index: mouse}
+	h.InjectEvents(
+		gin.OsEvent{KeyId: gin.KeyId{Index: gin.MouseXAxis, Device: device}, Press_amt: dx, Timestamp: timestamp},
+		gin.OsEvent{KeyId: gin.KeyId{Index: gin.MouseYAxis, Device: device}, Press_amt: dy, Timestamp: timestamp},
+	)
+}
+
+// InjectTouch injects an event for a contact on the specified touch device.
+// x and y are in window coordinates, and a pressure of 0 ends the contact.
+func (h *Os) InjectTouch(touch gin.DeviceIndex, contact int, pressure float64, x, y int, timestamp int64) {
+	h.InjectEvents(gin.OsEvent{
+		KeyId: gin.KeyId{
+			Index:  gin.TouchContact0 + gin.KeyIndex(contact),
+			Device: gin.DeviceId{Type: gin.DeviceTypeTouch, Index: touch},
+		},
+		Press_amt: pressure,
+		Timestamp: timestamp,
+		X:         x,
+		Y:         y,
+	})
+}
+
+// SetHorizon sets the event horizon that will be reported by the next call to
+// GetInputEvents().  The horizon should never move backwards.
+func (h *Os) SetHorizon(horizon int64) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	h.horizon = horizon
+}
+
+// Advance moves the event horizon forward by ms milliseconds.
+func (h *Os) Advance(ms int64) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	h.horizon += ms
+}
+
+func (h *Os) Horizon() int64 {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	return h.horizon
+}
+
+// SetFocus sets the value returned by HasFocus().  If there is a window and
+// the focus changes then a WindowFocusGained or WindowFocusLost event is
+// queued, timestamped with the current horizon.
+func (h *Os) SetFocus(has_focus bool) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	if has_focus == h.has_focus {
+		return
+	}
+	h.has_focus = has_focus
+	if has_focus {
+		h.pushWindowEvent(system.WindowFocusGained)
+	} else {
+		h.pushWindowEvent(system.WindowFocusLost)
+	}
+}
+
+// pushWindowEvent queues a window event with the window's current dimensions,
+// if there is a window.  h.mutex must be held.
+func (h *Os) pushWindowEvent(event_type system.WindowEventType) {
+	if h.window == nil {
+		return
+	}
+	h.window_events = append(h.window_events, system.WindowEvent{
+		Type:      event_type,
+		X:         h.window.x,
+		Y:         h.window.y,
+		Dx:        h.window.dx,
+		Dy:        h.window.dy,
+		Timestamp: h.horizon,
+	})
+}
+
+// RequestClose queues a WindowCloseRequested event, as if the user had clicked
+// the window's close button.  It does nothing if there is no window.
+func (h *Os) RequestClose() {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	h.pushWindowEvent(system.WindowCloseRequested)
+}
+
+// setWindowDims changes the window's dimensions and queues a WindowMoved
+// and/or WindowResized event if they changed.  h.mutex must be held.
+func (h *Os) setWindowDims(x, y, dx, dy int) {
+	if h.window == nil {
+		return
+	}
+	moved := h.window.x != x || h.window.y != y
+	resized := h.window.dx != dx || h.window.dy != dy
+	h.window.x, h.window.y, h.window.dx, h.window.dy = x, y, dx, dy
+	if moved {
+		h.pushWindowEvent(system.WindowMoved)
+	}
+	if resized {
+		h.pushWindowEvent(system.WindowResized)
+	}
+}
+
+// MoveWindow moves the window and queues a WindowMoved event.  It does nothing
+// if there is no window or if the window is already at x, y.
+func (h *Os) MoveWindow(x, y int) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	if h.window == nil {
+		return
+	}
+	h.setWindowDims(x, y, h.window.dx, h.window.dy)
+}
+
+// ResizeWindow resizes the window and queues a WindowResized event.  It does
+// nothing if there is no window or if the window is already dx by dy.
+func (h *Os) ResizeWindow(dx, dy int) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	if h.window == nil {
+		return
+	}
+	h.setWindowDims(h.window.x, h.window.y, dx, dy)
+}
+
+// SetMinimized minimizes or restores the window and queues a WindowMinimized
+// or WindowRestored event.  It does nothing if there is no window or if the
+// window is already in that state.
+func (h *Os) SetMinimized(minimized bool) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	if h.window == nil || h.window.minimized == minimized {
+		return
+	}
+	h.window.minimized = minimized
+	if minimized {
+		h.pushWindowEvent(system.WindowMinimized)
+	} else {
+		h.pushWindowEvent(system.WindowRestored)
+	}
+}
+
+func (h *Os) Minimized() bool {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	return h.window != nil && h.window.minimized
+}
+
+// SetCursorPos sets the position reported by GetCursorPos(), in window
+// coordinates.
+func (h *Os) SetCursorPos(x, y int) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	h.cursor_x, h.cursor_y = x, y
+}
+
+func (h *Os) Title() string {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	return h.title
+}
+
+func (h *Os) Icon() image.Image {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	return h.icon
+}
+
+// CursorImage returns the image and hot spot set by SetCursorImage(), or a nil
+// image if a standard cursor is being used.
+func (h *Os) CursorImage() (image.Image, int, int) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	return h.cursor_image, h.cursor_hot.X, h.cursor_hot.Y
+}
+
+// StandardCursor returns the standard cursor being used, which is CursorArrow
+// if a cursor image is being used instead.
+func (h *Os) StandardCursor() system.StandardCursor {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	return h.cursor
+}
+
+func (h *Os) CursorHidden() bool {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	return h.cursor_hidden
+}
+
+func (h *Os) RelativeMouseMode() bool {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	return h.relative_mouse
+}
+
+// SetActiveDevices sets the value returned by GetActiveDevices().
+func (h *Os) SetActiveDevices(devices map[gin.DeviceType][]gin.DeviceIndex) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	h.devices = make(map[gin.DeviceType][]gin.DeviceIndex, len(devices))
+	for device_type, indexes := range devices {
+		h.devices[device_type] = append([]gin.DeviceIndex(nil), indexes...)
+	}
+}
+
+// ConnectDevice adds a device to the set returned by GetActiveDevices() and
+// queues a gin.DeviceConnected event for it, timestamped with the current
+// horizon.  Connecting a device that is already connected does nothing.
+func (h *Os) ConnectDevice(device gin.DeviceId, name string) {
+	h.ConnectDeviceWithGUID(device, name, "")
+}
+
+// ConnectDeviceWithGUID is like ConnectDevice(), but the device also reports
+// the specified GUID so that it can be given a gin.GamepadMapping.
+func (h *Os) ConnectDeviceWithGUID(device gin.DeviceId, name, guid string) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	for _, index := range h.devices[device.Type] {
+		if index == device.Index {
+			return
+		}
+	}
+	h.devices[device.Type] = append(h.devices[device.Type], device.Index)
+	h.device_events = append(h.device_events, gin.DeviceEvent{
+		Type:      gin.DeviceConnected,
+		Device:    device,
+		Name:      name,
+		GUID:      guid,
+		Timestamp: h.horizon,
+	})
+}
+
+// DisconnectDevice removes a device from the set returned by
+// GetActiveDevices() and queues a gin.DeviceDisconnected event for it,
+// timestamped with the current horizon.  Disconnecting a device that is not
+// connected does nothing.
+func (h *Os) DisconnectDevice(device gin.DeviceId) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	indexes := h.devices[device.Type]
+	for i, index := range indexes {
+		if index != device.Index {
+			continue
+		}
+		h.devices[device.Type] = append(indexes[0:i:i], indexes[i+1:]...)
+		h.device_events = append(h.device_events, gin.DeviceEvent{
+			Type:      gin.DeviceDisconnected,
+			Device:    device,
+			Timestamp: h.horizon,
+		})
+		return
+	}
+}
+
+// HasWindow returns true iff CreateWindow() has been called.
+func (h *Os) HasWindow() bool {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	return h.window != nil
+}
+
+func (h *Os) VSyncEnabled() bool {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	return h.vsync
+}
+
+// ThinkCount returns the number of times Think() has been called.
+func (h *Os) ThinkCount() int {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	return h.think_count
+}
+
+// SwapCount returns the number of times SwapBuffers() has been called.
+func (h *Os) SwapCount() int {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	return h.swap_count
+}
@@ -0,0 +1,522 @@
+package headless_test
+
+import (
+	"github.com/orfjackal/gospec/src/gospec"
+	. "github.com/orfjackal/gospec/src/gospec"
+	"github.com/runningwild/glop/gin"
+	"github.com/runningwild/glop/gos/headless"
+	"github.com/runningwild/glop/system"
+	"image"
+	"time"
+)
+
+var keya = gin.KeyId{Index: gin.KeyA, Device: gin.DeviceId{Type: gin.DeviceTypeKeyboard, Index: 1}}
+
+func HeadlessSpec(c gospec.Context) {
+	h := headless.Make()
+	c.Specify("Events are held until the horizon passes them.", func() {
+		h.InjectPress(keya, 1, 20)
+		h.InjectPress(keya, 0, 5)
+		events, horizon := h.GetInputEvents()
+		c.Expect(horizon, Equals, int64(0))
+		c.Expect(len(events), Equals, 0)
+
+		h.SetHorizon(10)
+		events, horizon = h.GetInputEvents()
+		c.Expect(horizon, Equals, int64(10))
+		c.Expect(len(events), Equals, 1)
+		c.Expect(events[0].Timestamp, Equals, int64(5))
+
+		h.Advance(10)
+		events, horizon = h.GetInputEvents()
+		c.Expect(horizon, Equals, int64(20))
+		c.Expect(len(events), Equals, 1)
+		c.Expect(events[0].Timestamp, Equals, int64(20))
+
+		events, _ = h.GetInputEvents()
+		c.Expect(len(events), Equals, 0)
+	})
+
+	c.Specify("Focus, window and cursor state are controllable.", func() {
+		c.Expect(h.HasFocus(), Equals, true)
+		h.SetFocus(false)
+		c.Expect(h.HasFocus(), Equals, false)
+
+		c.Expect(h.HasWindow(), Equals, false)
+		h.CreateWindow(10, 20, 300, 200)
+		x, y, dx, dy := h.GetWindowDims()
+		c.Expect(h.HasWindow(), Equals, true)
+		c.Expect([]int{x, y, dx, dy}, ContainsInOrder, []int{10, 20, 300, 200})
+
+		h.SetCursorPos(3, 4)
+		cx, cy := h.GetCursorPos()
+		c.Expect(cx, Equals, 3)
+		c.Expect(cy, Equals, 4)
+	})
+}
+
+func HeadlessSystemSpec(c gospec.Context) {
+	h := headless.Make()
+	h.SetHorizon(1000)
+	sys := system.Make(h)
+	sys.Startup()
+	c.Specify("Events injected into a headless Os come out of system.System.", func() {
+		h.InjectPress(keya, 1, 1005)
+		h.Advance(10)
+		sys.Think()
+		groups := sys.GetInputEvents()
+		c.Expect(h.ThinkCount(), Equals, 1)
+		c.Expect(len(groups), Equals, 1)
+		found, event := groups[0].FindEvent(keya)
+		c.Expect(found, Equals, true)
+		c.Expect(event.Type, Equals, gin.Press)
+		c.Expect(groups[0].Timestamp, Equals, int64(5))
+
+		h.InjectPress(keya, 0, 1015)
+		h.Advance(10)
+		sys.Think()
+		groups = sys.GetInputEvents()
+		c.Expect(len(groups), Equals, 1)
+		found, event = groups[0].FindEvent(keya)
+		c.Expect(found, Equals, true)
+		c.Expect(event.Type, Equals, gin.Release)
+	})
+}
+
+type textListener struct {
+	texts []string
+}
+
+func (l *textListener) HandleEventGroup(gin.EventGroup) {}
+func (l *textListener) Think()                          {}
+func (l *textListener) HandleTextEvent(event gin.TextEvent) {
+	l.texts = append(l.texts, event.Text)
+}
+
+func HeadlessTextSpec(c gospec.Context) {
+	h := headless.Make()
+	h.SetHorizon(1000)
+	sys := system.Make(h)
+	sys.Startup()
+	c.Specify("Text events are delivered through system.System and to listeners.", func() {
+		listener := &textListener{}
+		gin.In().RegisterEventListener(listener)
+		defer gin.In().UnregisterEventListener(listener)
+		h.InjectText(1, "héllo", 1005)
+		h.InjectTextEvents(gin.TextEvent{Type: gin.TextComposition, Text: "にほ", Cursor: 2, Timestamp: 1007})
+		h.InjectText(1, "later", 1020)
+		h.Advance(10)
+		sys.Think()
+		events := sys.GetTextEvents()
+		c.Expect(len(events), Equals, 2)
+		c.Expect(events[0].Text, Equals, "héllo")
+		c.Expect(events[0].Timestamp, Equals, int64(5))
+		c.Expect(events[1].Type, Equals, gin.TextComposition)
+		c.Expect(listener.texts, ContainsInOrder, []string{"héllo", "にほ"})
+
+		h.Advance(10)
+		sys.Think()
+		events = sys.GetTextEvents()
+		c.Expect(len(events), Equals, 1)
+		c.Expect(events[0].Text, Equals, "later")
+	})
+}
+
+type deviceListener struct {
+	events []gin.DeviceEvent
+}
+
+func (l *deviceListener) HandleEventGroup(gin.EventGroup) {}
+func (l *deviceListener) Think()                          {}
+func (l *deviceListener) HandleDeviceEvent(event gin.DeviceEvent) {
+	l.events = append(l.events, event)
+}
+
+func HeadlessDeviceSpec(c gospec.Context) {
+	h := headless.Make()
+	h.SetHorizon(1000)
+	sys := system.Make(h)
+	sys.Startup()
+	pad := gin.DeviceId{Type: gin.DeviceTypeController, Index: 2}
+	button := gin.KeyId{Index: gin.ControllerButton0, Device: pad}
+	c.Specify("Devices can be connected and disconnected.", func() {
+		listener := &deviceListener{}
+		gin.In().RegisterEventListener(listener)
+		defer gin.In().UnregisterEventListener(listener)
+
+		h.ConnectDevice(pad, "Gamepad")
+		h.Advance(10)
+		h.InjectPress(button, 1, 1005)
+		sys.Think()
+		c.Expect(len(sys.GetDeviceEvents()), Equals, 1)
+		c.Expect(len(listener.events), Equals, 1)
+		c.Expect(listener.events[0].Type, Equals, gin.DeviceConnected)
+		c.Expect(listener.events[0].Name, Equals, "Gamepad")
+		c.Expect(sys.GetActiveDevices()[gin.DeviceTypeController], ContainsExactly, []gin.DeviceIndex{2})
+		c.Expect(gin.In().GetKey(button).IsDown(), Equals, true)
+
+		c.Specify("Keys are released when their device is disconnected.", func() {
+			h.DisconnectDevice(pad)
+			h.Advance(10)
+			sys.Think()
+			c.Expect(len(listener.events), Equals, 2)
+			c.Expect(listener.events[1].Type, Equals, gin.DeviceDisconnected)
+			c.Expect(listener.events[1].Timestamp, Equals, int64(10))
+			c.Expect(len(sys.GetActiveDevices()[gin.DeviceTypeController]), Equals, 0)
+			c.Expect(gin.In().GetKey(button).IsDown(), Equals, false)
+			c.Expect(gin.In().GetKey(button).FrameReleaseCount(), Equals, 1)
+		})
+	})
+}
+
+func HeadlessMouseSpec(c gospec.Context) {
+	h := headless.Make()
+	h.SetHorizon(1000)
+	sys := system.Make(h)
+	sys.Startup()
+	c.Specify("Relative mouse mode reaches the Os.", func() {
+		sys.SetRelativeMouseMode(true)
+		c.Expect(h.RelativeMouseMode(), Equals, true)
+		sys.SetRelativeMouseMode(false)
+		c.Expect(h.RelativeMouseMode(), Equals, false)
+	})
+	c.Specify("Each mouse reports its own motion.", func() {
+		h.InjectMouseMotion(7, 3, -1, 1002)
+		h.InjectMouseMotion(8, -5, 0, 1003)
+		h.InjectMouseMotion(7, 2, -2, 1004)
+		h.Advance(10)
+		sys.Think()
+		dx, dy := gin.In().MouseDelta(7)
+		c.Expect(dx, Equals, 5.0)
+		c.Expect(dy, Equals, -3.0)
+		dx, dy = gin.In().MouseDelta(8)
+		c.Expect(dx, Equals, -5.0)
+		c.Expect(dy, Equals, 0.0)
+	})
+}
+
+func HeadlessTouchSpec(c gospec.Context) {
+	h := headless.Make()
+	h.SetHorizon(1000)
+	sys := system.Make(h)
+	sys.Startup()
+	c.Specify("Touches reach gin with their positions.", func() {
+		screen := gin.DeviceId{Type: gin.DeviceTypeTouch, Index: 9}
+		tap := gin.In().BindTapKey("headless tap", screen, 100, 5)
+		h.InjectTouch(9, 0, 1, 40, 60, 1002)
+		h.Advance(10)
+		sys.Think()
+		contacts := gin.In().Contacts(screen)
+		c.Assume(len(contacts), Equals, 1)
+		c.Expect(contacts[0].Phase, Equals, gin.TouchBegin)
+		x, y := contacts[0].Cursor.Point()
+		c.Expect(x, Equals, 40)
+		c.Expect(y, Equals, 60)
+
+		h.InjectTouch(9, 0, 0, 0, 0, 1012)
+		h.Advance(10)
+		sys.Think()
+		c.Expect(tap.FramePressCount(), Equals, 1)
+	})
+}
+
+func HeadlessRunnerSpec(c gospec.Context) {
+	h := headless.Make()
+	sys := system.Make(h)
+	sys.Startup()
+
+	// The clock advances by the next step every time it is read.
+	var now time.Duration
+	var steps []time.Duration
+	clock := func() time.Duration {
+		if len(steps) > 0 {
+			now += steps[0]
+			steps = steps[1:]
+		}
+		return now
+	}
+
+	var log []string
+	var alphas []float64
+	var runner *system.Runner
+	on_update := func() {}
+	on_draw := func() {}
+	update := func() {
+		log = append(log, "update")
+		on_update()
+	}
+	draw := func(alpha float64) {
+		log = append(log, "draw")
+		alphas = append(alphas, alpha)
+		on_draw()
+		if len(steps) == 0 {
+			runner.Quit()
+		}
+	}
+	runner = system.MakeRunner(sys, 100, update, draw)
+	runner.SetClock(clock)
+
+	c.Specify("Ticks are fixed and frames are interpolated.", func() {
+		steps = []time.Duration{0, 25 * time.Millisecond, 25 * time.Millisecond, 25 * time.Millisecond}
+		runner.Run()
+		c.Expect(runner.Ticks(), Equals, int64(7))
+		c.Expect(runner.Frames(), Equals, int64(3))
+		c.Expect(alphas, ContainsInOrder, []float64{0.5, 0, 0.5})
+		c.Expect(log, ContainsInOrder, []string{"update", "update", "draw", "update", "update", "update", "draw", "update", "update", "draw"})
+		c.Expect(h.SwapCount(), Equals, 3)
+		c.Expect(h.ThinkCount(), Equals, 3)
+	})
+
+	c.Specify("Long frames are capped.", func() {
+		runner.SetMaxFrameTime(50 * time.Millisecond)
+		steps = []time.Duration{0, time.Second, 5 * time.Millisecond}
+		runner.Run()
+		c.Expect(runner.Ticks(), Equals, int64(5))
+		c.Expect(alphas, ContainsInOrder, []float64{0, 0.5})
+	})
+
+	c.Specify("Nothing ticks while paused.", func() {
+		steps = []time.Duration{0, 15 * time.Millisecond, 100 * time.Millisecond, 100 * time.Millisecond, 20 * time.Millisecond}
+		on_draw = func() {
+			switch len(alphas) {
+			case 1:
+				runner.Pause()
+			case 3:
+				runner.Resume()
+			}
+		}
+		runner.Run()
+		c.Expect(runner.Ticks(), Equals, int64(3))
+		c.Expect(alphas, ContainsInOrder, []float64{0.5, 0.5, 0.5, 0.5})
+	})
+
+	c.Specify("Quitting from update stops before the next frame.", func() {
+		steps = []time.Duration{0, 50 * time.Millisecond}
+		on_update = runner.Quit
+		runner.Run()
+		c.Expect(runner.Ticks(), Equals, int64(1))
+		c.Expect(runner.Frames(), Equals, int64(0))
+		c.Expect(h.SwapCount(), Equals, 0)
+	})
+}
+
+func HeadlessWindowSpec(c gospec.Context) {
+	h := headless.Make()
+	h.SetHorizon(1000)
+	sys := system.Make(h)
+	sys.Startup()
+	sys.CreateWindow(10, 20, 300, 200)
+	c.Specify("Window events reach the System in order.", func() {
+		h.Advance(5)
+		h.MoveWindow(30, 40)
+		h.ResizeWindow(640, 480)
+		h.ResizeWindow(640, 480)
+		h.SetFocus(false)
+		h.SetMinimized(true)
+		h.SetMinimized(false)
+		h.RequestClose()
+		sys.Think()
+		events := sys.GetWindowEvents()
+		c.Assume(len(events), Equals, 6)
+		var types []system.WindowEventType
+		for _, event := range events {
+			types = append(types, event.Type)
+		}
+		c.Expect(types, ContainsInOrder, []system.WindowEventType{
+			system.WindowMoved,
+			system.WindowResized,
+			system.WindowFocusLost,
+			system.WindowMinimized,
+			system.WindowRestored,
+			system.WindowCloseRequested,
+		})
+		c.Expect(events[0].X, Equals, 30)
+		c.Expect(events[0].Dx, Equals, 300)
+		c.Expect(events[1].Dx, Equals, 640)
+		c.Expect(events[1].Dy, Equals, 480)
+		c.Expect(events[1].Timestamp, Equals, int64(5))
+
+		sys.Think()
+		c.Expect(len(sys.GetWindowEvents()), Equals, 0)
+	})
+	c.Specify("A destroyed window can be recreated at a different size.", func() {
+		h.RequestClose()
+		sys.DestroyWindow()
+		c.Expect(h.HasWindow(), Equals, false)
+		h.ResizeWindow(10, 10)
+		sys.Think()
+		c.Expect(len(sys.GetWindowEvents()), Equals, 0)
+
+		sys.CreateWindow(0, 0, 800, 600)
+		_, _, dx, dy := sys.GetWindowDims()
+		c.Expect(dx, Equals, 800)
+		c.Expect(dy, Equals, 600)
+		h.SetFocus(false)
+		h.SetFocus(true)
+		sys.Think()
+		events := sys.GetWindowEvents()
+		c.Assume(len(events), Equals, 2)
+		c.Expect(events[1].Type, Equals, system.WindowFocusGained)
+		c.Expect(events[1].Dx, Equals, 800)
+	})
+}
+
+func HeadlessDisplaySpec(c gospec.Context) {
+	h := headless.Make()
+	h.SetMonitors([]system.Monitor{
+		{
+			Name:    "main",
+			Dx:      2560,
+			Dy:      1440,
+			Primary: true,
+			Current: system.DisplayMode{Width: 2560, Height: 1440, Refresh: 144},
+			Modes: []system.DisplayMode{
+				{Width: 2560, Height: 1440, Refresh: 144},
+				{Width: 2560, Height: 1440, Refresh: 60},
+				{Width: 1920, Height: 1080, Refresh: 60},
+			},
+			ContentScale: 2,
+		},
+		{
+			Name:         "side",
+			X:            2560,
+			Dx:           1280,
+			Dy:           1024,
+			Current:      system.DisplayMode{Width: 1280, Height: 1024, Refresh: 75},
+			Modes:        []system.DisplayMode{{Width: 1280, Height: 1024, Refresh: 75}},
+			ContentScale: 1,
+		},
+	})
+	sys := system.Make(h)
+	sys.Startup()
+	sys.CreateWindow(100, 100, 800, 600)
+
+	c.Specify("Monitors and their modes can be listed.", func() {
+		monitors := sys.GetMonitors()
+		c.Assume(len(monitors), Equals, 2)
+		c.Expect(monitors[0].Primary, Equals, true)
+		c.Expect(len(monitors[0].Modes), Equals, 3)
+		c.Expect(monitors[1].HasMode(system.DisplayMode{Width: 1280, Height: 1024, Refresh: 75}), Equals, true)
+		c.Expect(monitors[1].HasMode(system.DisplayMode{Width: 1280, Height: 1024, Refresh: 60}), Equals, false)
+		monitors[0].Modes[0].Width = 1
+		c.Expect(sys.GetMonitors()[0].Modes[0].Width, Equals, 2560)
+	})
+
+	c.Specify("Content scale comes from the monitor the window is on.", func() {
+		c.Expect(sys.GetContentScale(), Equals, 2.0)
+		h.MoveWindow(3000, 100)
+		c.Expect(sys.GetContentScale(), Equals, 1.0)
+	})
+
+	c.Specify("Borderless fullscreen covers the monitor and windowed restores the window.", func() {
+		c.Assume(sys.SetWindowMode(system.BorderlessFullscreen, 1, system.DisplayMode{}), IsNil)
+		c.Expect(sys.GetWindowMode(), Equals, system.BorderlessFullscreen)
+		x, y, dx, dy := sys.GetWindowDims()
+		c.Expect(x, Equals, 2560)
+		c.Expect(y, Equals, 0)
+		c.Expect(dx, Equals, 1280)
+		c.Expect(dy, Equals, 1024)
+
+		c.Assume(sys.SetWindowMode(system.Windowed, 0, system.DisplayMode{}), IsNil)
+		c.Expect(sys.GetWindowMode(), Equals, system.Windowed)
+		x, y, dx, dy = sys.GetWindowDims()
+		c.Expect(x, Equals, 100)
+		c.Expect(dx, Equals, 800)
+		c.Expect(dy, Equals, 600)
+		sys.Think()
+		c.Expect(len(sys.GetWindowEvents()), Equals, 4)
+	})
+
+	c.Specify("Exclusive fullscreen changes the monitor's mode until it ends.", func() {
+		mode := system.DisplayMode{Width: 1920, Height: 1080, Refresh: 60}
+		c.Assume(sys.SetWindowMode(system.ExclusiveFullscreen, 0, mode), IsNil)
+		c.Expect(sys.GetMonitors()[0].Current, Equals, mode)
+		_, _, dx, dy := sys.GetWindowDims()
+		c.Expect(dx, Equals, 1920)
+		c.Expect(dy, Equals, 1080)
+
+		c.Assume(sys.SetWindowMode(system.BorderlessFullscreen, 0, system.DisplayMode{}), IsNil)
+		c.Expect(sys.GetMonitors()[0].Current.Refresh, Equals, 144.0)
+		_, _, dx, _ = sys.GetWindowDims()
+		c.Expect(dx, Equals, 2560)
+
+		c.Assume(sys.SetWindowMode(system.ExclusiveFullscreen, 0, mode), IsNil)
+		sys.DestroyWindow()
+		c.Expect(sys.GetMonitors()[0].Current.Width, Equals, 2560)
+		c.Expect(sys.GetWindowMode(), Equals, system.Windowed)
+	})
+
+	c.Specify("Bad window modes are rejected.", func() {
+		c.Expect(sys.SetWindowMode(system.BorderlessFullscreen, 2, system.DisplayMode{}), Not(IsNil))
+		c.Expect(sys.SetWindowMode(system.ExclusiveFullscreen, 0, system.DisplayMode{Width: 640, Height: 480}), Not(IsNil))
+		c.Expect(sys.SetWindowMode(system.WindowMode(7), 0, system.DisplayMode{}), Not(IsNil))
+		c.Expect(sys.GetWindowMode(), Equals, system.Windowed)
+		sys.DestroyWindow()
+		c.Expect(sys.SetWindowMode(system.BorderlessFullscreen, 0, system.DisplayMode{}), Not(IsNil))
+	})
+}
+
+func DisplayModeSpec(c gospec.Context) {
+	c.Specify("Display modes are sorted largest and fastest first, without duplicates.", func() {
+		modes := system.SortDisplayModes([]system.DisplayMode{
+			{Width: 1280, Height: 720, Refresh: 60},
+			{Width: 1920, Height: 1080, Refresh: 60},
+			{Width: 1920, Height: 1080, Refresh: 144},
+			{Width: 1280, Height: 720, Refresh: 60},
+			{Width: 1920, Height: 1200, Refresh: 60},
+		})
+		c.Expect(modes, ContainsInOrder, []system.DisplayMode{
+			{Width: 1920, Height: 1200, Refresh: 60},
+			{Width: 1920, Height: 1080, Refresh: 144},
+			{Width: 1920, Height: 1080, Refresh: 60},
+			{Width: 1280, Height: 720, Refresh: 60},
+		})
+		c.Expect(len(modes), Equals, 4)
+	})
+}
+
+func HeadlessDesktopSpec(c gospec.Context) {
+	h := headless.Make()
+	sys := system.Make(h)
+	sys.Startup()
+	c.Specify("The title and icon are kept across windows.", func() {
+		sys.SetWindowTitle("Editor")
+		icon := image.NewRGBA(image.Rect(0, 0, 16, 16))
+		sys.SetWindowIcon(icon)
+		sys.CreateWindow(0, 0, 100, 100)
+		sys.DestroyWindow()
+		c.Expect(h.Title(), Equals, "Editor")
+		c.Expect(h.Icon(), Equals, image.Image(icon))
+		sys.SetWindowIcon(nil)
+		c.Expect(h.Icon(), IsNil)
+	})
+	c.Specify("Cursors can be images or standard shapes.", func() {
+		c.Expect(h.StandardCursor(), Equals, system.CursorArrow)
+		img := image.NewRGBA(image.Rect(10, 10, 42, 42))
+		c.Expect(sys.SetCursorImage(img, 3, 4), IsNil)
+		got, x, y := h.CursorImage()
+		c.Expect(got, Equals, image.Image(img))
+		c.Expect(x, Equals, 3)
+		c.Expect(y, Equals, 4)
+		sys.SetStandardCursor(system.CursorIBeam)
+		got, _, _ = h.CursorImage()
+		c.Expect(got, IsNil)
+		c.Expect(h.StandardCursor(), Equals, system.CursorIBeam)
+		c.Expect(system.CursorIBeam.String(), Equals, "i-beam")
+	})
+	c.Specify("Cursor hot spots must be inside the image.", func() {
+		img := image.NewRGBA(image.Rect(10, 10, 42, 42))
+		c.Expect(sys.SetCursorImage(img, 32, 0), Not(IsNil))
+		c.Expect(sys.SetCursorImage(img, -1, 0), Not(IsNil))
+		c.Expect(h.StandardCursor(), Equals, system.CursorArrow)
+		got, _, _ := h.CursorImage()
+		c.Expect(got, IsNil)
+		c.Expect(sys.SetCursorImage(img, 31, 31), IsNil)
+		c.Expect(sys.SetCursorImage(nil, 100, 100), IsNil)
+	})
+	c.Specify("Text can be copied and pasted.", func() {
+		c.Expect(sys.GetClipboardText(), Equals, "")
+		sys.SetClipboardText("héllo\nworld")
+		c.Expect(sys.GetClipboardText(), Equals, "héllo\nworld")
+	})
+}
@@ -5,9 +5,22 @@
 #include <algorithm>
 #include <cstdio>
 #include <stdio.h>
+#include <stdlib.h>
+#include <string.h>
+#include <locale.h>
+#include <wchar.h>
+#include <math.h>
 #include <sys/time.h>
+#include <unistd.h>
 
 #include <X11/Xlib.h>
+#include <X11/Xutil.h>
+#include <X11/Xatom.h>
+#include <X11/Xresource.h>
+#include <X11/cursorfont.h>
+#include <X11/Xcursor/Xcursor.h>
+#include <X11/extensions/Xrandr.h>
+#include <X11/extensions/XInput2.h>
 #include <GL/glx.h>
 
 using namespace std;
@@ -23,6 +36,22 @@ int screen = 0;
 XIM xim = NULL;
 Atom close_atom;
 
+// The clipboard is owned by an unmapped window, rather than the real one, so
+// that it works whether or not there is a window and survives DestroyWindow().
+Window selection_window;
+Atom clipboard_atom;
+Atom utf8_atom;
+Atom targets_atom;
+Atom paste_atom;
+
+// Opcode of the XInput2 extension, or -1 if it isn't available.  XInput2 is
+// used for unaccelerated mouse motion in relative mouse mode.
+int xi_opcode = -1;
+
+// True iff the XRandR extension is available, it is used to list monitors and
+// change display modes.
+bool have_xrandr = false;
+
 Display *get_x_display() { return display; }
 int get_x_screen() { return screen; }
 
@@ -31,16 +60,16 @@ static long long gtm() {
   gettimeofday(&tv, NULL);
   return (long long)tv.tv_sec * 1000000 + tv.tv_usec;
 }
-static int gt() {
-  int val = gtm() / 1000;
-  return val;
+// Milliseconds since the epoch, the same clock that evdev timestamps use.
+static long long gt() {
+  return gtm() / 1000;
 }
 
 struct OsWindowData {
   OsWindowData() { window = (Window)NULL; }
   ~OsWindowData() {
     glXDestroyContext(display, context);
-    XDestroyIC(inputcontext);
+    if (inputcontext) XDestroyIC(inputcontext);
     XDestroyWindow(display, window);
   }
   
@@ -58,10 +87,34 @@ void GlopInit() {
   
   screen = DefaultScreen(display);
   
+  // Input methods only work if the locale has been set, and this needs to
+  // happen before the IM is opened.
+  setlocale(LC_CTYPE, "");
+  XSetLocaleModifiers("");
   xim = XOpenIM(display, NULL, NULL, NULL);
 //  ASSERT(xim);
+
+  int xi_event, xi_error;
+  if (XQueryExtension(display, "XInputExtension", &xi_opcode, &xi_event, &xi_error)) {
+    int major = 2, minor = 0;
+    if (XIQueryVersion(display, &major, &minor) != Success)
+      xi_opcode = -1;
+  } else {
+    xi_opcode = -1;
+  }
   
+  int xrr_event, xrr_error;
+  have_xrandr = XRRQueryExtension(display, &xrr_event, &xrr_error);
+
+  XrmInitialize();
+
   close_atom = XInternAtom(display, "WM_DELETE_WINDOW", false);
+
+  selection_window = XCreateSimpleWindow(display, RootWindow(display, screen), 0, 0, 1, 1, 0, 0, 0);
+  clipboard_atom = XInternAtom(display, "CLIPBOARD", false);
+  utf8_atom = XInternAtom(display, "UTF8_STRING", false);
+  targets_atom = XInternAtom(display, "TARGETS", false);
+  paste_atom = XInternAtom(display, "GLOP_PASTE", false);
 }
 void glopShutDown() {
   XCloseIM(xim);
@@ -69,6 +122,121 @@ void glopShutDown() {
 }
 
 vector<GlopKeyEvent> events;
+vector<GlopTextEvent> text_events;
+vector<GlopWindowEvent> window_events;
+
+// The current pre-edit string if the input method is composing text.
+static wstring preedit;
+
+// The event owns its text, which is freed once the event has been handed off
+// by GlopGetTextEvents() and the next batch is requested.
+static void PushTextEvent(int type, const string& text, int cursor) {
+  GlopTextEvent ev;
+  memset(&ev, 0, sizeof(ev));
+  ev.type = type;
+  ev.cursor = cursor;
+  ev.timestamp = gt();
+  ev.text = strdup(text.c_str());
+  text_events.push_back(ev);
+}
+
+// wchar_t is UTF-32 on Linux, so this doesn't depend on the locale's encoding
+// the way wcstombs() does.
+static string WideToUtf8(const wstring& w) {
+  string ret;
+  for (size_t i = 0; i < w.size(); i++) {
+    unsigned long c = w[i];
+    if (c > 0x10FFFF || (c >= 0xD800 && c <= 0xDFFF)) {
+      c = 0xFFFD;
+    }
+    if (c < 0x80) {
+      ret += (char)c;
+    } else if (c < 0x800) {
+      ret += (char)(0xC0 | (c >> 6));
+      ret += (char)(0x80 | (c & 0x3F));
+    } else if (c < 0x10000) {
+      ret += (char)(0xE0 | (c >> 12));
+      ret += (char)(0x80 | ((c >> 6) & 0x3F));
+      ret += (char)(0x80 | (c & 0x3F));
+    } else {
+      ret += (char)(0xF0 | (c >> 18));
+      ret += (char)(0x80 | ((c >> 12) & 0x3F));
+      ret += (char)(0x80 | ((c >> 6) & 0x3F));
+      ret += (char)(0x80 | (c & 0x3F));
+    }
+  }
+  return ret;
+}
+
+static int PreeditStart(XIC ic, XPointer client_data, XPointer call_data) {
+  preedit.clear();
+  return -1;  // No limit on the length of the pre-edit string
+}
+
+static void PreeditDone(XIC ic, XPointer client_data, XPointer call_data) {
+  preedit.clear();
+  PushTextEvent(glopTextComposition, string(), 0);
+}
+
+static void PreeditDraw(XIC ic, XPointer client_data, XIMPreeditDrawCallbackStruct* call_data) {
+  int first = call_data->chg_first;
+  int length = call_data->chg_length;
+  if (first > (int)preedit.size()) first = preedit.size();
+  if (first + length > (int)preedit.size()) length = preedit.size() - first;
+  preedit.erase(first, length);
+  XIMText* text = call_data->text;
+  if (text != NULL) {
+    wstring insert;
+    if (text->encoding_is_wchar) {
+      if (text->string.wide_char != NULL) {
+        insert = wstring(text->string.wide_char, text->length);
+      }
+    } else if (text->string.multi_byte != NULL) {
+      insert.resize(text->length);
+      size_t n = mbstowcs(&insert[0], text->string.multi_byte, text->length);
+      insert.resize(n == (size_t)-1 ? 0 : n);
+    }
+    preedit.insert(first, insert);
+  }
+  PushTextEvent(glopTextComposition, WideToUtf8(preedit), call_data->caret);
+}
+
+static void PreeditCaret(XIC ic, XPointer client_data, XIMPreeditCaretCallbackStruct* call_data) {
+  if (call_data->direction == XIMAbsolutePosition) {
+    PushTextEvent(glopTextComposition, WideToUtf8(preedit), call_data->position);
+  }
+}
+
+// Gets the committed text, if any, for a key press that was not consumed by
+// the input method.
+static void SynthText(XEvent& event, XIC ic) {
+  char buf[64];
+  KeySym sym;
+  Status status;
+  int n = Xutf8LookupString(ic, &event.xkey, buf, sizeof(buf) - 1, &sym, &status);
+  string text;
+  if (status == XBufferOverflow) {
+    text.resize(n);
+    n = Xutf8LookupString(ic, &event.xkey, &text[0], n, &sym, &status);
+    text.resize(n);
+  } else {
+    text = string(buf, n);
+  }
+  if (status != XLookupChars && status != XLookupBoth) {
+    return;
+  }
+  // Control characters, like backspace, are handled as keys, not text.
+  string filtered;
+  for (size_t i = 0; i < text.size(); i++) {
+    unsigned char c = text[i];
+    if (c >= 0x20 && c != 0x7f) {
+      filtered += text[i];
+    }
+  }
+  if (!filtered.empty()) {
+    PushTextEvent(glopTextInput, filtered, 0);
+  }
+}
 static bool SynthKey(const KeySym &sym, bool pushed, const XEvent &event, Window window, GlopKeyEvent *ev) {
   // mostly ignored
   Window root, child;
@@ -226,7 +394,9 @@ static bool SynthButton(int button, bool pushed, const XEvent &event, Window win
   return true;
 }
 
-static bool SynthMotion(int dx, int dy, const XEvent &event, Window window, GlopKeyEvent *ev, GlopKeyEvent *ev2) {
+// The lock state comes from the pointer query rather than from the event, since
+// XI2 raw events don't have one.
+static bool SynthMotion(float dx, float dy, Window window, GlopKeyEvent *ev, GlopKeyEvent *ev2) {
   // mostly ignored
   Window root, child;
   int x, y, winx, winy;
@@ -238,16 +408,16 @@ static bool SynthMotion(int dx, int dy, const XEvent &event, Window window, Glop
   ev->timestamp = gt();
   ev->cursor_x = x;
   ev->cursor_y = y;
-  ev->num_lock = event.xkey.state & (1 << 4);
-  ev->caps_lock = event.xkey.state & LockMask;
+  ev->num_lock = mask & (1 << 4);
+  ev->caps_lock = mask & LockMask;
 
   ev2->index = kMouseYAxis;
   ev2->press_amt = dy;
   ev2->timestamp = ev->timestamp;
   ev2->cursor_x = x;
   ev2->cursor_y = y;
-  ev2->num_lock = event.xkey.state & (1 << 4);
-  ev2->caps_lock = event.xkey.state & LockMask;
+  ev2->num_lock = mask & (1 << 4);
+  ev2->caps_lock = mask & LockMask;
 
   return true;
 }
@@ -256,18 +426,209 @@ Bool EventTester(Display *display, XEvent *event, XPointer arg) {
   return true; // hurrr
 }
 OsWindowData *windowdata = NULL;
+
+// Set by FocusIn and FocusOut events on the window.
+static int has_focus = 0;
+
+// The window's dimensions as of the last window event, so that ConfigureNotify
+// events only turn into window events when something actually changed.
+static int last_x, last_y, last_dx, last_dy;
+
+// X doesn't say why a window was unmapped, but a window that we haven't
+// destroyed is only unmapped if it was minimized.
+static const int kNeverMapped = 0;
+static const int kMapped = 1;
+static const int kUnmapped = 2;
+static int map_state = kNeverMapped;
+
+static void PushWindowEvent(int type) {
+  GlopWindowEvent ev;
+  ev.type = type;
+  ev.x = last_x;
+  ev.y = last_y;
+  ev.dx = last_dx;
+  ev.dy = last_dy;
+  ev.timestamp = gt();
+  window_events.push_back(ev);
+}
+
+// While the cursor is hidden it is locked at lock_x, lock_y, in window
+// coordinates.  Every time it moves it is warped back there and the distance
+// it moved is reported.  In relative mode it is locked in the center of the
+// window and, if XInput2 is available, raw motion is reported instead.
+static bool cursor_hidden = false;
+static bool relative_mouse = false;
+static int lock_x, lock_y;
+static Cursor blank_cursor = None;
+
+// The cursor shown while the cursor isn't hidden, None for the default arrow.
+static Cursor current_cursor = None;
+
+// Last position of the cursor, used to turn motion into deltas while the
+// cursor isn't locked.
+static bool have_last_motion = false;
+static int last_motion_x, last_motion_y;
+
+static void UpdateCursor() {
+  if (!windowdata) return;
+  Window window = windowdata->window;
+  if (!cursor_hidden && !relative_mouse) {
+    XUngrabPointer(display, CurrentTime);
+    if (current_cursor != None)
+      XDefineCursor(display, window, current_cursor);
+    else
+      XUndefineCursor(display, window);
+    have_last_motion = false;
+    return;
+  }
+  if (blank_cursor == None) {
+    char data = 0;
+    XColor black;
+    memset(&black, 0, sizeof(black));
+    Pixmap pixmap = XCreateBitmapFromData(display, window, &data, 1, 1);
+    blank_cursor = XCreatePixmapCursor(display, pixmap, pixmap, &black, &black, 0, 0);
+    XFreePixmap(display, pixmap);
+  }
+  XDefineCursor(display, window, blank_cursor);
+  XGrabPointer(display, window, True,
+               ButtonPressMask | ButtonReleaseMask | PointerMotionMask,
+               GrabModeAsync, GrabModeAsync, window, blank_cursor, CurrentTime);
+  if (relative_mouse) {
+    XWindowAttributes attrs;
+    XGetWindowAttributes(display, window, &attrs);
+    lock_x = attrs.width / 2;
+    lock_y = attrs.height / 2;
+  } else {
+    Window root, child;
+    int root_x, root_y;
+    unsigned int mask;
+    XQueryPointer(display, window, &root, &child, &root_x, &root_y, &lock_x, &lock_y, &mask);
+  }
+  XWarpPointer(display, None, window, 0, 0, 0, 0, lock_x, lock_y);
+}
+
+// Raw motion events are only delivered to the root window.
+static void SelectRawMotion(bool enable) {
+  if (xi_opcode == -1) return;
+  unsigned char bits[XIMaskLen(XI_LASTEVENT)];
+  memset(bits, 0, sizeof(bits));
+  if (enable)
+    XISetMask(bits, XI_RawMotion);
+  XIEventMask mask;
+  mask.deviceid = XIAllMasterDevices;
+  mask.mask_len = sizeof(bits);
+  mask.mask = bits;
+  XISelectEvents(display, DefaultRootWindow(display), &mask, 1);
+}
+
+void GlopHideCursor(int hide) {
+  cursor_hidden = hide;
+  UpdateCursor();
+}
+
+void GlopSetRelativeMouseMode(int enable) {
+  relative_mouse = enable;
+  SelectRawMotion(relative_mouse);
+  UpdateCursor();
+}
+
 Window get_x_window() {
 //  ASSERT(windowdata);
   return windowdata->window;
 }
+// Clipboard functions
+// ===================
+
+// X doesn't store the clipboard anywhere.  Whoever owns the CLIPBOARD selection
+// hands its contents to anyone that asks, so the text is gone once we exit.
+// Text too big for a single request, which needs the INCR protocol, isn't
+// supported.
+static string clipboard_text;
+
+static void HandleSelectionRequest(const XSelectionRequestEvent& request) {
+  XEvent reply;
+  memset(&reply, 0, sizeof(reply));
+  reply.xselection.type = SelectionNotify;
+  reply.xselection.display = request.display;
+  reply.xselection.requestor = request.requestor;
+  reply.xselection.selection = request.selection;
+  reply.xselection.target = request.target;
+  reply.xselection.time = request.time;
+  reply.xselection.property = None;
+
+  // Obsolete clients don't specify a property.
+  Atom property = request.property != None ? request.property : request.target;
+  if (request.selection == clipboard_atom && request.target == targets_atom) {
+    Atom targets[] = {targets_atom, utf8_atom};
+    XChangeProperty(display, request.requestor, property, XA_ATOM, 32, PropModeReplace,
+                    (unsigned char*)targets, 2);
+    reply.xselection.property = property;
+  } else if (request.selection == clipboard_atom && request.target == utf8_atom) {
+    XChangeProperty(display, request.requestor, property, utf8_atom, 8, PropModeReplace,
+                    (const unsigned char*)clipboard_text.data(), clipboard_text.size());
+    reply.xselection.property = property;
+  }
+  XSendEvent(display, request.requestor, False, NoEventMask, &reply);
+}
+
+void GlopSetClipboardText(const char* text) {
+  clipboard_text = text;
+  XSetSelectionOwner(display, clipboard_atom, selection_window, CurrentTime);
+  XFlush(display);
+}
+
+// The returned string is valid until the next call.
+const char* GlopGetClipboardText() {
+  static string pasted;
+  Window owner = XGetSelectionOwner(display, clipboard_atom);
+  if (owner == selection_window) return clipboard_text.c_str();
+  pasted.clear();
+  if (owner == None) return pasted.c_str();
+
+  XConvertSelection(display, clipboard_atom, utf8_atom, paste_atom, selection_window, CurrentTime);
+  XFlush(display);
+  XEvent event;
+  long long give_up = gt() + 1000;
+  while (!XCheckTypedWindowEvent(display, selection_window, SelectionNotify, &event)) {
+    if (gt() > give_up) return pasted.c_str();
+    usleep(1000);
+  }
+  if (event.xselection.property == None) return pasted.c_str();
+
+  Atom type;
+  int format;
+  unsigned long count, remaining;
+  unsigned char* data = NULL;
+  if (XGetWindowProperty(display, selection_window, paste_atom, 0, 1 << 24, True, AnyPropertyType,
+                         &type, &format, &count, &remaining, &data) == Success && data) {
+    if (type == utf8_atom || type == XA_STRING)
+      pasted.assign((char*)data, count);
+    XFree(data);
+  }
+  return pasted.c_str();
+}
+
 void GlopThink() {
-  if(!windowdata) return;
+  if(!windowdata) {
+    // Without a window the only events that matter are for the clipboard.
+    XEvent event;
+    while(XCheckTypedWindowEvent(display, selection_window, SelectionRequest, &event))
+      HandleSelectionRequest(event.xselectionrequest);
+    return;
+  }
   
   OsWindowData *data = windowdata;
   XEvent event;
   int last_botched_release = -1;
   int last_botched_time = -1;
   while(XCheckIfEvent(display, &event, &EventTester, NULL)) {
+    // The input method has to see every event, since it talks to the input
+    // method server with events of its own.  Events that it uses are ignored,
+    // except for key events, which still count as keys but don't produce text.
+    bool filtered = XFilterEvent(&event, None);
+    if (filtered && event.type != KeyPress && event.type != KeyRelease)
+      continue;
+
     if((event.type == KeyPress || event.type == KeyRelease) && event.xkey.keycode < 256) {
       // X is kind of a cock and likes to send us hardware repeat messages for people holding buttons down. Why do you do this, X? Why do you have to make me hate you?
       
@@ -284,6 +645,9 @@ void GlopThink() {
             // ffffffffff
             last_botched_release = -1;
             last_botched_time = -1;
+            // Repeats aren't key presses, but they do type more text.
+            if(data->inputcontext && !filtered)
+              SynthText(event, data->inputcontext);
             continue;
           }
         }
@@ -305,6 +669,11 @@ void GlopThink() {
         
         if(SynthKey(sym, true, event, data->window, &ev))
           events.push_back(ev);
+
+        // If the input method uses this key press for composition it won't
+        // produce any text itself.
+        if(data->inputcontext && !filtered)
+          SynthText(event, data->inputcontext);
         break;
       }
       
@@ -330,21 +699,112 @@ void GlopThink() {
           events.push_back(ev);
         break;
 
-      case MotionNotify:
+      case MotionNotify: {
+        int x = event.xmotion.x;
+        int y = event.xmotion.y;
+        int dx, dy;
+        if (cursor_hidden || relative_mouse) {
+          // This is either the motion from our own warp, or motion that raw
+          // events already report.
+          if ((x == lock_x && y == lock_y) || (relative_mouse && xi_opcode != -1)) {
+            if (x != lock_x || y != lock_y)
+              XWarpPointer(display, None, data->window, 0, 0, 0, 0, lock_x, lock_y);
+            break;
+          }
+          dx = x - lock_x;
+          dy = y - lock_y;
+          XWarpPointer(display, None, data->window, 0, 0, 0, 0, lock_x, lock_y);
+        } else {
+          if (!have_last_motion) {
+            last_motion_x = x;
+            last_motion_y = y;
+            have_last_motion = true;
+            break;
+          }
+          dx = x - last_motion_x;
+          dy = y - last_motion_y;
+          last_motion_x = x;
+          last_motion_y = y;
+        }
         GlopKeyEvent ev2;
         GlopClearKeyEvent(&ev2);
-        if(SynthMotion(event.xmotion.x, event.xmotion.y, event, data->window, &ev, &ev2)) {
+        if(SynthMotion(dx, dy, data->window, &ev, &ev2)) {
           events.push_back(ev);
           events.push_back(ev2);
         }
         break;
+      }
+
+      case GenericEvent:
+        if (event.xcookie.extension == xi_opcode && XGetEventData(display, &event.xcookie)) {
+          if (event.xcookie.evtype == XI_RawMotion && relative_mouse && has_focus) {
+            // raw_values only holds the valuators that are set in the mask,
+            // valuators 0 and 1 are x and y.
+            XIRawEvent* raw = (XIRawEvent*)event.xcookie.data;
+            double delta[2] = {0, 0};
+            int value = 0;
+            for (int i = 0; i < 2 && i < raw->valuators.mask_len * 8; i++) {
+              if (XIMaskIsSet(raw->valuators.mask, i)) {
+                delta[i] = raw->raw_values[value];
+                value++;
+              }
+            }
+            GlopKeyEvent ev2;
+            GlopClearKeyEvent(&ev2);
+            if ((delta[0] != 0 || delta[1] != 0) &&
+                SynthMotion(delta[0], delta[1], data->window, &ev, &ev2)) {
+              events.push_back(ev);
+              events.push_back(ev2);
+            }
+          }
+          XFreeEventData(display, &event.xcookie);
+        }
+        break;
       
       case FocusIn:
-        XSetICFocus(data->inputcontext);
+        if (!has_focus)
+          PushWindowEvent(glopWindowFocusGained);
+        has_focus = 1;
+        if(data->inputcontext)
+          XSetICFocus(data->inputcontext);
         break;
       
       case FocusOut:
-        XUnsetICFocus(data->inputcontext);
+        if (has_focus)
+          PushWindowEvent(glopWindowFocusLost);
+        has_focus = 0;
+        if(data->inputcontext)
+          XUnsetICFocus(data->inputcontext);
+        break;
+
+      case ConfigureNotify: {
+        // The position in the event is relative to the window manager's frame,
+        // so look the position up instead.
+        int x, y, dx, dy;
+        GlopGetWindowDims(&x, &y, &dx, &dy);
+        bool moved = (x != last_x || y != last_y);
+        bool resized = (dx != last_dx || dy != last_dy);
+        last_x = x;
+        last_y = y;
+        last_dx = dx;
+        last_dy = dy;
+        if (moved)
+          PushWindowEvent(glopWindowMoved);
+        if (resized)
+          PushWindowEvent(glopWindowResized);
+        break;
+      }
+
+      case MapNotify:
+        if (map_state == kUnmapped)
+          PushWindowEvent(glopWindowRestored);
+        map_state = kMapped;
+        break;
+
+      case UnmapNotify:
+        if (map_state == kMapped)
+          PushWindowEvent(glopWindowMinimized);
+        map_state = kUnmapped;
         break;
       
       case DestroyNotify:
@@ -353,18 +813,103 @@ void GlopThink() {
 //        LOGF("destroed\n");
         return;
     
+      case SelectionRequest:
+        HandleSelectionRequest(event.xselectionrequest);
+        break;
+
       case ClientMessage :
-        if(event.xclient.format == 32 && event.xclient.data.l[0] == static_cast<long>(close_atom)) {
-//            WindowDashDestroy();
-//            LOGF("destroj\n");
-            return;
-        }
+        // The window is left alone, it is up to the app to destroy it.
+        if(event.xclient.format == 32 && event.xclient.data.l[0] == static_cast<long>(close_atom))
+          PushWindowEvent(glopWindowCloseRequested);
+        break;
     }
   }
 }
 
 void GlopSetTitle(OsWindowData* data, const string& title) {
   XStoreName(display, data->window, title.c_str());
+  // XStoreName() is only for Latin-1, window managers use this instead.
+  XChangeProperty(display, data->window, XInternAtom(display, "_NET_WM_NAME", false), utf8_atom, 8,
+                  PropModeReplace, (const unsigned char*)title.data(), title.size());
+}
+
+void GlopSetWindowTitle(const char* title) {
+  if (!windowdata) return;
+  GlopSetTitle(windowdata, string(title));
+  XFlush(display);
+}
+
+// _NET_WM_ICON is the width, the height, and then the pixels.  Format 32
+// properties are passed as longs, whatever size those are.
+static vector<unsigned long> icon_data;
+
+static void ApplyIcon(Window window) {
+  Atom icon_atom = XInternAtom(display, "_NET_WM_ICON", false);
+  if (icon_data.empty()) {
+    XDeleteProperty(display, window, icon_atom);
+  } else {
+    XChangeProperty(display, window, icon_atom, XA_CARDINAL, 32, PropModeReplace,
+                    (unsigned char*)&icon_data[0], icon_data.size());
+  }
+}
+
+// argb is width * height non-premultiplied pixels, or NULL to remove the icon.
+void GlopSetWindowIcon(int width, int height, const unsigned int* argb) {
+  icon_data.clear();
+  if (argb) {
+    icon_data.push_back(width);
+    icon_data.push_back(height);
+    for (int i = 0; i < width * height; i++)
+      icon_data.push_back(argb[i]);
+  }
+  if (!windowdata) return;
+  ApplyIcon(windowdata->window);
+  XFlush(display);
+}
+
+static void SetCurrentCursor(Cursor cursor) {
+  if (current_cursor != None) XFreeCursor(display, current_cursor);
+  current_cursor = cursor;
+  // While the cursor is hidden UpdateCursor() puts it back when it is shown.
+  if (!windowdata || cursor_hidden || relative_mouse) return;
+  if (current_cursor != None)
+    XDefineCursor(display, windowdata->window, current_cursor);
+  else
+    XUndefineCursor(display, windowdata->window);
+  XFlush(display);
+}
+
+// argb is width * height premultiplied pixels, or NULL for the default cursor.
+void GlopSetCursorImage(int width, int height, const unsigned int* argb, int hot_x, int hot_y) {
+  if (!argb) {
+    SetCurrentCursor(None);
+    return;
+  }
+  XcursorImage* image = XcursorImageCreate(width, height);
+  image->xhot = hot_x;
+  image->yhot = hot_y;
+  for (int i = 0; i < width * height; i++)
+    image->pixels[i] = argb[i];
+  SetCurrentCursor(XcursorImageLoadCursor(display, image));
+  XcursorImageDestroy(image);
+}
+
+void GlopSetStandardCursor(int cursor) {
+  unsigned int shape;
+  switch (cursor) {
+    case glopCursorIBeam: shape = XC_xterm; break;
+    case glopCursorCrosshair: shape = XC_crosshair; break;
+    case glopCursorHand: shape = XC_hand2; break;
+    case glopCursorWait: shape = XC_watch; break;
+    case glopCursorResizeHorizontal: shape = XC_sb_h_double_arrow; break;
+    case glopCursorResizeVertical: shape = XC_sb_v_double_arrow; break;
+    case glopCursorResizeAll: shape = XC_fleur; break;
+    case glopCursorNotAllowed: shape = XC_X_cursor; break;
+    default:
+      SetCurrentCursor(None);
+      return;
+  }
+  SetCurrentCursor(XCreateFontCursor(display, shape));
 }
 
 void glopSetCurrentContext(OsWindowData* data) {
@@ -475,13 +1020,59 @@ void* GlopCreateWindow(void* title, int x, int y, int width, int height) {
   }
 
   GlopSetTitle(nw, string((char*)(title)));
+  ApplyIcon(nw->window);
+  if (current_cursor != None)
+    XDefineCursor(display, nw->window, current_cursor);
   
   XSetWMProtocols(display, nw->window, &close_atom, 1);
   // I think in here is where we're meant to set window styles and stuff
   
-  nw->inputcontext = XCreateIC(xim, XNInputStyle, XIMPreeditNothing | XIMStatusNothing, XNClientWindow, nw->window, XNFocusWindow, nw->window, NULL);
-//  ASSERT(nw->inputcontext);
+  nw->inputcontext = NULL;
+  if (xim) {
+    // Use on-the-spot pre-editing if the input method supports it so that the
+    // text being composed can be drawn by the app.
+    XIMStyles* styles = NULL;
+    bool callbacks = false;
+    if (XGetIMValues(xim, XNQueryInputStyle, &styles, NULL) == NULL && styles) {
+      for (int i = 0; i < styles->count_styles; i++) {
+        if (styles->supported_styles[i] == (XIMPreeditCallbacks | XIMStatusNothing)) {
+          callbacks = true;
+        }
+      }
+      XFree(styles);
+    }
+    if (callbacks) {
+      static XIMCallback start, done, draw, caret;
+      start.callback = (XIMProc)PreeditStart;
+      done.callback = (XIMProc)PreeditDone;
+      draw.callback = (XIMProc)PreeditDraw;
+      caret.callback = (XIMProc)PreeditCaret;
+      start.client_data = done.client_data = draw.client_data = caret.client_data = NULL;
+      XVaNestedList preedit_attribs = XVaCreateNestedList(0,
+          XNPreeditStartCallback, &start,
+          XNPreeditDoneCallback, &done,
+          XNPreeditDrawCallback, &draw,
+          XNPreeditCaretCallback, &caret,
+          NULL);
+      nw->inputcontext = XCreateIC(xim, XNInputStyle, XIMPreeditCallbacks | XIMStatusNothing, XNClientWindow, nw->window, XNFocusWindow, nw->window, XNPreeditAttributes, preedit_attribs, NULL);
+      XFree(preedit_attribs);
+    }
+    if (!nw->inputcontext) {
+      nw->inputcontext = XCreateIC(xim, XNInputStyle, XIMPreeditNothing | XIMStatusNothing, XNClientWindow, nw->window, XNFocusWindow, nw->window, NULL);
+    }
+  }
+  if (nw->inputcontext) {
+    // The input method may need to see events that we don't otherwise ask for.
+    unsigned long filter_events = 0;
+    XGetICValues(nw->inputcontext, XNFilterEvents, &filter_events, NULL);
+    XSelectInput(display, nw->window, attribs.event_mask | filter_events);
+  }
   
+  last_x = x;
+  last_y = y;
+  last_dx = width;
+  last_dy = height;
+  map_state = kNeverMapped;
   XMapWindow(display, nw->window);
   
   nw->context = glXCreateContext(display, vinfo, NULL, True);
@@ -498,6 +1089,39 @@ void glopDestroyWindow(OsWindowData* data) {
   delete data;
 }
 
+static void RestoreCrtcMode();
+static int window_mode = glopWindowed;
+
+static Bool IsWindowEvent(Display* display, XEvent* event, XPointer arg) {
+  return event->type != GenericEvent && event->xany.window == *(Window*)arg;
+}
+
+void GlopDestroyWindow() {
+  if (!windowdata) return;
+  Window window = windowdata->window;
+  RestoreCrtcMode();
+  window_mode = glopWindowed;
+  if (cursor_hidden || relative_mouse)
+    XUngrabPointer(display, CurrentTime);
+  glXMakeCurrent(display, None, NULL);
+  glopDestroyWindow(windowdata);
+  windowdata = NULL;
+  has_focus = 0;
+  have_last_motion = false;
+
+  // Throw away everything that is still queued up for the old window, but
+  // nothing else, since the selection window might have requests waiting.
+  XSync(display, False);
+  XEvent event;
+  while (XCheckIfEvent(display, &event, &IsWindowEvent, (XPointer)&window)) {}
+  events.clear();
+  for (int i = 0; i < text_events.size(); i++) {
+    free(text_events[i].text);
+  }
+  text_events.clear();
+  window_events.clear();
+}
+
 void glopGetWindowFocusState(OsWindowData* data, bool* is_in_focus, bool* focus_changed) {
   *is_in_focus = true;
   *focus_changed = false;
@@ -530,10 +1154,271 @@ void glopGetWindowSize(const OsWindowData* data, int* width, int* height) {
 }
 
 void GlopGetWindowDims(int* x, int* y, int* dx, int* dy) {
+  if (!windowdata) {
+    *x = *y = *dx = *dy = 0;
+    return;
+  }
   glopGetWindowPosition(windowdata, x, y);
   glopGetWindowSize(windowdata, dx, dy);
 }
 
+void GlopGetScreenSize(int* width, int* height) {
+  *width = DisplayWidth(display, screen);
+  *height = DisplayHeight(display, screen);
+}
+
+
+// Monitor functions
+// =================
+
+static double ModeRefresh(const XRRModeInfo* info) {
+  double v_total = info->vTotal;
+  if (info->modeFlags & RR_DoubleScan) v_total *= 2;
+  if (info->modeFlags & RR_Interlace) v_total /= 2;
+  if (info->hTotal == 0 || v_total == 0) return 0;
+  return info->dotClock / (info->hTotal * v_total);
+}
+
+static const XRRModeInfo* FindModeInfo(XRRScreenResources* res, RRMode id) {
+  for (int i = 0; i < res->nmode; i++) {
+    if (res->modes[i].id == id) return &res->modes[i];
+  }
+  return NULL;
+}
+
+// Returns the outputs that have a monitor attached and turned on, with the
+// primary output first.  This is the order that GlopGetMonitors() uses, so
+// monitor indexes are indexes into this.
+static vector<RROutput> ActiveOutputs(XRRScreenResources* res) {
+  RROutput primary = XRRGetOutputPrimary(display, RootWindow(display, screen));
+  vector<RROutput> ret;
+  for (int i = 0; i < res->noutput; i++) {
+    XRROutputInfo* info = XRRGetOutputInfo(display, res, res->outputs[i]);
+    if (info->connection == RR_Connected && info->crtc != None) {
+      if (res->outputs[i] == primary) {
+        ret.insert(ret.begin(), res->outputs[i]);
+      } else {
+        ret.push_back(res->outputs[i]);
+      }
+    }
+    XRRFreeOutputInfo(info);
+  }
+  return ret;
+}
+
+static vector<GlopMonitor> glop_monitor_buffer;
+static vector<GlopDisplayMode> glop_mode_buffer;
+
+void GlopGetMonitors(void** _monitors_ret, void* _num_monitors, void** _modes_ret, void* _num_modes) {
+  glop_monitor_buffer.clear();
+  glop_mode_buffer.clear();
+  if (!have_xrandr) {
+    // Without XRandR all we know about is the screen as a whole.
+    GlopMonitor monitor;
+    memset(&monitor, 0, sizeof(monitor));
+    strncpy(monitor.name, "default", sizeof(monitor.name) - 1);
+    GlopGetScreenSize(&monitor.dx, &monitor.dy);
+    monitor.primary = 1;
+    monitor.current.width = monitor.dx;
+    monitor.current.height = monitor.dy;
+    monitor.num_modes = 1;
+    glop_monitor_buffer.push_back(monitor);
+    glop_mode_buffer.push_back(monitor.current);
+  } else {
+    XRRScreenResources* res = XRRGetScreenResourcesCurrent(display, RootWindow(display, screen));
+    vector<RROutput> outputs = ActiveOutputs(res);
+    for (int i = 0; i < outputs.size(); i++) {
+      XRROutputInfo* info = XRRGetOutputInfo(display, res, outputs[i]);
+      XRRCrtcInfo* crtc = XRRGetCrtcInfo(display, res, info->crtc);
+      GlopMonitor monitor;
+      memset(&monitor, 0, sizeof(monitor));
+      strncpy(monitor.name, info->name, sizeof(monitor.name) - 1);
+      monitor.x = crtc->x;
+      monitor.y = crtc->y;
+      monitor.dx = crtc->width;
+      monitor.dy = crtc->height;
+      monitor.primary = (i == 0);
+      monitor.current.width = crtc->width;
+      monitor.current.height = crtc->height;
+      const XRRModeInfo* current = FindModeInfo(res, crtc->mode);
+      if (current) monitor.current.refresh = ModeRefresh(current);
+      monitor.first_mode = glop_mode_buffer.size();
+      for (int j = 0; j < info->nmode; j++) {
+        const XRRModeInfo* mode_info = FindModeInfo(res, info->modes[j]);
+        if (!mode_info) continue;
+        GlopDisplayMode mode;
+        mode.width = mode_info->width;
+        mode.height = mode_info->height;
+        mode.refresh = ModeRefresh(mode_info);
+        glop_mode_buffer.push_back(mode);
+        monitor.num_modes++;
+      }
+      glop_monitor_buffer.push_back(monitor);
+      XRRFreeCrtcInfo(crtc);
+      XRRFreeOutputInfo(info);
+    }
+    XRRFreeScreenResources(res);
+  }
+  *((GlopMonitor**)_monitors_ret) = glop_monitor_buffer.empty() ? NULL : &glop_monitor_buffer[0];
+  *((int*)_num_monitors) = glop_monitor_buffer.size();
+  *((GlopDisplayMode**)_modes_ret) = glop_mode_buffer.empty() ? NULL : &glop_mode_buffer[0];
+  *((int*)_num_modes) = glop_mode_buffer.size();
+}
+
+// While the window is fullscreen these are the dimensions that it had before
+// it went fullscreen.  While it is exclusive fullscreen changed_crtc is the
+// crtc whose mode was changed, and saved_crtc_mode is the mode that it had.
+static int windowed_x, windowed_y, windowed_dx, windowed_dy;
+static RRCrtc changed_crtc = None;
+static RRMode saved_crtc_mode = None;
+
+static void RestoreCrtcMode() {
+  if (changed_crtc == None) return;
+  XRRScreenResources* res = XRRGetScreenResourcesCurrent(display, RootWindow(display, screen));
+  XRRCrtcInfo* crtc = XRRGetCrtcInfo(display, res, changed_crtc);
+  XRRSetCrtcConfig(display, res, changed_crtc, CurrentTime, crtc->x, crtc->y, saved_crtc_mode,
+                   crtc->rotation, crtc->outputs, crtc->noutput);
+  XRRFreeCrtcInfo(crtc);
+  XRRFreeScreenResources(res);
+  changed_crtc = None;
+}
+
+// Windows are created with size hints that keep them from being resized, so
+// the hints have to change along with the size.
+static void SetFixedSize(Window window, int dx, int dy) {
+  XSizeHints hints;
+  hints.flags = PMinSize | PMaxSize;
+  hints.min_width = hints.max_width = dx;
+  hints.min_height = hints.max_height = dy;
+  XSetWMNormalHints(display, window, &hints);
+}
+
+// Asks the window manager to add or remove the fullscreen state, which takes
+// away the window's decorations and keeps it above panels and docks.
+static void SetFullscreenState(Window window, bool fullscreen) {
+  XEvent event;
+  memset(&event, 0, sizeof(event));
+  event.type = ClientMessage;
+  event.xclient.window = window;
+  event.xclient.message_type = XInternAtom(display, "_NET_WM_STATE", False);
+  event.xclient.format = 32;
+  event.xclient.data.l[0] = fullscreen ? 1 : 0;  // _NET_WM_STATE_ADD or _NET_WM_STATE_REMOVE
+  event.xclient.data.l[1] = XInternAtom(display, "_NET_WM_STATE_FULLSCREEN", False);
+  event.xclient.data.l[3] = 1;  // A normal application
+  XSendEvent(display, RootWindow(display, screen), False,
+             SubstructureRedirectMask | SubstructureNotifyMask, &event);
+}
+
+// Returns 0 on success.  monitor, width, height and refresh are only used by
+// the modes that need them, and the caller has already checked them against
+// GlopGetMonitors().
+int GlopSetWindowMode(int mode, int monitor, int width, int height, double refresh) {
+  if (!windowdata) return 1;
+  Window window = windowdata->window;
+  if (mode == glopWindowed) {
+    RestoreCrtcMode();
+    if (window_mode != glopWindowed) {
+      SetFullscreenState(window, false);
+      SetFixedSize(window, windowed_dx, windowed_dy);
+      XMoveResizeWindow(display, window, windowed_x, windowed_y, windowed_dx, windowed_dy);
+    }
+    window_mode = mode;
+    XFlush(display);
+    return 0;
+  }
+
+  int x, y, dx, dy;
+  if (!have_xrandr) {
+    if (mode == glopExclusiveFullscreen || monitor != 0) return 1;
+    x = y = 0;
+    GlopGetScreenSize(&dx, &dy);
+  } else {
+    XRRScreenResources* res = XRRGetScreenResourcesCurrent(display, RootWindow(display, screen));
+    vector<RROutput> outputs = ActiveOutputs(res);
+    if (monitor < 0 || monitor >= outputs.size()) {
+      XRRFreeScreenResources(res);
+      return 1;
+    }
+    XRROutputInfo* info = XRRGetOutputInfo(display, res, outputs[monitor]);
+    RRCrtc crtc_id = info->crtc;
+    RRMode mode_id = None;
+    for (int i = 0; mode == glopExclusiveFullscreen && i < info->nmode; i++) {
+      const XRRModeInfo* mode_info = FindModeInfo(res, info->modes[i]);
+      if (mode_info && mode_info->width == width && mode_info->height == height &&
+          fabs(ModeRefresh(mode_info) - refresh) < 0.01) {
+        mode_id = mode_info->id;
+        break;
+      }
+    }
+    XRRFreeOutputInfo(info);
+    if (mode == glopExclusiveFullscreen && mode_id == None) {
+      XRRFreeScreenResources(res);
+      return 1;
+    }
+
+    // Only one crtc at a time has its mode changed, put back the old one
+    // before changing a new one.
+    if (changed_crtc != None && (mode != glopExclusiveFullscreen || changed_crtc != crtc_id)) {
+      RestoreCrtcMode();
+      XRRFreeScreenResources(res);
+      res = XRRGetScreenResourcesCurrent(display, RootWindow(display, screen));
+    }
+    XRRCrtcInfo* crtc = XRRGetCrtcInfo(display, res, crtc_id);
+    x = crtc->x;
+    y = crtc->y;
+    dx = crtc->width;
+    dy = crtc->height;
+    if (mode == glopExclusiveFullscreen) {
+      if (changed_crtc == None) saved_crtc_mode = crtc->mode;
+      Status status = XRRSetCrtcConfig(display, res, crtc_id, CurrentTime, crtc->x, crtc->y, mode_id,
+                                       crtc->rotation, crtc->outputs, crtc->noutput);
+      if (status != Success) {
+        XRRFreeCrtcInfo(crtc);
+        XRRFreeScreenResources(res);
+        RestoreCrtcMode();
+        return 1;
+      }
+      changed_crtc = crtc_id;
+      dx = width;
+      dy = height;
+    }
+    XRRFreeCrtcInfo(crtc);
+    XRRFreeScreenResources(res);
+  }
+
+  if (window_mode == glopWindowed) {
+    GlopGetWindowDims(&windowed_x, &windowed_y, &windowed_dx, &windowed_dy);
+  }
+  // The window manager puts a fullscreen window on the monitor that it is on,
+  // so move it there first.
+  SetFixedSize(window, dx, dy);
+  XMoveResizeWindow(display, window, x, y, dx, dy);
+  SetFullscreenState(window, true);
+  window_mode = mode;
+  XFlush(display);
+  return 0;
+}
+
+int GlopGetWindowMode() {
+  return window_mode;
+}
+
+// X has no per-monitor scale, instead desktops set Xft.dpi for everything.
+double GlopGetContentScale() {
+  double scale = 1;
+  char* resources = XResourceManagerString(display);
+  if (!resources) return scale;
+  XrmDatabase db = XrmGetStringDatabase(resources);
+  char* type = NULL;
+  XrmValue value;
+  if (XrmGetResource(db, "Xft.dpi", "Xft.Dpi", &type, &value) && value.addr) {
+    double dpi = atof(value.addr);
+    if (dpi > 0) scale = dpi / 96;
+  }
+  XrmDestroyDatabase(db);
+  return scale;
+}
+
 
 // Input functions
 // ===============
@@ -559,10 +1444,55 @@ void GlopGetInputEvents(void** _events_ret, void* _num_events, void* _horizon) {
   }
 }
 
+static GlopTextEvent* glop_text_event_buffer = 0;
+static int glop_text_event_buffer_size = 0;
+
+void GlopGetTextEvents(void** _events_ret, void* _num_events) {
+  vector<GlopTextEvent> ret;
+  ret.swap(text_events);
+
+  if (glop_text_event_buffer != 0) {
+    for (int i = 0; i < glop_text_event_buffer_size; i++) {
+      free(glop_text_event_buffer[i].text);
+    }
+    free(glop_text_event_buffer);
+  }
+  glop_text_event_buffer_size = ret.size();
+
+  glop_text_event_buffer = (GlopTextEvent*)malloc(sizeof(GlopTextEvent) * ret.size());
+  *((GlopTextEvent**)_events_ret) = glop_text_event_buffer;
+  *((int*)_num_events) = ret.size();
+  for (int i = 0; i < ret.size(); i++) {
+    glop_text_event_buffer[i] = ret[i];
+  }
+}
+
+static GlopWindowEvent* glop_window_event_buffer = 0;
+
+void GlopGetWindowEvents(void** _events_ret, void* _num_events) {
+  vector<GlopWindowEvent> ret;
+  ret.swap(window_events);
+
+  if (glop_window_event_buffer != 0) {
+    free(glop_window_event_buffer);
+  }
+
+  glop_window_event_buffer = (GlopWindowEvent*)malloc(sizeof(GlopWindowEvent) * ret.size());
+  *((GlopWindowEvent**)_events_ret) = glop_window_event_buffer;
+  *((int*)_num_events) = ret.size();
+  for (int i = 0; i < ret.size(); i++) {
+    glop_window_event_buffer[i] = ret[i];
+  }
+}
+
 void GlopGetMousePosition(int* x, int* y) { // TBI
   Window root, child;
   int childx, childy;
   unsigned int mods;
+  if (!windowdata) {
+    *x = *y = 0;
+    return;
+  }
   XQueryPointer(display, windowdata->window, &root, &child, x, y, &childx, &childy, &mods);
 }
 
@@ -583,9 +1513,14 @@ long long glopGetTimeMicro() {
 
 
 void GlopSwapBuffers() {
+  if (!windowdata) return;
   glXSwapBuffers(display, windowdata->window);
 }
 
+int GlopHasFocus() {
+  return has_focus;
+}
+
 void GlopEnableVSync(int enable) {
   // TODO: Implement
 }
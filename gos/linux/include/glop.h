@@ -101,6 +101,68 @@ void GlopClearKeyEvent(GlopKeyEvent* event) {
   event->caps_lock = 0;
 }
 
+#define glopTextInput  0
+#define glopTextComposition  1
+
+// text is UTF-8 encoded, and is only valid until the next call to
+// GlopGetTextEvents().  cursor is only used for composition events, it is
+// the position of the cursor within the pre-edit string, in characters.
+typedef struct {
+  int type;
+  int cursor;
+  long long timestamp;
+  char* text;
+} GlopTextEvent;
+
+// These match system.WindowEventType.
+#define glopWindowCloseRequested  0
+#define glopWindowResized  1
+#define glopWindowMoved  2
+#define glopWindowFocusGained  3
+#define glopWindowFocusLost  4
+#define glopWindowMinimized  5
+#define glopWindowRestored  6
+
+// x, y, dx and dy are the window's dimensions after the event, as returned by
+// GlopGetWindowDims().
+typedef struct {
+  int type;
+  int x, y, dx, dy;
+  long long timestamp;
+} GlopWindowEvent;
+
+typedef struct {
+  int width, height;
+  double refresh;
+} GlopDisplayMode;
+
+// A monitor's modes are modes[first_mode] through modes[first_mode + num_modes
+// - 1] of the modes returned along with it by GlopGetMonitors().
+typedef struct {
+  char name[64];
+  int x, y, dx, dy;
+  int primary;
+  GlopDisplayMode current;
+  int first_mode;
+  int num_modes;
+} GlopMonitor;
+
+// These match system.WindowMode.
+#define glopWindowed  0
+#define glopBorderlessFullscreen  1
+#define glopExclusiveFullscreen  2
+
+// These match system.StandardCursor.
+#define glopCursorArrow  0
+#define glopCursorIBeam  1
+#define glopCursorCrosshair  2
+#define glopCursorHand  3
+#define glopCursorWait  4
+#define glopCursorResizeHorizontal  5
+#define glopCursorResizeVertical  6
+#define glopCursorResizeAll  7
+#define glopCursorNotAllowed  8
+
 void GlopInit();
 void* GlopCreateWindow(
     void* title,
@@ -108,13 +170,30 @@ void* GlopCreateWindow(
     int y,
     int width,
     int height);
+void GlopDestroyWindow();
 void GlopThink();
 void GlopSwapBuffers();
 
 void GlopGetMousePosition(int* x, int* y);
 void GlopGetWindowDims(int* x, int* y, int* dx, int* dy);
+void GlopGetScreenSize(int* width, int* height);
+void GlopGetMonitors(void** _monitors_ret, void* _num_monitors, void** _modes_ret, void* _num_modes);
+int GlopSetWindowMode(int mode, int monitor, int width, int height, double refresh);
+int GlopGetWindowMode();
+double GlopGetContentScale();
 void GlopGetInputEvents(void** _events_ret, void* _num_events, void* _horizon);
+void GlopGetTextEvents(void** _events_ret, void* _num_events);
+void GlopGetWindowEvents(void** _events_ret, void* _num_events);
 void GlopEnableVSync(int enable);
+int GlopHasFocus();
+void GlopHideCursor(int hide);
+void GlopSetWindowTitle(const char* title);
+void GlopSetWindowIcon(int width, int height, const unsigned int* argb);
+void GlopSetCursorImage(int width, int height, const unsigned int* argb, int hot_x, int hot_y);
+void GlopSetStandardCursor(int cursor);
+void GlopSetClipboardText(const char* text);
+const char* GlopGetClipboardText();
+void GlopSetRelativeMouseMode(int enable);
 
 
 /*
@@ -0,0 +1,17 @@
+package render_test
+
+import (
+	"github.com/orfjackal/gospec/src/gospec"
+	"github.com/runningwild/glop/render"
+	"testing"
+)
+
+func TestAllSpecs(t *testing.T) {
+	r := gospec.NewRunner()
+	r.AddSpec(FutureSpec)
+	r.AddSpec(QueueSpec)
+	r.AddSpec(PreprocessShaderSpec)
+	r.AddSpec(ResourceSpec)
+	r.AddSpec(render.UniformSpec)
+	gospec.MainGoTest(r, t)
+}
@@ -0,0 +1,89 @@
+package render
+
+import (
+	"fmt"
+	"runtime/debug"
+)
+
+// A Future is the result of a function that was queued with Call().
+type Future struct {
+	done  chan struct{}
+	value interface{}
+	err   error
+}
+
+// A PanicError is returned by Future.Wait() if the function panicked.  The
+// panic is recovered on the render thread, so the render thread keeps running.
+type PanicError struct {
+	// The value passed to panic().
+	Value interface{}
+
+	// The stack trace of the render thread where the panic happened.
+	Stack []byte
+}
+
+func (pe *PanicError) Error() string {
+	return fmt.Sprintf("Panic on the render thread: %v\n%s", pe.Value, pe.Stack)
+}
+
+// Call queues f to run on the render thread, like Queue(), and returns a
+// Future for its result.  Use Wait() to get the result, or Done() to wait for
+// it in a select.
+func Call(f func() (interface{}, error)) *Future {
+	return call(f)
+}
+
+// call must be called directly by the exported functions, so that the stats
+// are for the code that called them.
+func call(f func() (interface{}, error)) *Future {
+	future := &Future{done: make(chan struct{})}
+	queue(PriorityNormal, func() {
+		defer close(future.done)
+		future.value, future.err = protect(f)
+	}, 2)
+	return future
+}
+
+// Do runs f on the render thread and blocks until it has run, returning its
+// error.  It is shorthand for Call() and Wait() when there is no value.  On the
+// render thread it returns ErrRenderThread without running f.
+func Do(f func() error) error {
+	if OnRenderThread() {
+		return ErrRenderThread
+	}
+	_, err := call(func() (interface{}, error) {
+		return nil, f()
+	}).Wait()
+	return err
+}
+
+func protect(f func() (interface{}, error)) (value interface{}, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			value = nil
+			err = &PanicError{Value: r, Stack: debug.Stack()}
+		}
+	}()
+	return f()
+}
+
+// Wait blocks until the function has run and returns what it returned.  If it
+// panicked the error is a *PanicError.  Wait can be called any number of times,
+// from any goroutine.  On the render thread, where waiting would deadlock, it
+// returns ErrRenderThread if the function hasn't run yet.
+func (f *Future) Wait() (interface{}, error) {
+	select {
+	case <-f.done:
+	default:
+		if OnRenderThread() {
+			return nil, ErrRenderThread
+		}
+		<-f.done
+	}
+	return f.value, f.err
+}
+
+// Done returns a channel that is closed once the function has run.
+func (f *Future) Done() <-chan struct{} {
+	return f.done
+}
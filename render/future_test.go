@@ -0,0 +1,63 @@
+package render_test
+
+import (
+	"fmt"
+	"github.com/orfjackal/gospec/src/gospec"
+	. "github.com/orfjackal/gospec/src/gospec"
+	"github.com/runningwild/glop/render"
+	"strings"
+)
+
+func FutureSpec(c gospec.Context) {
+	render.Init()
+	c.Specify("Call returns the value and error from the render thread.", func() {
+		future := render.Call(func() (interface{}, error) {
+			return 7, nil
+		})
+		value, err := future.Wait()
+		c.Expect(err, IsNil)
+		c.Expect(value, Equals, 7)
+
+		_, err = render.Call(func() (interface{}, error) {
+			return nil, fmt.Errorf("no texture for you")
+		}).Wait()
+		c.Expect(err, Not(IsNil))
+		<-future.Done()
+		value, _ = future.Wait()
+		c.Expect(value, Equals, 7)
+	})
+
+	c.Specify("Calls run in order with queued functions.", func() {
+		var order []int
+		render.Queue(func() { order = append(order, 1) })
+		future := render.Call(func() (interface{}, error) {
+			order = append(order, 2)
+			return len(order), nil
+		})
+		render.Queue(func() { order = append(order, 3) })
+		value, _ := future.Wait()
+		c.Expect(value, Equals, 2)
+		render.Purge()
+		c.Expect(order, ContainsInOrder, []int{1, 2, 3})
+	})
+
+	c.Specify("Panics are returned as errors and the render thread keeps going.", func() {
+		value, err := render.Call(func() (interface{}, error) {
+			panic("oops")
+		}).Wait()
+		c.Expect(value, IsNil)
+		panic_err, ok := err.(*render.PanicError)
+		c.Assume(ok, Equals, true)
+		c.Expect(panic_err.Value, Equals, "oops")
+		c.Expect(strings.Contains(panic_err.Error(), "oops"), Equals, true)
+
+		err = render.Do(func() error {
+			var m map[string]int
+			m["x"] = 1
+			return nil
+		})
+		_, ok = err.(*render.PanicError)
+		c.Expect(ok, Equals, true)
+		c.Expect(render.Do(func() error { return nil }), IsNil)
+	})
+}
@@ -0,0 +1,130 @@
+package render_test
+
+import (
+	"context"
+	"github.com/orfjackal/gospec/src/gospec"
+	. "github.com/orfjackal/gospec/src/gospec"
+	"github.com/runningwild/glop/render"
+	"strings"
+	"time"
+)
+
+// blockRenderThread keeps the render thread busy until the returned channel is
+// closed.
+func blockRenderThread() chan struct{} {
+	release := make(chan struct{})
+	started := make(chan struct{})
+	render.QueuePriority(render.PriorityHigh, func() {
+		close(started)
+		<-release
+	})
+	<-started
+	return release
+}
+
+func QueueSpec(c gospec.Context) {
+	render.Init()
+	c.Specify("Higher priority functions run first.", func() {
+		release := blockRenderThread()
+		var order []string
+		render.QueuePriority(render.PriorityLow, func() { order = append(order, "low") })
+		render.Queue(func() { order = append(order, "normal") })
+		render.QueuePriority(render.PriorityHigh, func() { order = append(order, "high 1") })
+		render.QueuePriority(render.PriorityHigh, func() { order = append(order, "high 2") })
+		close(release)
+		render.Purge()
+		c.Expect(order, ContainsInOrder, []string{"high 1", "high 2", "normal", "low"})
+	})
+
+	c.Specify("TryQueue doesn't block when a lane is full.", func() {
+		release := blockRenderThread()
+		count := 0
+		for render.TryQueue(func() { count++ }) {
+		}
+		c.Expect(count, Equals, 0)
+		c.Expect(render.TryQueuePriority(render.PriorityLow, func() { count++ }), Equals, true)
+		c.Expect(render.GetStats().Lanes[render.PriorityNormal].Depth, Equals, render.QueueSize)
+		close(release)
+		render.Purge()
+		c.Expect(count, Equals, render.QueueSize+1)
+		c.Expect(render.GetStats().Lanes[render.PriorityNormal].Depth, Equals, 0)
+	})
+
+	c.Specify("Purge can time out.", func() {
+		release := blockRenderThread()
+		c.Expect(render.PurgeTimeout(10*time.Millisecond), Equals, context.DeadlineExceeded)
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		c.Expect(render.PurgeContext(ctx), Equals, context.Canceled)
+		close(release)
+		c.Expect(render.PurgeTimeout(time.Second), IsNil)
+	})
+
+	c.Specify("Waiting for the render thread from the render thread is an error.", func() {
+		c.Expect(render.OnRenderThread(), Equals, false)
+		var on_render_thread bool
+		var purge_err, do_err, wait_err error
+		render.Queue(func() {
+			on_render_thread = render.OnRenderThread()
+			purge_err = render.PurgeTimeout(time.Second)
+			do_err = render.Do(func() error { return nil })
+			_, wait_err = render.Call(func() (interface{}, error) { return nil, nil }).Wait()
+		})
+		render.Purge()
+		c.Expect(on_render_thread, Equals, true)
+		c.Expect(purge_err, Equals, render.ErrRenderThread)
+		c.Expect(do_err, Equals, render.ErrRenderThread)
+		c.Expect(wait_err, Equals, render.ErrRenderThread)
+
+		err := render.Do(func() error {
+			render.Purge()
+			return nil
+		})
+		_, ok := err.(*render.PanicError)
+		c.Expect(ok, Equals, true)
+	})
+
+	c.Specify("Stats track queue depth and time spent per function.", func() {
+		render.ResetStats()
+		var slow_caller string
+		var slow_priority render.Priority
+		render.SetSlowFuncHandler(5*time.Millisecond, func(caller string, p render.Priority, d time.Duration) {
+			slow_caller = caller
+			slow_priority = p
+		})
+		defer render.SetSlowFuncHandler(0, nil)
+
+		release := blockRenderThread()
+		for i := 0; i < 3; i++ {
+			render.QueuePriority(render.PriorityLow, func() {})
+		}
+		render.QueuePriority(render.PriorityLow, func() { time.Sleep(10 * time.Millisecond) })
+		close(release)
+		render.Purge()
+
+		stats := render.GetStats()
+		low := stats.Lanes[render.PriorityLow]
+		c.Expect(low.Queued, Equals, int64(4))
+		c.Expect(low.Run, Equals, int64(4))
+		c.Expect(low.MaxDepth, Equals, 4)
+		c.Expect(low.Depth, Equals, 0)
+		c.Expect(low.Longest >= 10*time.Millisecond, Equals, true)
+		c.Expect(low.Busy >= low.Longest, Equals, true)
+		c.Expect(stats.Lanes[render.PriorityHigh].Run, Equals, int64(1))
+
+		c.Expect(strings.Contains(slow_caller, "queue_test.go"), Equals, true)
+		c.Expect(slow_priority, Equals, render.PriorityLow)
+		found := false
+		for caller, fs := range stats.Funcs {
+			c.Expect(strings.Contains(caller, "queue_test.go"), Equals, true)
+			if caller == slow_caller {
+				found = true
+				c.Expect(fs.Run, Equals, int64(1))
+			}
+		}
+		c.Expect(found, Equals, true)
+
+		render.ResetStats()
+		c.Expect(render.GetStats().Lanes[render.PriorityLow].Run, Equals, int64(0))
+	})
+}
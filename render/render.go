@@ -1,53 +1,242 @@
 package render
 
 import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
 	"runtime"
+	"strconv"
 	"sync"
+	"sync/atomic"
+	"time"
 )
 
+// Functions are queued in one of several lanes.  Whenever the render thread is
+// ready for another function it takes one from the highest priority lane that
+// has any, so per-frame work doesn't wait behind bulk work like texture
+// uploads.  Functions in the same lane run in the order they were queued, but
+// there is no ordering between lanes.
+type Priority int
+
+const (
+	PriorityHigh Priority = iota
+	PriorityNormal
+	PriorityLow
+	NumPriorities
+)
+
+func (p Priority) String() string {
+	switch p {
+	case PriorityHigh:
+		return "high"
+	case PriorityNormal:
+		return "normal"
+	case PriorityLow:
+		return "low"
+	}
+	panic(fmt.Sprintf("%d is not a valid Priority", p))
+}
+
+// QueueSize is how many functions each lane can hold.  Queueing a function
+// into a lane that is full blocks until there is room, and counts as a stall.
+const QueueSize = 1000
+
+// ErrRenderThread is returned by anything that would wait forever because it
+// was called from the render thread, waiting for the render thread.
+var ErrRenderThread = errors.New("Can't wait for the render thread from the render thread.")
+
+type queuedFunc struct {
+	f func()
+
+	// Where the function was queued from, for reporting slow functions.
+	caller uintptr
+}
+
+type purgeRequest struct {
+	done chan struct{}
+}
+
 var (
-	render_funcs chan func()
-	purge        chan bool
-	init_once    sync.Once
+	lanes     [NumPriorities]chan queuedFunc
+	purge     chan purgeRequest
+	init_once sync.Once
+
+	// Id of the render thread's goroutine, 0 until Init() is called.  Only
+	// accessed atomically.
+	render_goroutine int64
 )
 
 func init() {
-	render_funcs = make(chan func(), 1000)
-	purge = make(chan bool)
+	for i := range lanes {
+		lanes[i] = make(chan queuedFunc, QueueSize)
+	}
+	purge = make(chan purgeRequest)
 }
 
 // Queues a function to run on the render thread
 func Queue(f func()) {
-	render_funcs <- f
+	queue(PriorityNormal, f, 1)
+}
+
+// QueuePriority queues a function to run on the render thread in the lane for
+// p.
+func QueuePriority(p Priority, f func()) {
+	queue(p, f, 1)
+}
+
+// TryQueue queues f like Queue(), unless its lane is full, in which case it
+// returns false without queueing it.
+func TryQueue(f func()) bool {
+	return tryQueue(PriorityNormal, f)
+}
+
+// TryQueuePriority is TryQueue() for the lane for p.
+func TryQueuePriority(p Priority, f func()) bool {
+	return tryQueue(p, f)
 }
 
-// Waits until all render thread functions have been run
+// depth is how many functions in this package are between queue and the code
+// that f should be attributed to in the stats.
+func queue(p Priority, f func(), depth int) {
+	qf := makeQueuedFunc(f, depth+1)
+	select {
+	case lanes[p] <- qf:
+		stats.queued(p)
+		return
+	default:
+	}
+	if OnRenderThread() {
+		panic(fmt.Sprintf("The %v priority render queue is full, so queueing from the render thread would deadlock.", p))
+	}
+	start := time.Now()
+	lanes[p] <- qf
+	stats.stalled(p, time.Since(start))
+	stats.queued(p)
+}
+
+func tryQueue(p Priority, f func()) bool {
+	select {
+	case lanes[p] <- makeQueuedFunc(f, 2):
+		stats.queued(p)
+		return true
+	default:
+		return false
+	}
+}
+
+// makeQueuedFunc attributes f to the code depth functions above its caller.
+func makeQueuedFunc(f func(), depth int) queuedFunc {
+	// Skip runtime.Callers and makeQueuedFunc too.
+	var pc [1]uintptr
+	runtime.Callers(depth+2, pc[:])
+	return queuedFunc{f: f, caller: pc[0]}
+}
+
+// Waits until all render thread functions have been run.  Calling this from
+// the render thread would deadlock, so it panics instead.
 func Purge() {
-	purge <- true
-	<-purge
+	if err := PurgeContext(context.Background()); err != nil {
+		panic(fmt.Sprintf("render.Purge(): %v", err))
+	}
+}
+
+// PurgeContext waits until all render thread functions that were queued before
+// it was called have been run, or until ctx is done, in which case it returns
+// ctx.Err().  It returns ErrRenderThread if called from the render thread.
+func PurgeContext(ctx context.Context) error {
+	if OnRenderThread() {
+		return ErrRenderThread
+	}
+	req := purgeRequest{done: make(chan struct{})}
+	select {
+	case purge <- req:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	select {
+	case <-req.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// PurgeTimeout is PurgeContext() with a timeout.
+func PurgeTimeout(timeout time.Duration) error {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	return PurgeContext(ctx)
+}
+
+// OnRenderThread returns true iff it is called from the render thread.
+func OnRenderThread() bool {
+	id := atomic.LoadInt64(&render_goroutine)
+	return id != 0 && id == goroutineId()
+}
+
+// goroutineId returns the id of the calling goroutine, which Go doesn't
+// otherwise expose, from the first line of its stack trace.
+func goroutineId() int64 {
+	var buf [64]byte
+	b := buf[:runtime.Stack(buf[:], false)]
+	b = bytes.TrimPrefix(b, []byte("goroutine "))
+	if i := bytes.IndexByte(b, ' '); i >= 0 {
+		b = b[:i]
+	}
+	id, _ := strconv.ParseInt(string(b), 10, 64)
+	return id
+}
+
+// next returns the next function to run and its lane, or false if every lane
+// is empty.
+func next() (Priority, queuedFunc, bool) {
+	for p := range lanes {
+		select {
+		case qf := <-lanes[p]:
+			return Priority(p), qf, true
+		default:
+		}
+	}
+	return 0, queuedFunc{}, false
+}
+
+func run(p Priority, qf queuedFunc) {
+	start := time.Now()
+	qf.f()
+	stats.ran(p, qf.caller, time.Since(start))
 }
 
 func Init() {
 	init_once.Do(func() {
+		started := make(chan struct{})
 		go func() {
 			runtime.LockOSThread()
+			atomic.StoreInt64(&render_goroutine, goroutineId())
+			close(started)
 			for {
+				// Always take the highest priority function that is waiting.
+				if p, qf, ok := next(); ok {
+					run(p, qf)
+					continue
+				}
 				select {
-				case f := <-render_funcs:
-					f()
-				case <-purge:
-					for {
-						select {
-						case f := <-render_funcs:
-							f()
-						default:
-							goto purged
-						}
+				case qf := <-lanes[PriorityHigh]:
+					run(PriorityHigh, qf)
+				case qf := <-lanes[PriorityNormal]:
+					run(PriorityNormal, qf)
+				case qf := <-lanes[PriorityLow]:
+					run(PriorityLow, qf)
+				case req := <-purge:
+					for p, qf, ok := next(); ok; p, qf, ok = next() {
+						run(p, qf)
 					}
-				purged:
-					purge <- true
+					// In case a finalizer couldn't queue this itself.
+					deletePending()
+					close(req.done)
 				}
 			}
 		}()
+		<-started
 	})
 }
@@ -0,0 +1,290 @@
+package render
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/go-gl/gl/v3.3-core/gl"
+	"runtime"
+	"sort"
+	"sync"
+)
+
+type ResourceType int
+
+const (
+	ResourceTexture ResourceType = iota
+	ResourceBuffer
+	ResourceVertexArray
+	ResourceSampler
+	ResourceProgram
+)
+
+func (t ResourceType) String() string {
+	switch t {
+	case ResourceTexture:
+		return "texture"
+	case ResourceBuffer:
+		return "buffer"
+	case ResourceVertexArray:
+		return "vertex array"
+	case ResourceSampler:
+		return "sampler"
+	case ResourceProgram:
+		return "program"
+	}
+	panic(fmt.Sprintf("%d is not a valid ResourceType", t))
+}
+
+// A Resource owns an OpenGL object and deletes it when Release() is called.
+// If a Resource is garbage collected without being released, the object is
+// deleted on the render thread the next time it gets to it, but this is
+// counted as a leak in LeakReport() since it means the object lived longer
+// than it needed to.
+type Resource struct {
+	// The tracker keeps track of records rather than Resources, so that it
+	// doesn't keep Resources from being garbage collected.
+	rec *resourceRecord
+}
+
+type resourceRecord struct {
+	kind  ResourceType
+	id    uint32
+	label string
+
+	// Where the resource was created, for the leak report.
+	created uintptr
+
+	// Only accessed with tracker locked.
+	released bool
+}
+
+var tracker struct {
+	sync.Mutex
+	live map[*resourceRecord]struct{}
+
+	// Resources that were garbage collected without being released, which
+	// are waiting to be deleted, and how many there have been by where they
+	// were created.
+	pending   []*resourceRecord
+	finalized map[uintptr]int
+}
+
+func init() {
+	tracker.live = make(map[*resourceRecord]struct{})
+	tracker.finalized = make(map[uintptr]int)
+}
+
+// Track makes a Resource for an OpenGL object that was created elsewhere.  The
+// Resource owns the object from then on.  label is only used in LeakReport().
+func Track(t ResourceType, id uint32, label string) *Resource {
+	return track(t, id, label, 1)
+}
+
+// track attributes the resource to the code depth functions above it.
+func track(t ResourceType, id uint32, label string, depth int) *Resource {
+	var pc [1]uintptr
+	runtime.Callers(depth+2, pc[:])
+	rec := &resourceRecord{kind: t, id: id, label: label, created: pc[0]}
+	tracker.Lock()
+	tracker.live[rec] = struct{}{}
+	tracker.Unlock()
+	r := &Resource{rec: rec}
+	runtime.SetFinalizer(r, finalizeResource)
+	return r
+}
+
+// The functions below create OpenGL objects, so they must be called on the
+// render thread.
+
+func NewTexture(label string) *Resource {
+	var id uint32
+	gl.GenTextures(1, &id)
+	return track(ResourceTexture, id, label, 1)
+}
+
+func NewBuffer(label string) *Resource {
+	var id uint32
+	gl.GenBuffers(1, &id)
+	return track(ResourceBuffer, id, label, 1)
+}
+
+func NewVertexArray(label string) *Resource {
+	var id uint32
+	gl.GenVertexArrays(1, &id)
+	return track(ResourceVertexArray, id, label, 1)
+}
+
+func NewSampler(label string) *Resource {
+	var id uint32
+	gl.GenSamplers(1, &id)
+	return track(ResourceSampler, id, label, 1)
+}
+
+// Id returns the OpenGL name of the object.  It must not be used after
+// Release() is called.
+func (r *Resource) Id() uint32 {
+	return r.rec.id
+}
+
+func (r *Resource) Type() ResourceType {
+	return r.rec.kind
+}
+
+func (r *Resource) Label() string {
+	return r.rec.label
+}
+
+// Released returns true iff Release() has been called.
+func (r *Resource) Released() bool {
+	tracker.Lock()
+	defer tracker.Unlock()
+	return r.rec.released
+}
+
+// Release deletes the OpenGL object.  On the render thread this happens right
+// away, otherwise it is queued to happen there.  Calling Release() more than
+// once does nothing.
+func (r *Resource) Release() {
+	runtime.SetFinalizer(r, nil)
+	if !untrack(r.rec) {
+		return
+	}
+	if OnRenderThread() {
+		deleteResource(r.rec)
+		return
+	}
+	rec := r.rec
+	QueuePriority(PriorityLow, func() {
+		deleteResource(rec)
+	})
+}
+
+// untrack marks rec as released, and returns false if it already was.
+func untrack(rec *resourceRecord) bool {
+	tracker.Lock()
+	defer tracker.Unlock()
+	if rec.released {
+		return false
+	}
+	rec.released = true
+	delete(tracker.live, rec)
+	return true
+}
+
+func finalizeResource(r *Resource) {
+	if !untrack(r.rec) {
+		return
+	}
+	tracker.Lock()
+	tracker.pending = append(tracker.pending, r.rec)
+	tracker.finalized[r.rec.created]++
+	tracker.Unlock()
+
+	// Finalizers must not block, so if the queue is full this waits until
+	// another one gets through, or until the next Purge().
+	TryQueuePriority(PriorityLow, deletePending)
+}
+
+// deletePending deletes resources that were garbage collected without being
+// released.  Must be called on the render thread.
+func deletePending() {
+	tracker.Lock()
+	pending := tracker.pending
+	tracker.pending = nil
+	tracker.Unlock()
+	for _, rec := range pending {
+		deleteResource(rec)
+	}
+}
+
+func deleteResource(rec *resourceRecord) {
+	// 0 is never the name of an OpenGL object.
+	if rec.id == 0 {
+		return
+	}
+	switch rec.kind {
+	case ResourceTexture:
+		gl.DeleteTextures(1, &rec.id)
+	case ResourceBuffer:
+		gl.DeleteBuffers(1, &rec.id)
+	case ResourceVertexArray:
+		gl.DeleteVertexArrays(1, &rec.id)
+	case ResourceSampler:
+		gl.DeleteSamplers(1, &rec.id)
+	case ResourceProgram:
+		gl.DeleteProgram(rec.id)
+	}
+}
+
+// ResourceInfo describes a Resource that hasn't been released.
+type ResourceInfo struct {
+	Type  ResourceType
+	Id    uint32
+	Label string
+
+	// The file:line where the Resource was created.
+	Created string
+}
+
+// LiveResources returns every Resource that hasn't been released or garbage
+// collected, sorted by type and then id.
+func LiveResources() []ResourceInfo {
+	tracker.Lock()
+	var infos []ResourceInfo
+	var pcs []uintptr
+	for rec := range tracker.live {
+		infos = append(infos, ResourceInfo{Type: rec.kind, Id: rec.id, Label: rec.label})
+		pcs = append(pcs, rec.created)
+	}
+	tracker.Unlock()
+	for i := range infos {
+		infos[i].Created = callerName(pcs[i])
+	}
+	sort.Slice(infos, func(i, j int) bool {
+		if infos[i].Type != infos[j].Type {
+			return infos[i].Type < infos[j].Type
+		}
+		if infos[i].Id != infos[j].Id {
+			return infos[i].Id < infos[j].Id
+		}
+		return infos[i].Label < infos[j].Label
+	})
+	return infos
+}
+
+// FinalizedResources returns how many Resources were garbage collected
+// without being released, keyed by the file:line where they were created.
+func FinalizedResources() map[string]int {
+	tracker.Lock()
+	defer tracker.Unlock()
+	counts := make(map[string]int)
+	for pc, n := range tracker.finalized {
+		counts[callerName(pc)] += n
+	}
+	return counts
+}
+
+// LeakReport returns a human readable list of every live Resource, and of
+// where Resources that were garbage collected without being released came
+// from.  Call it at shutdown, after releasing everything, to find leaks.
+func LeakReport() string {
+	var buf bytes.Buffer
+	live := LiveResources()
+	fmt.Fprintf(&buf, "%d live GL resources\n", len(live))
+	for _, info := range live {
+		fmt.Fprintf(&buf, "  %v %d '%s' created at %s\n", info.Type, info.Id, info.Label, info.Created)
+	}
+	finalized := FinalizedResources()
+	var sites []string
+	for site := range finalized {
+		sites = append(sites, site)
+	}
+	sort.Strings(sites)
+	if len(sites) > 0 {
+		fmt.Fprintf(&buf, "GL resources garbage collected without being released\n")
+	}
+	for _, site := range sites {
+		fmt.Fprintf(&buf, "  %d created at %s\n", finalized[site], site)
+	}
+	return buf.String()
+}
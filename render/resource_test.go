@@ -0,0 +1,76 @@
+package render_test
+
+import (
+	"github.com/orfjackal/gospec/src/gospec"
+	. "github.com/orfjackal/gospec/src/gospec"
+	"github.com/runningwild/glop/render"
+	"runtime"
+	"strings"
+	"time"
+)
+
+// The resources in these specs all have id 0, which isn't an OpenGL object,
+// so nothing is actually deleted.
+
+func isLive(label string) bool {
+	for _, info := range render.LiveResources() {
+		if info.Label == label {
+			return true
+		}
+	}
+	return false
+}
+
+func countFinalized() int {
+	total := 0
+	for _, n := range render.FinalizedResources() {
+		total += n
+	}
+	return total
+}
+
+func ResourceSpec(c gospec.Context) {
+	render.Init()
+	c.Specify("Released resources aren't live.", func() {
+		a := render.Track(render.ResourceTexture, 0, "spec texture a")
+		b := render.Track(render.ResourceBuffer, 0, "spec buffer b")
+		c.Expect(isLive("spec texture a"), Equals, true)
+		c.Expect(isLive("spec buffer b"), Equals, true)
+		c.Expect(a.Type(), Equals, render.ResourceTexture)
+		c.Expect(a.Released(), Equals, false)
+
+		a.Release()
+		a.Release()
+		c.Expect(a.Released(), Equals, true)
+		c.Expect(isLive("spec texture a"), Equals, false)
+		c.Expect(isLive("spec buffer b"), Equals, true)
+
+		render.Queue(func() { b.Release() })
+		render.Purge()
+		c.Expect(isLive("spec buffer b"), Equals, false)
+	})
+
+	c.Specify("The leak report says where live resources came from.", func() {
+		r := render.Track(render.ResourceSampler, 0, "spec sampler")
+		report := render.LeakReport()
+		c.Expect(strings.Contains(report, "sampler 0 'spec sampler' created at "), Equals, true)
+		c.Expect(strings.Contains(report, "resource_test.go"), Equals, true)
+		r.Release()
+		c.Expect(strings.Contains(render.LeakReport(), "spec sampler"), Equals, false)
+	})
+
+	c.Specify("Resources that are garbage collected are deleted and reported.", func() {
+		before := countFinalized()
+		func() {
+			render.Track(render.ResourceVertexArray, 0, "spec forgotten vertex array")
+		}()
+		for i := 0; i < 100 && countFinalized() == before; i++ {
+			runtime.GC()
+			time.Sleep(time.Millisecond)
+		}
+		render.Purge()
+		c.Expect(countFinalized(), Equals, before+1)
+		c.Expect(isLive("spec forgotten vertex array"), Equals, false)
+		c.Expect(strings.Contains(render.LeakReport(), "garbage collected without being released"), Equals, true)
+	})
+}
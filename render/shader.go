@@ -6,10 +6,18 @@ import (
 	"unsafe"
 )
 
-var shader_progs map[string]uint32
+type shaderProgram struct {
+	program *Resource
+
+	// Looked up once when the program is linked, so that setting a uniform
+	// doesn't have to ask OpenGL where it is every time.
+	uniforms map[string]Uniform
+}
+
+var shader_progs map[string]*shaderProgram
 
 func init() {
-	shader_progs = make(map[string]uint32)
+	shader_progs = make(map[string]*shaderProgram)
 }
 
 func EnableShader(name string) error {
@@ -21,49 +29,72 @@ func EnableShader(name string) error {
 	if !ok {
 		return fmt.Errorf("Tried to use unknown shader '%s'", name)
 	}
-	gl.UseProgram(prog_obj)
+	gl.UseProgram(prog_obj.program.Id())
 	return nil
 }
 
-func SetUniformI(shader, variable string, n int32) error {
-	prog, ok := shader_progs[shader]
-	if !ok {
-		return fmt.Errorf("Tried to set a uniform in an unknown shader '%s'", shader)
+func RegisterShader(name string, vertex, fragment []byte) error {
+	_, from_files := shader_files[name]
+	if _, ok := shader_progs[name]; ok || from_files {
+		return fmt.Errorf("Tried to register a shader called '%s' twice", name)
+	}
+	program_id, err := compileProgram(name, vertex, fragment)
+	if err != nil {
+		return err
+	}
+	shader_progs[name] = &shaderProgram{
+		program:  track(ResourceProgram, program_id, name, 1),
+		uniforms: introspectUniforms(program_id),
 	}
-	bvariable := []byte(fmt.Sprintf("%s\x00", variable))
-	loc := gl.GetUniformLocation(prog, (*uint8)(unsafe.Pointer(&bvariable[0])))
-	gl.Uniform1i(loc, n)
 	return nil
 }
 
-func SetUniformF(shader, variable string, f float32) error {
-	prog, ok := shader_progs[shader]
-	if !ok {
-		return fmt.Errorf("Tried to set a uniform in an unknown shader '%s'", shader)
+// ReplaceShader recompiles the shader called name from new source, or
+// registers it if there isn't one yet.  If compiling fails the old program is
+// kept, so whatever was using it keeps working, and the error is returned.
+func ReplaceShader(name string, vertex, fragment []byte) error {
+	program_id, err := compileProgram(name, vertex, fragment)
+	if err != nil {
+		return err
+	}
+	if prog, ok := shader_progs[name]; ok {
+		prog.program.Release()
+		prog.program = track(ResourceProgram, program_id, name, 1)
+		prog.uniforms = introspectUniforms(program_id)
+		return nil
+	}
+	shader_progs[name] = &shaderProgram{
+		program:  track(ResourceProgram, program_id, name, 1),
+		uniforms: introspectUniforms(program_id),
 	}
-	bvariable := []byte(fmt.Sprintf("%s\x00", variable))
-	loc := gl.GetUniformLocation(prog, (*uint8)(unsafe.Pointer(&bvariable[0])))
-	gl.Uniform1f(loc, f)
 	return nil
 }
 
-func SetUniform4F(shader, variable string, vs []float32) error {
-	prog, ok := shader_progs[shader]
-	if !ok {
-		return fmt.Errorf("Tried to set a uniform in an unknown shader '%s'", shader)
+// UnregisterShader deletes the shader called name, which can then be
+// registered again.  Shaders registered with RegisterShaderFiles() stop being
+// watched.  Must be called on the render thread.
+func UnregisterShader(name string) error {
+	prog, ok := shader_progs[name]
+	_, from_files := shader_files[name]
+	if !ok && !from_files {
+		return fmt.Errorf("Tried to unregister unknown shader '%s'", name)
+	}
+	if ok {
+		prog.program.Release()
+		delete(shader_progs, name)
 	}
-	bvariable := []byte(fmt.Sprintf("%s\x00", variable))
-	loc := gl.GetUniformLocation(prog, (*uint8)(unsafe.Pointer(&bvariable[0])))
-	gl.Uniform4f(loc, vs[0], vs[1], vs[2], vs[3])
+	delete(shader_files, name)
 	return nil
 }
 
-func RegisterShader(name string, vertex, fragment []byte) error {
-	if _, ok := shader_progs[name]; ok {
-		return fmt.Errorf("Tried to register a shader called '%s' twice", name)
+func compileProgram(name string, vertex, fragment []byte) (uint32, error) {
+	if len(vertex) == 0 || len(fragment) == 0 {
+		return 0, fmt.Errorf("Shader '%s' has no source", name)
 	}
-
+	// The shader objects are only needed until the program is linked, and
+	// they aren't actually deleted until they're detached from it.
 	vertex_id := gl.CreateShader(gl.VERTEX_SHADER)
+	defer gl.DeleteShader(vertex_id)
 	pointer := &vertex[0]
 	length := int32(len(vertex))
 	gl.ShaderSource(vertex_id, 1, (**uint8)(unsafe.Pointer(&pointer)), &length)
@@ -78,10 +109,11 @@ func RegisterShader(name string, vertex, fragment []byte) error {
 			length--
 		}
 		maxVersion := gl.GoStr(gl.GetString(gl.SHADING_LANGUAGE_VERSION))
-		return fmt.Errorf("Failed to compile vertex shader (max version supported: %q) %q: %q", maxVersion, name, buf[0:int(length)])
+		return 0, fmt.Errorf("Failed to compile vertex shader (max version supported: %q) %q: %q", maxVersion, name, buf[0:int(length)])
 	}
 
 	fragment_id := gl.CreateShader(gl.FRAGMENT_SHADER)
+	defer gl.DeleteShader(fragment_id)
 	pointer = &fragment[0]
 	length = int32(len(fragment))
 	gl.ShaderSource(fragment_id, 1, (**uint8)(unsafe.Pointer(&pointer)), &length)
@@ -95,7 +127,7 @@ func RegisterShader(name string, vertex, fragment []byte) error {
 			length--
 		}
 		maxVersion := gl.GoStr(gl.GetString(gl.SHADING_LANGUAGE_VERSION))
-		return fmt.Errorf("Failed to compile fragment shader (max version supported: %q) %q: %q", maxVersion, name, buf[0:int(length)])
+		return 0, fmt.Errorf("Failed to compile fragment shader (max version supported: %q) %q: %q", maxVersion, name, buf[0:int(length)])
 	}
 
 	// shader successfully compiled - now link
@@ -103,13 +135,18 @@ func RegisterShader(name string, vertex, fragment []byte) error {
 	gl.AttachShader(program_id, vertex_id)
 	gl.AttachShader(program_id, fragment_id)
 	gl.LinkProgram(program_id)
+	gl.DetachShader(program_id, vertex_id)
+	gl.DetachShader(program_id, fragment_id)
 	gl.GetProgramiv(program_id, gl.LINK_STATUS, &param)
 	if param == 0 {
-		return fmt.Errorf("Failed to link shader '%s': %v", name, param)
+		buf := make([]byte, 5*1024)
+		var length int32
+		gl.GetProgramInfoLog(program_id, int32(len(buf)), &length, (*uint8)(unsafe.Pointer(&buf[0])))
+		gl.DeleteProgram(program_id)
+		return 0, fmt.Errorf("Failed to link shader '%s': %q", name, buf[0:int(length)])
 	}
 
-	shader_progs[name] = program_id
-	return nil
+	return program_id, nil
 }
 
 func GetAttribLocation(shaderName, attribName string) (int32, error) {
@@ -117,13 +154,17 @@ func GetAttribLocation(shaderName, attribName string) (int32, error) {
 	if !ok {
 		return -1, fmt.Errorf("No shader named '%s'", shaderName)
 	}
-	return gl.GetAttribLocation(prog, gl.Str(fmt.Sprintf("%s\x00", attribName))), nil
+	return gl.GetAttribLocation(prog.program.Id(), gl.Str(fmt.Sprintf("%s\x00", attribName))), nil
 }
 
+// GetUniformLocation returns an error if the shader has no active uniform
+// called uniformName, which includes uniforms that are declared but that the
+// compiler found were unused.  The typed SetUniform*() functions are usually
+// easier.
 func GetUniformLocation(shaderName, uniformName string) (int32, error) {
-	prog, ok := shader_progs[shaderName]
-	if !ok {
-		return -1, fmt.Errorf("No shader named '%s'", shaderName)
+	u, err := GetUniform(shaderName, uniformName)
+	if err != nil {
+		return -1, err
 	}
-	return gl.GetUniformLocation(prog, gl.Str(fmt.Sprintf("%s\x00", uniformName))), nil
+	return u.Location, nil
 }
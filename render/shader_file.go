@@ -0,0 +1,356 @@
+package render
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// PreprocessShader reads the GLSL source in path and returns it with every
+// #include "file" line replaced by the contents of that file, which is found
+// relative to the file that includes it.  A file that has a #pragma once line
+// is only included the first time, so files can share includes.  Lines that
+// start inside of a /* */ comment are left alone.  Each key in defines is added
+// as #define key value, right after the #version line if there is one.
+//
+// The source is annotated with #line directives, so the compiler reports
+// errors with the line numbers of the original files and the index of the file
+// in the returned list of files, which is every file that was read, in order.
+func PreprocessShader(path string, defines map[string]string) ([]byte, []string, error) {
+	p := preprocess(path, defines)
+	return p.source, p.files, p.err
+}
+
+type preprocessed struct {
+	source []byte
+	files  []string
+	err    error
+
+	// The stamp of each file in files, from before it was read.
+	stamps map[string]fileStamp
+}
+
+func preprocess(path string, defines map[string]string) preprocessed {
+	p := preprocessor{
+		defines: defines,
+		index:   make(map[string]int),
+		once:    make(map[string]bool),
+		stamps:  make(map[string]fileStamp),
+	}
+	if err := p.include(path, nil); err != nil {
+		return preprocessed{files: p.files, err: err, stamps: p.stamps}
+	}
+	return preprocessed{source: p.out.Bytes(), files: p.files, stamps: p.stamps}
+}
+
+type preprocessor struct {
+	out     bytes.Buffer
+	defines map[string]string
+
+	// Every file read so far, and the index of each in files.
+	files []string
+	index map[string]int
+
+	// Files with #pragma once that have already been included.
+	once map[string]bool
+
+	// Stamped before each file is read, so that a change that lands while it
+	// is being read still looks like a change afterwards.
+	stamps map[string]fileStamp
+}
+
+// include writes the preprocessed contents of path to p.out.  stack is the
+// chain of files that included it, to catch include cycles.
+func (p *preprocessor) include(path string, stack []string) error {
+	path = filepath.Clean(path)
+	if p.once[path] {
+		return nil
+	}
+	for _, s := range stack {
+		if s == path {
+			return fmt.Errorf("'%s' includes itself: %s", path, strings.Join(append(stack, path), " -> "))
+		}
+	}
+	if _, ok := p.stamps[path]; !ok {
+		p.stamps[path] = stampFile(path)
+	}
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	index, ok := p.index[path]
+	if !ok {
+		index = len(p.files)
+		p.index[path] = index
+		p.files = append(p.files, path)
+	}
+	stack = append(stack, path)
+
+	lines := strings.Split(string(data), "\n")
+	commented := commentedLines(lines)
+	version := -1
+	for i, line := range lines {
+		if commented[i] {
+			continue
+		}
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "#pragma once" {
+			p.once[path] = true
+		}
+		if version == -1 && strings.HasPrefix(trimmed, "#version") {
+			version = i
+		}
+	}
+	if len(stack) == 1 {
+		if version == -1 {
+			p.writeDefines()
+			fmt.Fprintf(&p.out, "#line 1 %d\n", index)
+		}
+	} else {
+		if version != -1 {
+			return fmt.Errorf("%s:%d: Only the top level shader file can have a #version", path, version+1)
+		}
+		fmt.Fprintf(&p.out, "#line 1 %d\n", index)
+	}
+
+	for i, line := range lines {
+		if i == len(lines)-1 && line == "" {
+			break
+		}
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "#pragma once" && !commented[i] {
+			// Keep the line so that the line numbers stay the same.
+			fmt.Fprintf(&p.out, "\n")
+			continue
+		}
+		if i == version {
+			fmt.Fprintf(&p.out, "%s\n", line)
+			p.writeDefines()
+			fmt.Fprintf(&p.out, "#line %d %d\n", i+2, index)
+			continue
+		}
+		if commented[i] || !strings.HasPrefix(trimmed, "#include") {
+			fmt.Fprintf(&p.out, "%s\n", line)
+			continue
+		}
+		arg := strings.TrimSpace(strings.TrimPrefix(trimmed, "#include"))
+		if len(arg) < 2 || arg[0] != '"' || arg[len(arg)-1] != '"' {
+			return fmt.Errorf("%s:%d: Expected #include \"file\", got '%s'", path, i+1, trimmed)
+		}
+		included := filepath.Join(filepath.Dir(path), arg[1:len(arg)-1])
+		if err := p.include(included, stack); err != nil {
+			return fmt.Errorf("%s:%d: %v", path, i+1, err)
+		}
+		fmt.Fprintf(&p.out, "#line %d %d\n", i+2, index)
+	}
+	return nil
+}
+
+// commentedLines returns whether each line starts inside of a /* */ comment.
+func commentedLines(lines []string) []bool {
+	commented := make([]bool, len(lines))
+	in_comment := false
+	for i, line := range lines {
+		commented[i] = in_comment
+		for j := 0; j < len(line)-1; j++ {
+			switch {
+			case in_comment && line[j:j+2] == "*/":
+				in_comment = false
+				j++
+			case !in_comment && line[j:j+2] == "/*":
+				in_comment = true
+				j++
+			case !in_comment && line[j:j+2] == "//":
+				j = len(line)
+			}
+		}
+	}
+	return commented
+}
+
+func (p *preprocessor) writeDefines() {
+	var keys []string
+	for key := range p.defines {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		fmt.Fprintf(&p.out, "#define %s %s\n", key, p.defines[key])
+	}
+}
+
+type fileStamp struct {
+	mod_time time.Time
+	size     int64
+}
+
+func stampFile(path string) fileStamp {
+	info, err := os.Stat(path)
+	if err != nil {
+		// Changes to a file that is missing or unreadable are noticed when
+		// it comes back.
+		return fileStamp{}
+	}
+	return fileStamp{info.ModTime(), info.Size()}
+}
+
+// A shader that was registered from files, which is recompiled when any of
+// them change.
+type shaderFiles struct {
+	vertex, fragment string
+	defines          map[string]string
+
+	// Every file that went into the shader, including included files.
+	stamps map[string]fileStamp
+}
+
+// Only accessed on the render thread.
+var shader_files map[string]*shaderFiles
+
+func init() {
+	shader_files = make(map[string]*shaderFiles)
+}
+
+// RegisterShaderFiles registers a shader from GLSL files, which are run
+// through PreprocessShader() with defines.  Unlike RegisterShader() the shader
+// is registered even if it fails to compile, so that it can be fixed without
+// restarting.  Until then EnableShader() returns an error for it.
+//
+// Must be called on the render thread.
+func RegisterShaderFiles(name, vertex_path, fragment_path string, defines map[string]string) error {
+	if _, ok := shader_progs[name]; ok {
+		return fmt.Errorf("Tried to register a shader called '%s' twice", name)
+	}
+	if _, ok := shader_files[name]; ok {
+		return fmt.Errorf("Tried to register a shader called '%s' twice", name)
+	}
+	sf := &shaderFiles{
+		vertex:   vertex_path,
+		fragment: fragment_path,
+		defines:  make(map[string]string),
+		stamps:   make(map[string]fileStamp),
+	}
+	for key, value := range defines {
+		sf.defines[key] = value
+	}
+	shader_files[name] = sf
+	return sf.load(name)
+}
+
+// load preprocesses and compiles the shader, replacing the current program
+// only if that works.
+func (sf *shaderFiles) load(name string) error {
+	vertex := preprocess(sf.vertex, sf.defines)
+	fragment := preprocess(sf.fragment, sf.defines)
+
+	// Watch everything that was read this time, with the stamps from before it
+	// was read.  If preprocessing failed part way through, keep watching the
+	// old files too since the fix might be in one of them.
+	stamps := make(map[string]fileStamp)
+	if vertex.err != nil || fragment.err != nil {
+		for path, stamp := range sf.stamps {
+			stamps[path] = stamp
+		}
+	}
+	for _, p := range []preprocessed{vertex, fragment} {
+		for path, stamp := range p.stamps {
+			stamps[path] = stamp
+		}
+	}
+	sf.stamps = stamps
+
+	if vertex.err != nil {
+		return fmt.Errorf("Failed to preprocess vertex shader '%s': %v", name, vertex.err)
+	}
+	if fragment.err != nil {
+		return fmt.Errorf("Failed to preprocess fragment shader '%s': %v", name, fragment.err)
+	}
+	if err := ReplaceShader(name, vertex.source, fragment.source); err != nil {
+		return fmt.Errorf("%v\nVertex shader files: %s\nFragment shader files: %s", err, sourceList(vertex.files), sourceList(fragment.files))
+	}
+	return nil
+}
+
+// sourceList lists files with the source string numbers that the compiler
+// uses for them in errors.
+func sourceList(files []string) string {
+	var parts []string
+	for i, file := range files {
+		parts = append(parts, fmt.Sprintf("%d = %s", i, file))
+	}
+	return strings.Join(parts, ", ")
+}
+
+func (sf *shaderFiles) changed() bool {
+	for path, stamp := range sf.stamps {
+		now := stampFile(path)
+		if !now.mod_time.Equal(stamp.mod_time) || now.size != stamp.size {
+			return true
+		}
+	}
+	return false
+}
+
+// ReloadChangedShaders recompiles every shader registered with
+// RegisterShaderFiles() that has a file that changed since it was last
+// compiled.  A shader that fails to compile keeps its old program, and the
+// errors are returned.
+//
+// Must be called on the render thread.
+func ReloadChangedShaders() []error {
+	var names []string
+	for name, sf := range shader_files {
+		if sf.changed() {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	var errs []error
+	for _, name := range names {
+		if err := shader_files[name].load(name); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errs
+}
+
+// WatchShaderFiles calls ReloadChangedShaders() on the render thread every
+// interval, at low priority, until stop is called.  Errors are passed to
+// on_error, or logged if it is nil.
+func WatchShaderFiles(interval time.Duration, on_error func(error)) (stop func()) {
+	if on_error == nil {
+		on_error = func(err error) {
+			log.Printf("%v", err)
+		}
+	}
+	done := make(chan struct{})
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				// If the render thread is that far behind it can check
+				// next time.
+				TryQueuePriority(PriorityLow, func() {
+					for _, err := range ReloadChangedShaders() {
+						on_error(err)
+					}
+				})
+			}
+		}
+	}()
+	var once sync.Once
+	return func() {
+		once.Do(func() { close(done) })
+	}
+}
@@ -0,0 +1,135 @@
+package render_test
+
+import (
+	"github.com/orfjackal/gospec/src/gospec"
+	. "github.com/orfjackal/gospec/src/gospec"
+	"github.com/runningwild/glop/render"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+func PreprocessShaderSpec(c gospec.Context) {
+	dir, err := ioutil.TempDir("", "glop-shaders")
+	c.Assume(err, IsNil)
+	defer os.RemoveAll(dir)
+	write := func(name, source string) string {
+		path := filepath.Join(dir, name)
+		os.MkdirAll(filepath.Dir(path), 0755)
+		c.Assume(ioutil.WriteFile(path, []byte(source), 0644), IsNil)
+		return path
+	}
+
+	c.Specify("Includes are expanded with #line directives.", func() {
+		write("lib/light.glsl", "float light() {\n  return 1.0;\n}\n")
+		write("common.glsl", "#include \"lib/light.glsl\"\nuniform float t;\n")
+		main := write("main.frag", "#version 330\n#include \"common.glsl\"\nvoid main() {}\n")
+		source, files, err := render.PreprocessShader(main, nil)
+		c.Assume(err, IsNil)
+		c.Expect(files, ContainsInOrder, []string{
+			main,
+			filepath.Join(dir, "common.glsl"),
+			filepath.Join(dir, "lib/light.glsl"),
+		})
+		c.Expect(string(source), Equals, strings.Join([]string{
+			"#version 330",
+			"#line 2 0",
+			"#line 1 1",
+			"#line 1 2",
+			"float light() {",
+			"  return 1.0;",
+			"}",
+			"#line 2 1",
+			"uniform float t;",
+			"#line 3 0",
+			"void main() {}",
+			"",
+		}, "\n"))
+	})
+
+	c.Specify("Defines go after #version, or first if there isn't one.", func() {
+		defines := map[string]string{"SHADOWS": "", "LIGHTS": "4"}
+		versioned := write("versioned.vert", "// A comment\n#version 330\nvoid main() {}\n")
+		source, _, err := render.PreprocessShader(versioned, defines)
+		c.Assume(err, IsNil)
+		c.Expect(string(source), Equals, "// A comment\n#version 330\n#define LIGHTS 4\n#define SHADOWS \n#line 3 0\nvoid main() {}\n")
+
+		plain := write("plain.vert", "void main() {}")
+		source, _, err = render.PreprocessShader(plain, defines)
+		c.Assume(err, IsNil)
+		c.Expect(string(source), Equals, "#define LIGHTS 4\n#define SHADOWS \n#line 1 0\nvoid main() {}\n")
+	})
+
+	c.Specify("Files with #pragma once are only included once.", func() {
+		write("base.glsl", "#pragma once\nfloat base() { return 1.0; }\n")
+		write("left.glsl", "#include \"base.glsl\"\nfloat left() { return base(); }\n")
+		write("right.glsl", "#include \"base.glsl\"\nfloat right() { return base(); }\n")
+		diamond := write("diamond.frag", "#include \"left.glsl\"\n#include \"right.glsl\"\n")
+		source, files, err := render.PreprocessShader(diamond, nil)
+		c.Assume(err, IsNil)
+		c.Expect(strings.Count(string(source), "float base()"), Equals, 1)
+		c.Expect(strings.Contains(string(source), "#pragma once"), Equals, false)
+		c.Expect(files, ContainsInOrder, []string{
+			diamond,
+			filepath.Join(dir, "left.glsl"),
+			filepath.Join(dir, "base.glsl"),
+			filepath.Join(dir, "right.glsl"),
+		})
+
+		write("twice.glsl", "float twice;\n")
+		repeated := write("repeated.frag", "#include \"twice.glsl\"\n#include \"twice.glsl\"\n")
+		source, _, err = render.PreprocessShader(repeated, nil)
+		c.Assume(err, IsNil)
+		c.Expect(strings.Count(string(source), "float twice;"), Equals, 2)
+	})
+
+	c.Specify("Directives in block comments are left alone.", func() {
+		commented := write("commented.frag", strings.Join([]string{
+			"#version 330",
+			"/* Usage:",
+			"#include \"nope.glsl\"",
+			"#pragma once",
+			"*/ // #include \"nope.glsl\" /*",
+			"void main() {}",
+			"",
+		}, "\n"))
+		source, files, err := render.PreprocessShader(commented, nil)
+		c.Assume(err, IsNil)
+		c.Expect(files, ContainsInOrder, []string{commented})
+		c.Expect(string(source), Equals, strings.Join([]string{
+			"#version 330",
+			"#line 2 0",
+			"/* Usage:",
+			"#include \"nope.glsl\"",
+			"#pragma once",
+			"*/ // #include \"nope.glsl\" /*",
+			"void main() {}",
+			"",
+		}, "\n"))
+	})
+
+	c.Specify("Bad includes are errors.", func() {
+		write("a.glsl", "#include \"b.glsl\"\n")
+		write("b.glsl", "\n#include \"a.glsl\"\n")
+		cycle := write("cycle.frag", "#include \"a.glsl\"\n")
+		_, _, err := render.PreprocessShader(cycle, nil)
+		c.Assume(err, Not(IsNil))
+		c.Expect(strings.Contains(err.Error(), "b.glsl:2"), Equals, true)
+		c.Expect(strings.Contains(err.Error(), "includes itself"), Equals, true)
+
+		missing := write("missing.frag", "#include \"nope.glsl\"\n")
+		_, files, err := render.PreprocessShader(missing, nil)
+		c.Expect(err, Not(IsNil))
+		c.Expect(files, ContainsInOrder, []string{missing})
+
+		unquoted := write("unquoted.frag", "#include common.glsl\n")
+		_, _, err = render.PreprocessShader(unquoted, nil)
+		c.Expect(err, Not(IsNil))
+
+		write("versioned.glsl", "#version 330\n")
+		nested_version := write("nested.frag", "#version 330\n#include \"versioned.glsl\"\n")
+		_, _, err = render.PreprocessShader(nested_version, nil)
+		c.Expect(err, Not(IsNil))
+	})
+}
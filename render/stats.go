@@ -0,0 +1,151 @@
+package render
+
+import (
+	"fmt"
+	"runtime"
+	"sync"
+	"time"
+)
+
+// LaneStats are the statistics for one priority lane of the render queue.
+type LaneStats struct {
+	// How many functions are waiting in the lane right now, and the most that
+	// have been waiting at once.
+	Depth, MaxDepth int
+
+	// How many functions have been queued in the lane, and how many have run.
+	Queued, Run int64
+
+	// Total time spent running functions from the lane, and the longest that
+	// any one of them took.
+	Busy, Longest time.Duration
+
+	// How many times queueing blocked because the lane was full, and the total
+	// time spent blocked.
+	Stalls  int64
+	Stalled time.Duration
+}
+
+// FuncStats are the statistics for all of the functions queued from one place
+// in the code.
+type FuncStats struct {
+	Run           int64
+	Busy, Longest time.Duration
+}
+
+type Stats struct {
+	Lanes [NumPriorities]LaneStats
+
+	// Keyed by the file:line that the functions were queued from.
+	Funcs map[string]FuncStats
+}
+
+// A SlowFuncHandler is called by the render thread after running a function
+// that took longer than the threshold given to SetSlowFuncHandler().  caller is
+// the file:line that the function was queued from.
+type SlowFuncHandler func(caller string, p Priority, d time.Duration)
+
+type renderStats struct {
+	sync.Mutex
+	lanes [NumPriorities]LaneStats
+
+	// Keyed by program counter, they are only turned into file:line when
+	// someone asks for them.
+	funcs map[uintptr]FuncStats
+
+	slow_threshold time.Duration
+	slow_handler   SlowFuncHandler
+}
+
+var stats = renderStats{funcs: make(map[uintptr]FuncStats)}
+
+func (s *renderStats) queued(p Priority) {
+	depth := len(lanes[p])
+	s.Lock()
+	defer s.Unlock()
+	s.lanes[p].Queued++
+	if depth > s.lanes[p].MaxDepth {
+		s.lanes[p].MaxDepth = depth
+	}
+}
+
+func (s *renderStats) stalled(p Priority, d time.Duration) {
+	s.Lock()
+	defer s.Unlock()
+	s.lanes[p].Stalls++
+	s.lanes[p].Stalled += d
+}
+
+func (s *renderStats) ran(p Priority, caller uintptr, d time.Duration) {
+	s.Lock()
+	lane := &s.lanes[p]
+	lane.Run++
+	lane.Busy += d
+	if d > lane.Longest {
+		lane.Longest = d
+	}
+	fs := s.funcs[caller]
+	fs.Run++
+	fs.Busy += d
+	if d > fs.Longest {
+		fs.Longest = d
+	}
+	s.funcs[caller] = fs
+	handler := s.slow_handler
+	slow := handler != nil && d > s.slow_threshold
+	s.Unlock()
+
+	if slow {
+		handler(callerName(caller), p, d)
+	}
+}
+
+func callerName(pc uintptr) string {
+	if pc == 0 {
+		return "unknown"
+	}
+	frame, _ := runtime.CallersFrames([]uintptr{pc}).Next()
+	return fmt.Sprintf("%s:%d", frame.File, frame.Line)
+}
+
+// GetStats returns the statistics for the render queue since the program
+// started or since the last call to ResetStats().
+func GetStats() Stats {
+	stats.Lock()
+	defer stats.Unlock()
+	ret := Stats{Lanes: stats.lanes, Funcs: make(map[string]FuncStats)}
+	for i := range ret.Lanes {
+		ret.Lanes[i].Depth = len(lanes[i])
+	}
+	for pc, fs := range stats.funcs {
+		// Different program counters can be on the same line.
+		name := callerName(pc)
+		total := ret.Funcs[name]
+		total.Run += fs.Run
+		total.Busy += fs.Busy
+		if fs.Longest > total.Longest {
+			total.Longest = fs.Longest
+		}
+		ret.Funcs[name] = total
+	}
+	return ret
+}
+
+// ResetStats clears all of the render queue statistics, e.g. at the start of a
+// frame so that GetStats() only covers that frame.
+func ResetStats() {
+	stats.Lock()
+	defer stats.Unlock()
+	stats.lanes = [NumPriorities]LaneStats{}
+	stats.funcs = make(map[uintptr]FuncStats)
+}
+
+// SetSlowFuncHandler sets a handler to be called on the render thread whenever
+// a function takes longer than threshold to run, to help find frame hitches.
+// A nil handler turns this off.
+func SetSlowFuncHandler(threshold time.Duration, handler SlowFuncHandler) {
+	stats.Lock()
+	defer stats.Unlock()
+	stats.slow_threshold = threshold
+	stats.slow_handler = handler
+}
@@ -0,0 +1,376 @@
+package render
+
+import (
+	"fmt"
+	"github.com/go-gl/gl/v3.3-core/gl"
+	"sort"
+	"strings"
+)
+
+// A Uniform is an active uniform variable in a shader program, as reported by
+// OpenGL after the program was linked.  Uniforms that the compiler found were
+// unused are not active, so they are not listed and can't be set.
+type Uniform struct {
+	// Arrays are named without the [0] that OpenGL puts on the end.
+	Name string
+
+	// The OpenGL type, e.g. gl.FLOAT_VEC3.
+	Type uint32
+
+	// Number of elements, which is 1 unless the uniform is an array.
+	Size int
+
+	Location int32
+}
+
+type uniformType struct {
+	name string
+
+	// Number of floats or ints in one element.
+	components int
+
+	// True for types that are set with ints, which includes bools and
+	// samplers.
+	ints bool
+
+	sampler bool
+}
+
+var uniform_types = map[uint32]uniformType{
+	gl.FLOAT:                        {"float", 1, false, false},
+	gl.FLOAT_VEC2:                   {"vec2", 2, false, false},
+	gl.FLOAT_VEC3:                   {"vec3", 3, false, false},
+	gl.FLOAT_VEC4:                   {"vec4", 4, false, false},
+	gl.FLOAT_MAT2:                   {"mat2", 4, false, false},
+	gl.FLOAT_MAT3:                   {"mat3", 9, false, false},
+	gl.FLOAT_MAT4:                   {"mat4", 16, false, false},
+	gl.INT:                          {"int", 1, true, false},
+	gl.INT_VEC2:                     {"ivec2", 2, true, false},
+	gl.INT_VEC3:                     {"ivec3", 3, true, false},
+	gl.INT_VEC4:                     {"ivec4", 4, true, false},
+	gl.BOOL:                         {"bool", 1, true, false},
+	gl.BOOL_VEC2:                    {"bvec2", 2, true, false},
+	gl.BOOL_VEC3:                    {"bvec3", 3, true, false},
+	gl.BOOL_VEC4:                    {"bvec4", 4, true, false},
+	gl.SAMPLER_1D:                   {"sampler1D", 1, true, true},
+	gl.SAMPLER_2D:                   {"sampler2D", 1, true, true},
+	gl.SAMPLER_3D:                   {"sampler3D", 1, true, true},
+	gl.SAMPLER_CUBE:                 {"samplerCube", 1, true, true},
+	gl.SAMPLER_2D_SHADOW:            {"sampler2DShadow", 1, true, true},
+	gl.SAMPLER_2D_ARRAY:             {"sampler2DArray", 1, true, true},
+	gl.SAMPLER_2D_RECT:              {"sampler2DRect", 1, true, true},
+	gl.SAMPLER_BUFFER:               {"samplerBuffer", 1, true, true},
+	gl.SAMPLER_2D_MULTISAMPLE:       {"sampler2DMS", 1, true, true},
+	gl.INT_SAMPLER_2D:               {"isampler2D", 1, true, true},
+	gl.UNSIGNED_INT_SAMPLER_2D:      {"usampler2D", 1, true, true},
+	gl.SAMPLER_2D_ARRAY_SHADOW:      {"sampler2DArrayShadow", 1, true, true},
+	gl.SAMPLER_CUBE_SHADOW:          {"samplerCubeShadow", 1, true, true},
+	gl.SAMPLER_1D_ARRAY:             {"sampler1DArray", 1, true, true},
+	gl.SAMPLER_1D_SHADOW:            {"sampler1DShadow", 1, true, true},
+	gl.SAMPLER_2D_RECT_SHADOW:       {"sampler2DRectShadow", 1, true, true},
+	gl.SAMPLER_2D_MULTISAMPLE_ARRAY: {"sampler2DMSArray", 1, true, true},
+}
+
+// TypeName returns the GLSL name of the uniform's type, e.g. "vec3".
+func (u Uniform) TypeName() string {
+	if t, ok := uniform_types[u.Type]; ok {
+		return t.name
+	}
+	return fmt.Sprintf("type 0x%x", u.Type)
+}
+
+// introspectUniforms lists the active uniforms in a program that has just been
+// linked, keyed by name.  Uniforms in uniform blocks are left out since they
+// aren't set with glUniform*().
+func introspectUniforms(prog uint32) map[string]Uniform {
+	var count, max_length int32
+	gl.GetProgramiv(prog, gl.ACTIVE_UNIFORMS, &count)
+	gl.GetProgramiv(prog, gl.ACTIVE_UNIFORM_MAX_LENGTH, &max_length)
+	uniforms := make(map[string]Uniform)
+	if count == 0 {
+		return uniforms
+	}
+	buf := make([]byte, max_length+1)
+	for i := uint32(0); i < uint32(count); i++ {
+		var length, size int32
+		var xtype uint32
+		gl.GetActiveUniform(prog, i, int32(len(buf)), &length, &size, &xtype, &buf[0])
+		name := string(buf[:length])
+		location := gl.GetUniformLocation(prog, gl.Str(name+"\x00"))
+		if location < 0 {
+			continue
+		}
+		name = strings.TrimSuffix(name, "[0]")
+		uniforms[name] = Uniform{
+			Name:     name,
+			Type:     xtype,
+			Size:     int(size),
+			Location: location,
+		}
+	}
+	return uniforms
+}
+
+// GetUniforms returns the active uniforms in a shader, sorted by name.
+func GetUniforms(shader string) ([]Uniform, error) {
+	prog, ok := shader_progs[shader]
+	if !ok {
+		return nil, fmt.Errorf("No shader named '%s'", shader)
+	}
+	var uniforms []Uniform
+	for _, u := range prog.uniforms {
+		uniforms = append(uniforms, u)
+	}
+	sort.Slice(uniforms, func(i, j int) bool { return uniforms[i].Name < uniforms[j].Name })
+	return uniforms, nil
+}
+
+// GetUniform returns the active uniform called variable in a shader, or an
+// error if there isn't one.  Arrays can be looked up with or without [0].
+func GetUniform(shader, variable string) (Uniform, error) {
+	prog, ok := shader_progs[shader]
+	if !ok {
+		return Uniform{}, fmt.Errorf("No shader named '%s'", shader)
+	}
+	u, ok := prog.uniforms[strings.TrimSuffix(variable, "[0]")]
+	if !ok {
+		return Uniform{}, fmt.Errorf("Shader '%s' has no active uniform '%s'", shader, variable)
+	}
+	return u, nil
+}
+
+// lookupUniform returns the uniform called variable in shader, if it has one of
+// types, which are GLSL type names.
+func lookupUniform(shader, variable string, types ...string) (Uniform, error) {
+	u, err := GetUniform(shader, variable)
+	if err != nil {
+		return u, err
+	}
+	name := u.TypeName()
+	for _, t := range types {
+		if t == name {
+			return u, nil
+		}
+	}
+	return u, fmt.Errorf("Uniform '%s' in shader '%s' is a %s, not a %s", variable, shader, name, strings.Join(types, " or "))
+}
+
+func checkLength(variable string, values, expected int) error {
+	if values != expected {
+		return fmt.Errorf("Uniform '%s' needs %d values, got %d", variable, expected, values)
+	}
+	return nil
+}
+
+// The setters below must be called on the render thread.  Each one returns an
+// error, without setting anything, if the shader has no active uniform by that
+// name, or if the uniform's type doesn't match the setter.
+
+// SetUniformI sets an int, bool or sampler uniform.
+func SetUniformI(shader, variable string, n int32) error {
+	u, err := GetUniform(shader, variable)
+	if err != nil {
+		return err
+	}
+	if t := uniform_types[u.Type]; !t.ints || t.components != 1 {
+		return fmt.Errorf("Uniform '%s' in shader '%s' is a %s, not an int, bool or sampler", variable, shader, u.TypeName())
+	}
+	gl.Uniform1i(u.Location, n)
+	return nil
+}
+
+func SetUniform2I(shader, variable string, vs []int32) error {
+	u, err := lookupUniform(shader, variable, "ivec2", "bvec2")
+	if err == nil {
+		err = checkLength(variable, len(vs), 2)
+	}
+	if err != nil {
+		return err
+	}
+	gl.Uniform2i(u.Location, vs[0], vs[1])
+	return nil
+}
+
+func SetUniform3I(shader, variable string, vs []int32) error {
+	u, err := lookupUniform(shader, variable, "ivec3", "bvec3")
+	if err == nil {
+		err = checkLength(variable, len(vs), 3)
+	}
+	if err != nil {
+		return err
+	}
+	gl.Uniform3i(u.Location, vs[0], vs[1], vs[2])
+	return nil
+}
+
+func SetUniform4I(shader, variable string, vs []int32) error {
+	u, err := lookupUniform(shader, variable, "ivec4", "bvec4")
+	if err == nil {
+		err = checkLength(variable, len(vs), 4)
+	}
+	if err != nil {
+		return err
+	}
+	gl.Uniform4i(u.Location, vs[0], vs[1], vs[2], vs[3])
+	return nil
+}
+
+func lookupSampler(shader, variable string) (Uniform, error) {
+	u, err := GetUniform(shader, variable)
+	if err != nil {
+		return u, err
+	}
+	if !uniform_types[u.Type].sampler {
+		return u, fmt.Errorf("Uniform '%s' in shader '%s' is a %s, not a sampler", variable, shader, u.TypeName())
+	}
+	return u, nil
+}
+
+// SetUniformSampler sets a sampler uniform to use texture unit unit, i.e. the
+// texture bound after gl.ActiveTexture(gl.TEXTURE0 + unit).
+func SetUniformSampler(shader, variable string, unit int32) error {
+	u, err := lookupSampler(shader, variable)
+	if err != nil {
+		return err
+	}
+	gl.Uniform1i(u.Location, unit)
+	return nil
+}
+
+func SetUniformF(shader, variable string, f float32) error {
+	u, err := lookupUniform(shader, variable, "float")
+	if err != nil {
+		return err
+	}
+	gl.Uniform1f(u.Location, f)
+	return nil
+}
+
+func SetUniform2F(shader, variable string, vs []float32) error {
+	u, err := lookupUniform(shader, variable, "vec2")
+	if err == nil {
+		err = checkLength(variable, len(vs), 2)
+	}
+	if err != nil {
+		return err
+	}
+	gl.Uniform2f(u.Location, vs[0], vs[1])
+	return nil
+}
+
+func SetUniform3F(shader, variable string, vs []float32) error {
+	u, err := lookupUniform(shader, variable, "vec3")
+	if err == nil {
+		err = checkLength(variable, len(vs), 3)
+	}
+	if err != nil {
+		return err
+	}
+	gl.Uniform3f(u.Location, vs[0], vs[1], vs[2])
+	return nil
+}
+
+func SetUniform4F(shader, variable string, vs []float32) error {
+	u, err := lookupUniform(shader, variable, "vec4")
+	if err == nil {
+		err = checkLength(variable, len(vs), 4)
+	}
+	if err != nil {
+		return err
+	}
+	gl.Uniform4f(u.Location, vs[0], vs[1], vs[2], vs[3])
+	return nil
+}
+
+// SetUniformMat3 sets a mat3 uniform from 9 floats in column-major order.
+func SetUniformMat3(shader, variable string, m []float32) error {
+	u, err := lookupUniform(shader, variable, "mat3")
+	if err == nil {
+		err = checkLength(variable, len(m), 9)
+	}
+	if err != nil {
+		return err
+	}
+	gl.UniformMatrix3fv(u.Location, 1, false, &m[0])
+	return nil
+}
+
+// SetUniformMat4 sets a mat4 uniform from 16 floats in column-major order.
+func SetUniformMat4(shader, variable string, m []float32) error {
+	u, err := lookupUniform(shader, variable, "mat4")
+	if err == nil {
+		err = checkLength(variable, len(m), 16)
+	}
+	if err != nil {
+		return err
+	}
+	gl.UniformMatrix4fv(u.Location, 1, false, &m[0])
+	return nil
+}
+
+// arrayCount returns how many elements of u vs has values for.
+func arrayCount(u Uniform, variable string, values int) (int32, error) {
+	components := uniform_types[u.Type].components
+	if values == 0 || values%components != 0 || values/components > u.Size {
+		return 0, fmt.Errorf("Uniform '%s' is a %s[%d], which can't be set from %d values", variable, u.TypeName(), u.Size, values)
+	}
+	return int32(values / components), nil
+}
+
+// SetUniformFloats sets any float, vector or matrix uniform, or the first
+// len(vs) / components elements of an array of them.  Matrices are in
+// column-major order.
+func SetUniformFloats(shader, variable string, vs []float32) error {
+	u, err := lookupUniform(shader, variable, "float", "vec2", "vec3", "vec4", "mat2", "mat3", "mat4")
+	if err != nil {
+		return err
+	}
+	count, err := arrayCount(u, variable, len(vs))
+	if err != nil {
+		return err
+	}
+	switch u.Type {
+	case gl.FLOAT:
+		gl.Uniform1fv(u.Location, count, &vs[0])
+	case gl.FLOAT_VEC2:
+		gl.Uniform2fv(u.Location, count, &vs[0])
+	case gl.FLOAT_VEC3:
+		gl.Uniform3fv(u.Location, count, &vs[0])
+	case gl.FLOAT_VEC4:
+		gl.Uniform4fv(u.Location, count, &vs[0])
+	case gl.FLOAT_MAT2:
+		gl.UniformMatrix2fv(u.Location, count, false, &vs[0])
+	case gl.FLOAT_MAT3:
+		gl.UniformMatrix3fv(u.Location, count, false, &vs[0])
+	case gl.FLOAT_MAT4:
+		gl.UniformMatrix4fv(u.Location, count, false, &vs[0])
+	}
+	return nil
+}
+
+// SetUniformInts sets any int, bool or sampler uniform, or vector of them, or
+// the first len(vs) / components elements of an array of them.
+func SetUniformInts(shader, variable string, vs []int32) error {
+	u, err := GetUniform(shader, variable)
+	if err != nil {
+		return err
+	}
+	if !uniform_types[u.Type].ints {
+		return fmt.Errorf("Uniform '%s' in shader '%s' is a %s, not an int, bool or sampler type", variable, shader, u.TypeName())
+	}
+	count, err := arrayCount(u, variable, len(vs))
+	if err != nil {
+		return err
+	}
+	switch uniform_types[u.Type].components {
+	case 1:
+		gl.Uniform1iv(u.Location, count, &vs[0])
+	case 2:
+		gl.Uniform2iv(u.Location, count, &vs[0])
+	case 3:
+		gl.Uniform3iv(u.Location, count, &vs[0])
+	case 4:
+		gl.Uniform4iv(u.Location, count, &vs[0])
+	}
+	return nil
+}
@@ -0,0 +1,98 @@
+package render
+
+import (
+	"github.com/go-gl/gl/v3.3-core/gl"
+	"github.com/orfjackal/gospec/src/gospec"
+	. "github.com/orfjackal/gospec/src/gospec"
+)
+
+// UniformSpec is in package render so that it can register a shader without
+// OpenGL, it's exported so that TestAllSpecs can run it.
+func UniformSpec(c gospec.Context) {
+	shader_progs["spec uniforms"] = &shaderProgram{
+		uniforms: map[string]Uniform{
+			"color":  {Name: "color", Type: gl.FLOAT_VEC3, Size: 1, Location: 0},
+			"lights": {Name: "lights", Type: gl.FLOAT_VEC3, Size: 4, Location: 1},
+			"tex":    {Name: "tex", Type: gl.SAMPLER_2D, Size: 1, Location: 5},
+			"odd":    {Name: "odd", Type: 0x1234, Size: 1, Location: 6},
+		},
+	}
+	defer delete(shader_progs, "spec uniforms")
+
+	c.Specify("Arrays can be looked up with or without [0].", func() {
+		u, err := GetUniform("spec uniforms", "lights")
+		c.Assume(err, IsNil)
+		c.Expect(u.Location, Equals, int32(1))
+		u, err = GetUniform("spec uniforms", "lights[0]")
+		c.Assume(err, IsNil)
+		c.Expect(u.Location, Equals, int32(1))
+		_, err = GetUniform("spec uniforms", "lights[1]")
+		c.Expect(err, Not(IsNil))
+
+		uniforms, err := GetUniforms("spec uniforms")
+		c.Assume(err, IsNil)
+		var names []string
+		for _, u := range uniforms {
+			names = append(names, u.Name)
+		}
+		c.Expect(names, ContainsInOrder, []string{"color", "lights", "odd", "tex"})
+	})
+
+	c.Specify("Missing shaders and uniforms are errors.", func() {
+		_, err := GetUniform("no such shader", "color")
+		c.Assume(err, Not(IsNil))
+		c.Expect(err.Error(), Equals, "No shader named 'no such shader'")
+		_, err = GetUniform("spec uniforms", "unused")
+		c.Assume(err, Not(IsNil))
+		c.Expect(err.Error(), Equals, "Shader 'spec uniforms' has no active uniform 'unused'")
+		_, err = GetUniformLocation("spec uniforms", "unused")
+		c.Expect(err, Not(IsNil))
+	})
+
+	c.Specify("Uniforms must have one of the expected types.", func() {
+		u, err := lookupUniform("spec uniforms", "color", "vec2", "vec3")
+		c.Assume(err, IsNil)
+		c.Expect(u.Name, Equals, "color")
+		_, err = lookupUniform("spec uniforms", "color", "vec4", "ivec4")
+		c.Assume(err, Not(IsNil))
+		c.Expect(err.Error(), Equals, "Uniform 'color' in shader 'spec uniforms' is a vec3, not a vec4 or ivec4")
+
+		// These all fail before they get to OpenGL.
+		c.Expect(SetUniformF("spec uniforms", "color", 1), Not(IsNil))
+		c.Expect(SetUniformI("spec uniforms", "color", 1), Not(IsNil))
+		c.Expect(SetUniform3F("spec uniforms", "tex", []float32{1, 2, 3}), Not(IsNil))
+		c.Expect(SetUniform3F("spec uniforms", "color", []float32{1, 2}), Not(IsNil))
+		c.Expect(SetUniformSampler("spec uniforms", "color", 0), Not(IsNil))
+		c.Expect(SetUniformFloats("spec uniforms", "odd", []float32{1}), Not(IsNil))
+	})
+
+	c.Specify("Arrays can be set from whole elements, up to their size.", func() {
+		lights, _ := GetUniform("spec uniforms", "lights")
+		color, _ := GetUniform("spec uniforms", "color")
+		count, err := arrayCount(lights, "lights", 12)
+		c.Expect(err, IsNil)
+		c.Expect(count, Equals, int32(4))
+		count, err = arrayCount(lights, "lights", 6)
+		c.Expect(err, IsNil)
+		c.Expect(count, Equals, int32(2))
+		count, err = arrayCount(color, "color", 3)
+		c.Expect(err, IsNil)
+		c.Expect(count, Equals, int32(1))
+
+		for _, values := range []int{0, 7, 15} {
+			_, err = arrayCount(lights, "lights", values)
+			c.Expect(err, Not(IsNil))
+		}
+		_, err = arrayCount(color, "color", 6)
+		c.Assume(err, Not(IsNil))
+		c.Expect(err.Error(), Equals, "Uniform 'color' is a vec3[1], which can't be set from 6 values")
+	})
+
+	c.Specify("Unknown types are named by number.", func() {
+		c.Expect(Uniform{Type: gl.FLOAT_MAT4}.TypeName(), Equals, "mat4")
+		c.Expect(Uniform{Type: 0x1234}.TypeName(), Equals, "type 0x1234")
+		_, err := lookupUniform("spec uniforms", "odd", "float")
+		c.Assume(err, Not(IsNil))
+		c.Expect(err.Error(), Equals, "Uniform 'odd' in shader 'spec uniforms' is a type 0x1234, not a float")
+	})
+}
@@ -0,0 +1,59 @@
+package system
+
+import (
+	"fmt"
+	"image"
+)
+
+// A StandardCursor is one of the cursors that the OS provides, drawn in the
+// user's cursor theme.
+type StandardCursor int
+
+const (
+	CursorArrow StandardCursor = iota
+	CursorIBeam
+	CursorCrosshair
+	CursorHand
+	CursorWait
+	CursorResizeHorizontal
+	CursorResizeVertical
+	CursorResizeAll
+	CursorNotAllowed
+)
+
+func (sc StandardCursor) String() string {
+	switch sc {
+	case CursorArrow:
+		return "arrow"
+	case CursorIBeam:
+		return "i-beam"
+	case CursorCrosshair:
+		return "crosshair"
+	case CursorHand:
+		return "hand"
+	case CursorWait:
+		return "wait"
+	case CursorResizeHorizontal:
+		return "resize horizontal"
+	case CursorResizeVertical:
+		return "resize vertical"
+	case CursorResizeAll:
+		return "resize all"
+	case CursorNotAllowed:
+		return "not allowed"
+	}
+	panic(fmt.Sprintf("%d is not a valid StandardCursor", sc))
+}
+
+// CheckCursorImage returns an error if the arguments to SetCursorImage() don't
+// make sense.  Implementations of Os can use this to validate their arguments.
+func CheckCursorImage(img image.Image, hot_x, hot_y int) error {
+	if img == nil {
+		return nil
+	}
+	hot := image.Pt(hot_x, hot_y).Add(img.Bounds().Min)
+	if !hot.In(img.Bounds()) {
+		return fmt.Errorf("Cursor hot spot (%d, %d) is outside of the image bounds %v.", hot_x, hot_y, img.Bounds())
+	}
+	return nil
+}
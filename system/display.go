@@ -0,0 +1,133 @@
+package system
+
+import (
+	"fmt"
+	"sort"
+)
+
+// A DisplayMode is a resolution and refresh rate that a monitor supports.
+type DisplayMode struct {
+	Width, Height int
+
+	// In Hz.  This is 0 if the OS doesn't say.
+	Refresh float64
+}
+
+func (dm DisplayMode) String() string {
+	return fmt.Sprintf("%dx%d@%.2fHz", dm.Width, dm.Height, dm.Refresh)
+}
+
+// A Monitor is a single display attached to the system.
+type Monitor struct {
+	Name string
+
+	// Where the monitor is on the desktop, and its size, in pixels.
+	X, Y, Dx, Dy int
+
+	// True iff this is the monitor that windows go on by default.
+	Primary bool
+
+	// The mode that the monitor is currently using, and every mode that it
+	// supports, sorted from largest to smallest and from fastest to slowest.
+	Current DisplayMode
+	Modes   []DisplayMode
+
+	// How much bigger than normal things need to be drawn to look the right
+	// size on this monitor, e.g. 2 for most HiDPI monitors.
+	ContentScale float64
+}
+
+// HasMode returns true iff mode is one of m.Modes.
+func (m *Monitor) HasMode(mode DisplayMode) bool {
+	for _, have := range m.Modes {
+		if have == mode {
+			return true
+		}
+	}
+	return false
+}
+
+type WindowMode int
+
+const (
+	// A normal window with a border and a title bar.
+	Windowed WindowMode = iota
+
+	// A window without a border that covers an entire monitor, which stays at
+	// its current display mode.  Switching to and from this mode is fast and
+	// doesn't disturb other windows.
+	BorderlessFullscreen
+
+	// A window that covers an entire monitor, which is switched to a specific
+	// display mode while the window has it.
+	ExclusiveFullscreen
+)
+
+func (wm WindowMode) String() string {
+	switch wm {
+	case Windowed:
+		return "windowed"
+	case BorderlessFullscreen:
+		return "borderless fullscreen"
+	case ExclusiveFullscreen:
+		return "exclusive fullscreen"
+	}
+	panic(fmt.Sprintf("%d is not a valid WindowMode", wm))
+}
+
+// CheckWindowMode returns an error if the arguments to SetWindowMode() don't
+// make sense for monitors, as returned by GetMonitors().  Implementations of
+// Os can use this to validate their arguments.
+func CheckWindowMode(monitors []Monitor, mode WindowMode, monitor int, display_mode DisplayMode) error {
+	if mode == Windowed {
+		return nil
+	}
+	if mode != BorderlessFullscreen && mode != ExclusiveFullscreen {
+		return fmt.Errorf("Unknown window mode %d.", mode)
+	}
+	if monitor < 0 || monitor >= len(monitors) {
+		return fmt.Errorf("There is no monitor %d, there are only %d.", monitor, len(monitors))
+	}
+	if mode == ExclusiveFullscreen && !monitors[monitor].HasMode(display_mode) {
+		return fmt.Errorf("Monitor '%s' doesn't support %v.", monitors[monitor].Name, display_mode)
+	}
+	return nil
+}
+
+type displayModeSlice []DisplayMode
+
+func (dms displayModeSlice) Len() int      { return len(dms) }
+func (dms displayModeSlice) Swap(i, j int) { dms[i], dms[j] = dms[j], dms[i] }
+func (dms displayModeSlice) Less(i, j int) bool {
+	if dms[i].Width != dms[j].Width {
+		return dms[i].Width > dms[j].Width
+	}
+	if dms[i].Height != dms[j].Height {
+		return dms[i].Height > dms[j].Height
+	}
+	return dms[i].Refresh > dms[j].Refresh
+}
+
+// SortDisplayModes sorts modes from largest to smallest and from fastest to
+// slowest, and removes duplicates, which is the order that Monitor.Modes is in.
+func SortDisplayModes(modes []DisplayMode) []DisplayMode {
+	sort.Sort(displayModeSlice(modes))
+	var ret []DisplayMode
+	for i, mode := range modes {
+		if i == 0 || mode != modes[i-1] {
+			ret = append(ret, mode)
+		}
+	}
+	return ret
+}
+
+// MonitorAt returns the index in monitors of the monitor that contains the
+// point x, y, in desktop coordinates, or -1 if none of them do.
+func MonitorAt(monitors []Monitor, x, y int) int {
+	for i, m := range monitors {
+		if x >= m.X && x < m.X+m.Dx && y >= m.Y && y < m.Y+m.Dy {
+			return i
+		}
+	}
+	return -1
+}
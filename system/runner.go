@@ -0,0 +1,181 @@
+package system
+
+import (
+	"fmt"
+	"github.com/runningwild/glop/render"
+	"sync"
+	"time"
+)
+
+// A Runner is a main loop with a fixed simulation rate.  The simulation is
+// advanced in ticks of exactly the same length, no matter how long each frame
+// takes to draw, so it behaves the same on every machine.  Each frame is drawn
+// with an interpolation alpha, which is how far the current time is between
+// the most recent tick and the next one, so that motion stays smooth when the
+// frame rate and the tick rate differ.
+//
+// Every frame the Runner calls, in this order:
+//
+//	System.Think(), on the calling thread,
+//	update, once for each tick that is due, on the calling thread,
+//	draw and then System.SwapBuffers(), on the render thread,
+//
+// and then waits for the render thread to finish, so that update never runs
+// at the same time as draw.
+type Runner struct {
+	sys    System
+	tick   time.Duration
+	update func()
+	draw   func(alpha float64)
+
+	// Frames that take longer than this are treated as if they took exactly
+	// this long.
+	max_frame time.Duration
+
+	clock func() time.Duration
+
+	// Protects everything below, which can be changed from any goroutine.
+	mutex  sync.Mutex
+	paused bool
+	quit   bool
+	ticks  int64
+	frames int64
+}
+
+// DefaultMaxFrameTime is the longest that a single frame counts for unless
+// SetMaxFrameTime() is used.
+const DefaultMaxFrameTime = 250 * time.Millisecond
+
+// MakeRunner returns a Runner that calls update ticks_per_second times per
+// second and draw once per frame.  draw may be nil.
+func MakeRunner(sys System, ticks_per_second int, update func(), draw func(alpha float64)) *Runner {
+	if ticks_per_second <= 0 {
+		panic(fmt.Sprintf("A Runner needs a positive tick rate, not %d.", ticks_per_second))
+	}
+	start := time.Now()
+	return &Runner{
+		sys:       sys,
+		tick:      time.Second / time.Duration(ticks_per_second),
+		update:    update,
+		draw:      draw,
+		max_frame: DefaultMaxFrameTime,
+		clock: func() time.Duration {
+			return time.Since(start)
+		},
+	}
+}
+
+// SetMaxFrameTime caps how long a single frame can count for.  Without a cap
+// a long frame, from loading a level, hitting a breakpoint or dragging the
+// window, would be followed by a burst of ticks to catch up, which makes the
+// next frame slow as well.  With the cap the simulation just falls behind the
+// clock.  Call this before Run().
+func (r *Runner) SetMaxFrameTime(max time.Duration) {
+	r.max_frame = max
+}
+
+// SetClock replaces the clock that the Runner uses to time frames, which
+// should return the time since some fixed point.  This is mostly for tests.
+// Call this before Run().
+func (r *Runner) SetClock(clock func() time.Duration) {
+	r.clock = clock
+}
+
+// Pause stops calling update until Resume() is called.  The System still
+// thinks and frames are still drawn, with the same alpha as the frame before
+// the pause, and time spent paused is not made up when resuming.
+func (r *Runner) Pause() {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.paused = true
+}
+
+func (r *Runner) Resume() {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.paused = false
+}
+
+func (r *Runner) Paused() bool {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	return r.paused
+}
+
+// Quit makes Run() return once the current tick, or frame, is done.  No more
+// ticks or frames are started after Quit is called.  It is safe to call from
+// any goroutine, including from update and draw.
+func (r *Runner) Quit() {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.quit = true
+}
+
+func (r *Runner) quitting() bool {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	return r.quit
+}
+
+// Ticks returns the number of times that update has been called.
+func (r *Runner) Ticks() int64 {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	return r.ticks
+}
+
+// Frames returns the number of frames that have been drawn.
+func (r *Runner) Frames() int64 {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	return r.frames
+}
+
+// Run runs the loop until Quit() is called.  Like System.Think(), call Run()
+// from the thread that called System.Startup().
+func (r *Runner) Run() {
+	render.Init()
+	last := r.clock()
+	var behind time.Duration
+	alpha := 0.0
+	for !r.quitting() {
+		r.sys.Think()
+		// On windows System.Think() only queues its work up on the render thread.
+		render.Purge()
+
+		now := r.clock()
+		elapsed := now - last
+		last = now
+		if elapsed > r.max_frame {
+			elapsed = r.max_frame
+		}
+		if !r.Paused() {
+			behind += elapsed
+			for behind >= r.tick && !r.quitting() {
+				r.update()
+				behind -= r.tick
+				r.mutex.Lock()
+				r.ticks++
+				r.mutex.Unlock()
+			}
+			alpha = float64(behind) / float64(r.tick)
+		}
+		if r.quitting() {
+			break
+		}
+
+		frame_alpha := alpha
+		// Drawing goes ahead of any bulk work, like texture uploads, that is
+		// waiting on the render thread.
+		render.QueuePriority(render.PriorityHigh, func() {
+			if r.draw != nil {
+				r.draw(frame_alpha)
+			}
+			r.sys.SwapBuffers()
+		})
+		render.Purge()
+		r.mutex.Lock()
+		r.frames++
+		r.mutex.Unlock()
+	}
+}
@@ -2,6 +2,8 @@ package system
 
 import (
 	"github.com/runningwild/glop/gin"
+	"image"
+	"sort"
 )
 
 type System interface {
@@ -12,8 +14,10 @@ type System interface {
 	Think()
 
 	CreateWindow(x, y, width, height int)
-	// TODO: implement this:
-	// DestroyWindow(Window)
+
+	// Destroys the window and its OpenGl context.  CreateWindow() can be called
+	// again afterwards, for example to recreate the window at a different size.
+	DestroyWindow()
 
 	// Gets the cursor position in window coordinates with the cursor at the bottom left
 	// corner of the window
@@ -23,17 +27,76 @@ type System interface {
 	// locked.  It should still generate mouse move events.
 	HideCursor(bool)
 
+	// Enables or disables relative mouse mode, which is what first-person
+	// camera controls want.  In relative mode the cursor is hidden and locked,
+	// and MouseXAxis and MouseYAxis report unaccelerated motion.  Where the OS
+	// can tell mice apart each mouse reports its own motion on its own device,
+	// see gin.Input.MouseDelta().
+	//
+	// Whether or not relative mode is enabled, the press amounts of MouseXAxis
+	// and MouseYAxis events are how far the mouse moved since the last event,
+	// not where the cursor is.  Use GetCursorPos() for the cursor's position.
+	SetRelativeMouseMode(bool)
+
 	GetWindowDims() (x, y, dx, dy int)
 
+	// Sets the window's title.  This can be called before CreateWindow(), and
+	// the title is kept if the window is recreated.
+	SetWindowTitle(title string)
+
+	// Sets the window's icon, which should be square.  A nil icon goes back to
+	// the default one.  Like the title, this is kept if the window is recreated.
+	SetWindowIcon(icon image.Image)
+
+	// Sets the cursor that is shown over the window to img.  The hot spot, the
+	// pixel that is at the cursor's position, is at hot_x, hot_y relative to the
+	// top left of img.  A nil img goes back to CursorArrow.  This doesn't
+	// affect whether the cursor is hidden, see HideCursor().  If the hot spot
+	// isn't inside img this returns an error and leaves the cursor alone.
+	SetCursorImage(img image.Image, hot_x, hot_y int) error
+	SetStandardCursor(cursor StandardCursor)
+
+	// Gets and sets the text on the system clipboard.
+	GetClipboardText() string
+	SetClipboardText(text string)
+
+	// Returns every monitor attached to the system, with the primary monitor
+	// first.
+	GetMonitors() []Monitor
+
+	// Switches the window between windowed and fullscreen.  monitor is an index
+	// into the slice returned by GetMonitors(), and display_mode must be one of
+	// that monitor's Modes, but each is only used by the modes that need it.
+	// Switching back to Windowed restores the window's position and size from
+	// before it went fullscreen, and restores the monitor's display mode.
+	SetWindowMode(mode WindowMode, monitor int, display_mode DisplayMode) error
+	GetWindowMode() WindowMode
+
+	// Returns the ContentScale of the monitor that the window is on.
+	GetContentScale() float64
+
 	SwapBuffers()
 	GetActiveDevices() map[gin.DeviceType][]gin.DeviceIndex
 	GetInputEvents() []gin.EventGroup
 
+	// Returns the text events that happened during the last call to Think().
+	// These are also sent to any gin Listeners that implement gin.TextHandler.
+	GetTextEvents() []gin.TextEvent
+
+	// Returns the devices that were connected or disconnected during the last
+	// call to Think().  These are also sent to any gin Listeners that implement
+	// gin.DeviceHandler, before any input from that frame is processed.  Keys
+	// that are down on a device when it is disconnected are released.
+	GetDeviceEvents() []gin.DeviceEvent
+
+	// Returns the window events that happened during the last call to Think(),
+	// in the order that they happened.
+	GetWindowEvents() []WindowEvent
+
 	EnableVSync(bool)
 
-	// These probably shouldn't be here, probably always want to do the Think() approach
-	//  Run()
-	//  Quit()
+	// There is no Run() or Quit() here, a System is driven by calling Think()
+	// every frame.  See Runner for a main loop that does that.
 }
 
 // This is the interface implemented by any operating system that supports
@@ -53,8 +116,9 @@ type Os interface {
 	// dimensions or in full sreen mode.
 	CreateWindow(x, y, width, height int)
 
-	// TODO: implement this:
-	// DestroyWindow(Window)
+	// Destroy the window created by CreateWindow(), if there is one, along with
+	// its OpenGl context.
+	DestroyWindow()
 
 	// Gets the cursor position in window coordinates with the cursor at the bottom left
 	// corner of the window
@@ -64,8 +128,45 @@ type Os interface {
 	// locked.  It should still generate mouse move events.
 	HideCursor(bool)
 
+	// Enables or disables relative mouse mode.  While it is enabled the cursor
+	// is hidden and locked, and MouseXAxis and MouseYAxis events report
+	// unaccelerated motion, on a separate device for each mouse if possible.
+	// Either way MouseXAxis and MouseYAxis events report how far the mouse
+	// moved, not the cursor's position, see System.SetRelativeMouseMode().
+	SetRelativeMouseMode(bool)
+
 	GetWindowDims() (x, y, dx, dy int)
 
+	// Sets the window's title and icon.  These are kept even if there is no
+	// window, and used by the next call to CreateWindow().  A nil icon goes back
+	// to the default.
+	SetWindowTitle(title string)
+	SetWindowIcon(icon image.Image)
+
+	// Sets the cursor shown over the window, see System.SetCursorImage().  The
+	// cursor is kept even if there is no window, like the title and icon.  See
+	// CheckCursorImage() for validating the arguments.
+	SetCursorImage(img image.Image, hot_x, hot_y int) error
+	SetStandardCursor(cursor StandardCursor)
+
+	// Gets and sets the text on the system clipboard.  The text is UTF-8.
+	GetClipboardText() string
+	SetClipboardText(text string)
+
+	// Returns every monitor attached to the system, with the primary monitor
+	// first.
+	GetMonitors() []Monitor
+
+	// Switches the window between windowed, borderless fullscreen and exclusive
+	// fullscreen.  See System.SetWindowMode(), and CheckWindowMode() for
+	// validating the arguments.
+	SetWindowMode(mode WindowMode, monitor int, display_mode DisplayMode) error
+	GetWindowMode() WindowMode
+
+	// Returns the ContentScale of the monitor that the window is on, or of the
+	// primary monitor if there is no window.
+	GetContentScale() float64
+
 	// Swap the OpenGl buffers on this window
 	SwapBuffers()
 
@@ -79,6 +180,24 @@ type Os interface {
 	// horizon, no future events will have a timestamp less than or equal to it.
 	GetInputEvents() ([]gin.OsEvent, int64)
 
+	// Returns all of the text events in the order that they happened since the
+	// last call to this function.  Timestamps are on the same clock as those
+	// returned by GetInputEvents().
+	GetTextEvents() []gin.TextEvent
+
+	// Returns all devices that were connected or disconnected since the last
+	// call to this function, in the order that it happened.  Timestamps are on
+	// the same clock as those returned by GetInputEvents(), and are never
+	// greater than the last horizon returned by GetInputEvents().  A device
+	// that is reconnected should get the same DeviceIndex that it had before.
+	GetDeviceEvents() []gin.DeviceEvent
+
+	// Returns all of the window events in the order that they happened since
+	// the last call to this function.  Timestamps are on the same clock as those
+	// returned by GetInputEvents().  Events for a window are not reported after
+	// DestroyWindow() has been called on it.
+	GetWindowEvents() []WindowEvent
+
 	EnableVSync(bool)
 
 	// Returns true iff the application currently is in focus.
@@ -90,9 +209,12 @@ type Os interface {
 }
 
 type sysObj struct {
-	os       Os
-	events   []gin.EventGroup
-	start_ms int64
+	os            Os
+	events        []gin.EventGroup
+	text_events   []gin.TextEvent
+	device_events []gin.DeviceEvent
+	window_events []WindowEvent
+	start_ms      int64
 }
 
 func Make(os Os) System {
@@ -110,20 +232,74 @@ func (sys *sysObj) thinkInternal() {
 	for i := range events {
 		events[i].Timestamp -= sys.start_ms
 	}
+	sys.device_events = sys.os.GetDeviceEvents()
+	for i := range sys.device_events {
+		sys.device_events[i].Timestamp -= sys.start_ms
+	}
+	gin.In().DispatchDeviceEvents(sys.device_events)
+	if releases := gin.In().DeviceReleaseEvents(sys.device_events); len(releases) > 0 {
+		events = append(events, releases...)
+		sort.Stable(osEventSlice(events))
+	}
 	sys.events = gin.In().Think(horizon-sys.start_ms, sys.os.HasFocus(), events)
+	sys.text_events = sys.os.GetTextEvents()
+	for i := range sys.text_events {
+		sys.text_events[i].Timestamp -= sys.start_ms
+	}
+	gin.In().DispatchTextEvents(sys.text_events)
+	sys.window_events = sys.os.GetWindowEvents()
+	for i := range sys.window_events {
+		sys.window_events[i].Timestamp -= sys.start_ms
+	}
 }
 func (sys *sysObj) CreateWindow(x, y, width, height int) {
 	sys.os.CreateWindow(x, y, width, height)
 }
+func (sys *sysObj) DestroyWindow() {
+	sys.os.DestroyWindow()
+}
 func (sys *sysObj) GetCursorPos() (int, int) {
 	return sys.os.GetCursorPos()
 }
 func (sys *sysObj) HideCursor(hide bool) {
 	sys.os.HideCursor(hide)
 }
+func (sys *sysObj) SetRelativeMouseMode(enable bool) {
+	sys.os.SetRelativeMouseMode(enable)
+}
 func (sys *sysObj) GetWindowDims() (int, int, int, int) {
 	return sys.os.GetWindowDims()
 }
+func (sys *sysObj) SetWindowTitle(title string) {
+	sys.os.SetWindowTitle(title)
+}
+func (sys *sysObj) SetWindowIcon(icon image.Image) {
+	sys.os.SetWindowIcon(icon)
+}
+func (sys *sysObj) SetCursorImage(img image.Image, hot_x, hot_y int) error {
+	return sys.os.SetCursorImage(img, hot_x, hot_y)
+}
+func (sys *sysObj) SetStandardCursor(cursor StandardCursor) {
+	sys.os.SetStandardCursor(cursor)
+}
+func (sys *sysObj) GetClipboardText() string {
+	return sys.os.GetClipboardText()
+}
+func (sys *sysObj) SetClipboardText(text string) {
+	sys.os.SetClipboardText(text)
+}
+func (sys *sysObj) GetMonitors() []Monitor {
+	return sys.os.GetMonitors()
+}
+func (sys *sysObj) SetWindowMode(mode WindowMode, monitor int, display_mode DisplayMode) error {
+	return sys.os.SetWindowMode(mode, monitor, display_mode)
+}
+func (sys *sysObj) GetWindowMode() WindowMode {
+	return sys.os.GetWindowMode()
+}
+func (sys *sysObj) GetContentScale() float64 {
+	return sys.os.GetContentScale()
+}
 func (sys *sysObj) SwapBuffers() {
 	sys.os.SwapBuffers()
 }
@@ -133,6 +309,21 @@ func (sys *sysObj) GetActiveDevices() map[gin.DeviceType][]gin.DeviceIndex {
 func (sys *sysObj) GetInputEvents() []gin.EventGroup {
 	return sys.events
 }
+func (sys *sysObj) GetTextEvents() []gin.TextEvent {
+	return sys.text_events
+}
+func (sys *sysObj) GetDeviceEvents() []gin.DeviceEvent {
+	return sys.device_events
+}
+func (sys *sysObj) GetWindowEvents() []WindowEvent {
+	return sys.window_events
+}
 func (sys *sysObj) EnableVSync(enable bool) {
 	sys.os.EnableVSync(enable)
 }
+
+type osEventSlice []gin.OsEvent
+
+func (oes osEventSlice) Len() int           { return len(oes) }
+func (oes osEventSlice) Swap(i, j int)      { oes[i], oes[j] = oes[j], oes[i] }
+func (oes osEventSlice) Less(i, j int) bool { return oes[i].Timestamp < oes[j].Timestamp }
@@ -10,7 +10,7 @@ import (
 )
 
 func (sys *sysObj) Think() {
-	render.Queue(func() {
+	render.QueuePriority(render.PriorityHigh, func() {
 		sys.thinkInternal()
 	})
 }
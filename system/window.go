@@ -0,0 +1,58 @@
+package system
+
+import (
+	"fmt"
+)
+
+type WindowEventType int
+
+const (
+	// The user asked for the window to be closed, e.g. by clicking its close
+	// button.  The window is not closed, it is up to the app to decide whether
+	// to call DestroyWindow().
+	WindowCloseRequested WindowEventType = iota
+
+	// The window's size changed.  Dx and Dy are the new size.
+	WindowResized
+
+	// The window moved.  X and Y are the new position.
+	WindowMoved
+
+	WindowFocusGained
+	WindowFocusLost
+
+	WindowMinimized
+	WindowRestored
+)
+
+func (t WindowEventType) String() string {
+	switch t {
+	case WindowCloseRequested:
+		return "close requested"
+	case WindowResized:
+		return "resized"
+	case WindowMoved:
+		return "moved"
+	case WindowFocusGained:
+		return "focus gained"
+	case WindowFocusLost:
+		return "focus lost"
+	case WindowMinimized:
+		return "minimized"
+	case WindowRestored:
+		return "restored"
+	}
+	panic(fmt.Sprintf("%d is not a valid WindowEventType", t))
+}
+
+// A WindowEvent is something that happened to the window, rather than input
+// from a device.  X, Y, Dx and Dy are the window's dimensions after the event,
+// the same as what GetWindowDims() would have returned right then.
+type WindowEvent struct {
+	Type WindowEventType
+
+	X, Y, Dx, Dy int
+
+	// Timestamp is on the same clock as the timestamps on input events.
+	Timestamp int64
+}
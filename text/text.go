@@ -106,11 +106,17 @@ type RuneInfo struct {
 	LeftSideBearing int
 }
 type strData struct {
-	varrays  [1]uint32
-	vbuffers [2]uint32
+	varray   *render.Resource
+	vbuffers [2]*render.Resource // position, tex coord
 	count    int32
 }
 
+func (data strData) release() {
+	data.varray.Release()
+	data.vbuffers[0].Release()
+	data.vbuffers[1].Release()
+}
+
 // Dictionary contains all of the information about a font necessary for rendering it using
 // distance field font rendering.
 type Dictionary struct {
@@ -128,8 +134,8 @@ type Dictionary struct {
 
 	// atlas texture and sampler
 	atlas struct {
-		texture  uint32
-		sampler  uint32
+		texture  *render.Resource
+		sampler  *render.Resource
 		varrays  [1]uint32
 		vbuffers [2]uint32 // position, tex coord
 	}
@@ -139,41 +145,41 @@ type Dictionary struct {
 	color [3]float32
 }
 
-var initOnce sync.Once
+var (
+	initOnce sync.Once
+	initErr  error
+)
 
 // LoadDictionary reads a gobbed Dictionary object from r, registers its atlas texture with opengl,
 // and returns a Dictionary that is ready to render text.
 func LoadDictionary(r io.Reader) (*Dictionary, error) {
-	errChan := make(chan error)
 	initOnce.Do(func() {
-		render.Queue(func() {
-			// errChan <- render.RegisterShader("glop.font", []byte(font_vertex_shader), []byte(font_fragment_shader))
-			errChan <- render.RegisterShader("glop.font", []byte(font_vshader), []byte(font_fshader))
+		initErr = render.Do(func() error {
+			// return render.RegisterShader("glop.font", []byte(font_vertex_shader), []byte(font_fragment_shader))
+			return render.RegisterShader("glop.font", []byte(font_vshader), []byte(font_fshader))
 		})
 	})
-	err := <-errChan
-	if err != nil {
-		return nil, err
+	if initErr != nil {
+		return nil, initErr
 	}
 
 	var dict Dictionary
 	dec := gob.NewDecoder(r)
-	err = dec.Decode(&dict)
+	err := dec.Decode(&dict)
 	if err != nil {
 		return nil, err
 	}
 
-	render.Queue(func() {
+	err = render.Do(func() error {
 		// Create the gl texture for the atlas
-		gl.GenTextures(1, &dict.atlas.texture)
+		dict.atlas.texture = render.NewTexture("glop.font atlas")
 		glerr := gl.GetError()
 		if glerr != 0 {
-			errChan <- fmt.Errorf("Gl Error on gl.GenTextures: %v", glerr)
-			return
+			return fmt.Errorf("Gl Error on gl.GenTextures: %v", glerr)
 		}
 
 		// Send the atlas to opengl
-		gl.BindTexture(gl.TEXTURE_2D, dict.atlas.texture)
+		gl.BindTexture(gl.TEXTURE_2D, dict.atlas.texture.Id())
 		gl.PixelStorei(gl.UNPACK_ALIGNMENT, 1)
 		gl.TexImage2D(
 			gl.TEXTURE_2D,
@@ -187,32 +193,53 @@ func LoadDictionary(r io.Reader) (*Dictionary, error) {
 			gl.Ptr(&dict.Pix[0]))
 		glerr = gl.GetError()
 		if glerr != 0 {
-			errChan <- fmt.Errorf("Gl Error on creating texture: %v", glerr)
-			return
+			return fmt.Errorf("Gl Error on creating texture: %v", glerr)
 		}
 
 		// Create the atlas sampler and set the parameters we want for it
-		gl.GenSamplers(1, &dict.atlas.sampler)
-		gl.SamplerParameteri(dict.atlas.sampler, gl.TEXTURE_MIN_FILTER, gl.LINEAR)
-		gl.SamplerParameteri(dict.atlas.sampler, gl.TEXTURE_MAG_FILTER, gl.LINEAR)
-		gl.SamplerParameteri(dict.atlas.sampler, gl.TEXTURE_WRAP_S, gl.REPEAT)
-		gl.SamplerParameteri(dict.atlas.sampler, gl.TEXTURE_WRAP_T, gl.REPEAT)
+		dict.atlas.sampler = render.NewSampler("glop.font atlas")
+		sampler := dict.atlas.sampler.Id()
+		gl.SamplerParameteri(sampler, gl.TEXTURE_MIN_FILTER, gl.LINEAR)
+		gl.SamplerParameteri(sampler, gl.TEXTURE_MAG_FILTER, gl.LINEAR)
+		gl.SamplerParameteri(sampler, gl.TEXTURE_WRAP_S, gl.REPEAT)
+		gl.SamplerParameteri(sampler, gl.TEXTURE_WRAP_T, gl.REPEAT)
 		glerr = gl.GetError()
 		if glerr != 0 {
-			errChan <- fmt.Errorf("Gl Error on creating sampler: %v", glerr)
-			return
+			return fmt.Errorf("Gl Error on creating sampler: %v", glerr)
 		}
-		errChan <- nil
+		return nil
 	})
-
-	err = <-errChan
 	if err != nil {
+		if dict.atlas.texture != nil {
+			dict.atlas.texture.Release()
+		}
+		if dict.atlas.sampler != nil {
+			dict.atlas.sampler.Release()
+		}
 		return nil, err
 	}
 
 	return &dict, nil
 }
 
+// FreeStrings frees the vertex data that RenderString() keeps for every string
+// it has rendered, e.g. when the text on screen changes completely.  Must be
+// called on the render thread.
+func (d *Dictionary) FreeStrings() {
+	for _, data := range d.strs {
+		data.release()
+	}
+	d.strs = nil
+}
+
+// Release frees everything the Dictionary has in opengl.  The Dictionary can't
+// be used afterwards.  Must be called on the render thread.
+func (d *Dictionary) Release() {
+	d.FreeStrings()
+	d.atlas.texture.Release()
+	d.atlas.sampler.Release()
+}
+
 func (d *Dictionary) SetFontColor(r, g, b float64) {
 	d.color[0], d.color[1], d.color[2] = float32(r), float32(g), float32(b)
 }
@@ -225,9 +252,10 @@ type pos struct {
 // text.  No error checking is done.
 func (d *Dictionary) bindString(str string) strData {
 	var data strData
-	gl.GenVertexArrays(1, &data.varrays[0])
-	gl.BindVertexArray(data.varrays[0])
-	gl.GenBuffers(2, &data.vbuffers[0])
+	data.varray = render.NewVertexArray("glop.font string")
+	gl.BindVertexArray(data.varray.Id())
+	data.vbuffers[0] = render.NewBuffer("glop.font string positions")
+	data.vbuffers[1] = render.NewBuffer("glop.font string tex coords")
 
 	var positions, texcoords []float32
 
@@ -281,13 +309,13 @@ func (d *Dictionary) bindString(str string) strData {
 		prev = r
 	}
 	data.count = int32(len(positions))
-	gl.BindBuffer(gl.ARRAY_BUFFER, data.vbuffers[0])
+	gl.BindBuffer(gl.ARRAY_BUFFER, data.vbuffers[0].Id())
 	gl.BufferData(gl.ARRAY_BUFFER, len(positions)*int(unsafe.Sizeof(positions[0])), gl.Ptr(&positions[0]), gl.STATIC_DRAW)
 	location, _ := render.GetAttribLocation("glop.font", "position")
 	gl.EnableVertexAttribArray(uint32(location))
 	gl.VertexAttribPointer(uint32(location), 2, gl.FLOAT, false, 0, gl.PtrOffset(0))
 
-	gl.BindBuffer(gl.ARRAY_BUFFER, data.vbuffers[1])
+	gl.BindBuffer(gl.ARRAY_BUFFER, data.vbuffers[1].Id())
 	gl.BufferData(gl.ARRAY_BUFFER, len(texcoords)*int(unsafe.Sizeof(texcoords[0])), gl.Ptr(&texcoords[0]), gl.STATIC_DRAW)
 	location, _ = render.GetAttribLocation("glop.font", "texCoord")
 	gl.EnableVertexAttribArray(uint32(location))
@@ -316,27 +344,20 @@ func (d *Dictionary) RenderString(str string, x, y, height float64) {
 	defer render.EnableShader("")
 
 	gl.ActiveTexture(gl.TEXTURE0)
-	gl.BindTexture(gl.TEXTURE_2D, d.atlas.texture)
-	location, _ := render.GetUniformLocation("glop.font", "tex")
-	gl.Uniform1i(location, 0)
-	gl.BindSampler(0, d.atlas.sampler)
+	gl.BindTexture(gl.TEXTURE_2D, d.atlas.texture.Id())
+	render.SetUniformSampler("glop.font", "tex", 0)
+	gl.BindSampler(0, d.atlas.sampler.Id())
 
-	location, _ = render.GetUniformLocation("glop.font", "height")
-	gl.Uniform1f(location, float32(height))
+	render.SetUniformF("glop.font", "height", float32(height))
 
 	var viewport [4]int32
 	gl.GetIntegerv(gl.VIEWPORT, &viewport[0])
-	location, _ = render.GetUniformLocation("glop.font", "screen")
-	gl.Uniform2f(location, float32(viewport[2]), float32(viewport[3]))
-
-	location, _ = render.GetUniformLocation("glop.font", "pen")
-	gl.Uniform2f(location, float32(x)+float32(viewport[0]), float32(y)+float32(viewport[1]))
-
-	location, _ = render.GetUniformLocation("glop.font", "textColor")
-	gl.Uniform3f(location, d.color[0], d.color[1], d.color[2])
+	render.SetUniform2F("glop.font", "screen", []float32{float32(viewport[2]), float32(viewport[3])})
+	render.SetUniform2F("glop.font", "pen", []float32{float32(x) + float32(viewport[0]), float32(y) + float32(viewport[1])})
+	render.SetUniform3F("glop.font", "textColor", d.color[:])
 
 	gl.Enable(gl.BLEND)
 	gl.BlendFunc(gl.SRC_ALPHA, gl.ONE_MINUS_SRC_ALPHA)
-	gl.BindVertexArray(data.varrays[0])
+	gl.BindVertexArray(data.varray.Id())
 	gl.DrawArrays(gl.TRIANGLES, 0, data.count)
 }